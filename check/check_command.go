@@ -3,6 +3,8 @@ package check
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/syslxg/gcs-resource"
 	"github.com/syslxg/gcs-resource/versions"
@@ -23,15 +25,35 @@ func (command *CheckCommand) Run(request CheckRequest) (CheckResponse, error) {
 		return CheckResponse{}, errors.New(message)
 	}
 
-	if request.Source.Regexp != "" {
-		return command.checkByRegex(request), nil
+	var response CheckResponse
+	var err error
+
+	if request.Source.Regexp != "" && request.Source.VersionFrom != "" {
+		response, err = command.checkByAttribute(request)
+	} else if request.Source.Regexp != "" && request.Source.TrackGenerations {
+		response, err = command.checkByRegexGenerations(request)
+	} else if request.Source.Regexp != "" {
+		response = command.checkByRegex(request)
+	} else if request.Source.PrefixRegexp != "" {
+		response = command.checkByPrefix(request)
 	} else {
-		return command.checkByVersionedFile(request)
+		response, err = command.checkByVersionedFile(request)
+	}
+
+	if err != nil {
+		return nil, err
 	}
+
+	return limitVersions(response, request.Source.MaxVersions), nil
 }
 
 func (command *CheckCommand) checkByRegex(request CheckRequest) CheckResponse {
-	extractions := versions.GetBucketObjectVersions(command.gcsClient, request.Source)
+	var extractions versions.Extractions
+	if since, ok := versionSince(request); ok {
+		extractions = versions.GetBucketObjectGenerations(command.gcsClient, request.Source).Since(since)
+	} else {
+		extractions = versions.GetBucketObjectVersions(command.gcsClient, request.Source)
+	}
 
 	if len(extractions) == 0 {
 		return CheckResponse{}
@@ -45,10 +67,83 @@ func (command *CheckCommand) checkByRegex(request CheckRequest) CheckResponse {
 	}
 }
 
+func (command *CheckCommand) checkByPrefix(request CheckRequest) CheckResponse {
+	extractions := versions.GetBucketPrefixVersions(command.gcsClient, request.Source)
+
+	if len(extractions) == 0 {
+		return CheckResponse{}
+	}
+
+	lastVersion, matched := versions.Extract(request.Version.Path, versions.PrefixPattern(request.Source.PrefixRegexp))
+	if !matched {
+		return latestVersion(extractions)
+	} else {
+		return newerVersions(lastVersion, extractions)
+	}
+}
+
+func (command *CheckCommand) checkByRegexGenerations(request CheckRequest) (CheckResponse, error) {
+	extractions := versions.GetBucketObjectGenerations(command.gcsClient, request.Source)
+	if since, ok := versionSince(request); ok {
+		extractions = extractions.Since(since)
+	}
+
+	if len(extractions) == 0 {
+		return CheckResponse{}, nil
+	}
+
+	lastVersion, matched := versions.Extract(request.Version.Path, request.Source.Regexp)
+	if !matched {
+		return latestGeneration(extractions), nil
+	}
+
+	if request.Version.Generation != "" {
+		requestGeneration, err := request.Version.GenerationValue()
+		if err != nil {
+			return nil, err
+		}
+		lastVersion.Generation = requestGeneration
+	}
+
+	return newerGenerations(lastVersion, extractions), nil
+}
+
+func (command *CheckCommand) checkByAttribute(request CheckRequest) (CheckResponse, error) {
+	versionFrom, err := versions.ParseVersionFrom(request.Source.VersionFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	attributions := versions.GetBucketObjectAttributions(command.gcsClient, request.Source)
+	if since, ok := versionSince(request); ok {
+		attributions = attributions.Since(since)
+	}
+
+	if len(attributions) == 0 {
+		return CheckResponse{}, nil
+	}
+
+	if request.Version.Value == "" {
+		return latestAttribution(attributions), nil
+	}
+
+	newer, found := attributions.Newer(request.Version.Path, request.Version.Value, versionFrom)
+	if !found {
+		return latestAttribution(attributions), nil
+	}
+
+	response := CheckResponse{}
+	for _, attribution := range newer {
+		response = append(response, attributionVersion(attribution))
+	}
+
+	return response, nil
+}
+
 func (command *CheckCommand) checkByVersionedFile(request CheckRequest) (CheckResponse, error) {
 	response := CheckResponse{}
 
-	generations, err := command.gcsClient.ObjectGenerations(request.Source.Bucket, request.Source.VersionedFile)
+	generations, err := command.objectGenerations(request)
 	if err != nil {
 		return response, err
 	}
@@ -57,13 +152,17 @@ func (command *CheckCommand) checkByVersionedFile(request CheckRequest) (CheckRe
 		return response, nil
 	}
 
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i] < generations[j]
+	})
+
 	if request.Version.Generation != "" {
-		for _, generation := range generations {
-			requestGeneration, err := request.Version.GenerationValue()
-			if err != nil {
-				return nil, err
-			}
+		requestGeneration, err := request.Version.GenerationValue()
+		if err != nil {
+			return nil, err
+		}
 
+		for _, generation := range generations {
 			if generation > requestGeneration {
 				version := gcsresource.Version{
 					Generation: fmt.Sprintf("%d", generation),
@@ -72,12 +171,7 @@ func (command *CheckCommand) checkByVersionedFile(request CheckRequest) (CheckRe
 			}
 		}
 	} else {
-		maxGeneration := generations[0]
-		for _, generation := range generations {
-			if generation > maxGeneration {
-				maxGeneration = generation
-			}
-		}
+		maxGeneration := generations[len(generations)-1]
 
 		version := gcsresource.Version{
 			Generation: fmt.Sprintf("%d", maxGeneration),
@@ -88,6 +182,48 @@ func (command *CheckCommand) checkByVersionedFile(request CheckRequest) (CheckRe
 	return response, nil
 }
 
+func (command *CheckCommand) objectGenerations(request CheckRequest) ([]int64, error) {
+	since, ok := versionSince(request)
+	if !ok {
+		return command.gcsClient.ObjectGenerations(request.Source.Bucket, request.Source.VersionedFile)
+	}
+
+	objects, err := command.gcsClient.ObjectGenerationsInfo(request.Source.Bucket, request.Source.VersionedFile)
+	if err != nil {
+		return nil, err
+	}
+
+	generations := []int64{}
+	for _, object := range objects {
+		created, err := time.Parse(time.RFC3339Nano, object.TimeCreated)
+		if err == nil && !created.Before(since) {
+			generations = append(generations, object.Generation)
+		}
+	}
+
+	return generations, nil
+}
+
+// versionSince returns the parsed version_since, which IsValid has already
+// validated, and false if it is not set.
+func versionSince(request CheckRequest) (time.Time, bool) {
+	if request.Source.VersionSince == "" {
+		return time.Time{}, false
+	}
+
+	since, err := request.Source.VersionSinceTime()
+	return since, err == nil
+}
+
+// limitVersions keeps the newest maxVersions versions, if set.
+func limitVersions(response CheckResponse, maxVersions int) CheckResponse {
+	if maxVersions > 0 && len(response) > maxVersions {
+		return response[len(response)-maxVersions:]
+	}
+
+	return response
+}
+
 func latestVersion(extractions versions.Extractions) CheckResponse {
 	lastExtraction := extractions[len(extractions)-1]
 	return []gcsresource.Version{{Path: lastExtraction.Path}}
@@ -107,3 +243,40 @@ func newerVersions(lastVersion versions.Extraction, extractions versions.Extract
 
 	return response
 }
+
+func latestGeneration(extractions versions.Extractions) CheckResponse {
+	lastExtraction := extractions[len(extractions)-1]
+	return []gcsresource.Version{generationVersion(lastExtraction)}
+}
+
+func newerGenerations(lastVersion versions.Extraction, extractions versions.Extractions) CheckResponse {
+	response := CheckResponse{}
+
+	for _, extraction := range extractions {
+		comparison := extraction.Version.Compare(lastVersion.Version)
+		if comparison > 0 || (comparison == 0 && extraction.Generation > lastVersion.Generation) {
+			response = append(response, generationVersion(extraction))
+		}
+	}
+
+	return response
+}
+
+func generationVersion(extraction versions.Extraction) gcsresource.Version {
+	return gcsresource.Version{
+		Path:       extraction.Path,
+		Generation: fmt.Sprintf("%d", extraction.Generation),
+	}
+}
+
+func latestAttribution(attributions versions.Attributions) CheckResponse {
+	lastAttribution := attributions[len(attributions)-1]
+	return []gcsresource.Version{attributionVersion(lastAttribution)}
+}
+
+func attributionVersion(attribution versions.Attribution) gcsresource.Version {
+	return gcsresource.Version{
+		Path:  attribution.Path,
+		Value: attribution.Value,
+	}
+}
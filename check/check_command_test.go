@@ -10,6 +10,7 @@ import (
 
 	"github.com/syslxg/gcs-resource"
 	"github.com/syslxg/gcs-resource/fakes"
+	storage "google.golang.org/api/storage/v1"
 
 	. "github.com/syslxg/gcs-resource/check"
 )
@@ -69,13 +70,52 @@ var _ = Describe("Check Command", func() {
 					Expect(err.Error()).To(ContainSubstring("please specify either regexp or versioned_file"))
 				})
 			})
+
+			Context("when version_from is set without a regexp", func() {
+				BeforeEach(func() {
+					request.Source.VersionedFile = "folder/version"
+					request.Source.VersionFrom = "updated"
+				})
+
+				It("returns an error", func() {
+					_, err := command.Run(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("please specify regexp when using version_from"))
+				})
+			})
+
+			Context("when max_versions is negative", func() {
+				BeforeEach(func() {
+					request.Source.Regexp = "folder/file-(.*).tgz"
+					request.Source.MaxVersions = -1
+				})
+
+				It("returns an error", func() {
+					_, err := command.Run(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("please specify a positive max_versions"))
+				})
+			})
+
+			Context("when track_generations is set without a regexp", func() {
+				BeforeEach(func() {
+					request.Source.VersionedFile = "folder/version"
+					request.Source.TrackGenerations = true
+				})
+
+				It("returns an error", func() {
+					_, err := command.Run(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("please specify regexp when using track_generations"))
+				})
+			})
 		})
 
 		Describe("with regexp", func() {
 			BeforeEach(func() {
 				request.Source.Regexp = "folder/file-(.*).tgz"
 
-				gcsClient.BucketObjectsReturns([]string{
+				gcsClient.QueryBucketObjectsReturns([]string{
 					"folder/file-0.0.1.tgz",
 					"folder/file-2.33.333.tgz",
 					"folder/file-2.4.3.tgz",
@@ -136,9 +176,57 @@ var _ = Describe("Check Command", func() {
 				})
 			})
 
+			Context("when max_versions is set", func() {
+				BeforeEach(func() {
+					request.Source.MaxVersions = 1
+					request.Version.Path = "folder/file-0.0.1.tgz"
+				})
+
+				It("returns only the newest versions", func() {
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "folder/file-3.53.tgz"},
+					}))
+				})
+			})
+
+			Context("when version_since is set", func() {
+				BeforeEach(func() {
+					request.Source.VersionSince = "2018-01-02T00:00:00Z"
+					request.Version.Path = "folder/file-0.0.1.tgz"
+
+					gcsClient.BucketObjectsInfoReturns([]*storage.Object{
+						{Name: "folder/file-0.0.1.tgz", TimeCreated: "2018-01-01T00:00:00Z"},
+						{Name: "folder/file-2.33.333.tgz", TimeCreated: "2018-01-01T00:00:00Z"},
+						{Name: "folder/file-2.4.3.tgz", TimeCreated: "2018-01-02T00:00:00Z"},
+						{Name: "folder/file-3.53.tgz", TimeCreated: "2018-01-03T00:00:00Z"},
+					}, nil)
+				})
+
+				It("returns only the versions created since then", func() {
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "folder/file-2.4.3.tgz"},
+						{Path: "folder/file-3.53.tgz"},
+					}))
+				})
+
+				It("returns an error when version_since is invalid", func() {
+					request.Source.VersionSince = "yesterday"
+
+					_, err := command.Run(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("invalid version_since value specified: yesterday"))
+				})
+			})
+
 			Context("when the bucket does not contains objects", func() {
 				BeforeEach(func() {
-					gcsClient.BucketObjectsReturns([]string{}, nil)
+					gcsClient.QueryBucketObjectsReturns([]string{}, nil)
 				})
 
 				It("does not explode", func() {
@@ -163,6 +251,341 @@ var _ = Describe("Check Command", func() {
 			})
 		})
 
+		Describe("with regexp and track_generations", func() {
+			BeforeEach(func() {
+				request.Source.Regexp = "folder/file-(.*).tgz"
+				request.Source.TrackGenerations = true
+
+				gcsClient.BucketObjectsInfoReturns([]*storage.Object{
+					{Name: "folder/file-0.0.1.tgz", Generation: 100},
+					{Name: "folder/file-2.33.333.tgz", Generation: 400},
+					{Name: "folder/file-2.4.3.tgz", Generation: 300},
+					{Name: "folder/file-3.53.tgz", Generation: 200},
+					{Name: "folder/other-1.0.0.tgz", Generation: 500},
+				}, nil)
+			})
+
+			It("lists the objects narrowed down by the regexp", func() {
+				_, err := command.Run(request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.BucketObjectsInfoCallCount()).To(Equal(1))
+				bucketName, query := gcsClient.BucketObjectsInfoArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(query).To(Equal(gcsresource.ObjectQuery{
+					Prefix:    "folder/file-",
+					MatchGlob: "folder/file-**?tgz",
+				}))
+			})
+
+			Context("when there is no previous version", func() {
+				It("includes the latest version with its generation", func() {
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "folder/file-3.53.tgz", Generation: "200"},
+					}))
+				})
+			})
+
+			Context("when there is a previous version", func() {
+				BeforeEach(func() {
+					request.Version.Path = "folder/file-2.4.3.tgz"
+					request.Version.Generation = "300"
+				})
+
+				It("includes the most recent versions in order", func() {
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "folder/file-2.33.333.tgz", Generation: "400"},
+						{Path: "folder/file-3.53.tgz", Generation: "200"},
+					}))
+				})
+
+				Context("when the previous version has been uploaded again", func() {
+					BeforeEach(func() {
+						request.Version.Path = "folder/file-3.53.tgz"
+						request.Version.Generation = "150"
+					})
+
+					It("includes the new generation of the same path", func() {
+						response, err := command.Run(request)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(response).To(Equal(CheckResponse{
+							{Path: "folder/file-3.53.tgz", Generation: "200"},
+						}))
+					})
+				})
+
+				Context("when the previous version has no generation", func() {
+					BeforeEach(func() {
+						request.Version.Path = "folder/file-3.53.tgz"
+						request.Version.Generation = ""
+					})
+
+					It("includes the current generation of the same path", func() {
+						response, err := command.Run(request)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(response).To(Equal(CheckResponse{
+							{Path: "folder/file-3.53.tgz", Generation: "200"},
+						}))
+					})
+				})
+
+				Context("when the previous generation is invalid", func() {
+					BeforeEach(func() {
+						request.Version.Generation = "foo"
+					})
+
+					It("returns an error", func() {
+						_, err := command.Run(request)
+						Expect(err).To(HaveOccurred())
+					})
+				})
+
+				Context("when the regex does not match the previous version", func() {
+					BeforeEach(func() {
+						request.Version.Path = "folder/fake-0.0.1.tgz"
+					})
+
+					It("returns the latest version", func() {
+						response, err := command.Run(request)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(response).To(Equal(CheckResponse{
+							{Path: "folder/file-3.53.tgz", Generation: "200"},
+						}))
+					})
+				})
+			})
+
+			Context("when the bucket does not contains objects", func() {
+				BeforeEach(func() {
+					gcsClient.BucketObjectsInfoReturns([]*storage.Object{}, nil)
+				})
+
+				It("does not explode", func() {
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(HaveLen(0))
+				})
+			})
+		})
+
+		Describe("with regexp and version_from", func() {
+			BeforeEach(func() {
+				request.Source.Regexp = "folder/.*.tgz"
+
+				gcsClient.BucketObjectsInfoReturns([]*storage.Object{
+					{Name: "folder/latest.tgz", Updated: "2018-01-03T00:00:00Z", Md5Hash: "AQ==", Metadata: map[string]string{"build": "1.10"}},
+					{Name: "folder/nightly.tgz", Updated: "2018-01-01T00:00:00Z", Md5Hash: "Ag==", Metadata: map[string]string{"build": "1.9"}},
+					{Name: "folder/copy.tgz", Updated: "2018-01-02T00:00:00Z", Md5Hash: "AQ==", Metadata: map[string]string{"build": "1.2"}},
+					{Name: "folder/other.txt", Updated: "2018-01-04T00:00:00Z", Md5Hash: "Aw=="},
+				}, nil)
+			})
+
+			Context("when version_from is invalid", func() {
+				BeforeEach(func() {
+					request.Source.VersionFrom = "size"
+				})
+
+				It("returns an error", func() {
+					_, err := command.Run(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("invalid version_from value specified"))
+				})
+			})
+
+			Context("when versioning by update time", func() {
+				BeforeEach(func() {
+					request.Source.VersionFrom = "updated"
+				})
+
+				It("includes the most recently updated object", func() {
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "folder/latest.tgz", Value: "2018-01-03T00:00:00Z"},
+					}))
+				})
+
+				It("includes objects updated after the previous version", func() {
+					request.Version = gcsresource.Version{Path: "folder/nightly.tgz", Value: "2018-01-01T00:00:00Z"}
+
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "folder/copy.tgz", Value: "2018-01-02T00:00:00Z"},
+						{Path: "folder/latest.tgz", Value: "2018-01-03T00:00:00Z"},
+					}))
+				})
+
+				It("includes objects updated after a previous version that was overwritten", func() {
+					request.Version = gcsresource.Version{Path: "folder/latest.tgz", Value: "2018-01-01T12:00:00Z"}
+
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "folder/copy.tgz", Value: "2018-01-02T00:00:00Z"},
+						{Path: "folder/latest.tgz", Value: "2018-01-03T00:00:00Z"},
+					}))
+				})
+			})
+
+			Context("when versioning by custom metadata", func() {
+				BeforeEach(func() {
+					request.Source.VersionFrom = "metadata:build"
+				})
+
+				It("orders objects by their metadata value", func() {
+					request.Version = gcsresource.Version{Path: "folder/copy.tgz", Value: "1.2"}
+
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "folder/nightly.tgz", Value: "1.9"},
+						{Path: "folder/latest.tgz", Value: "1.10"},
+					}))
+				})
+			})
+
+			Context("when versioning by content hash", func() {
+				BeforeEach(func() {
+					request.Source.VersionFrom = "md5"
+				})
+
+				It("deduplicates identical uploads", func() {
+					request.Version = gcsresource.Version{Path: "folder/nightly.tgz", Value: "02"}
+
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "folder/latest.tgz", Value: "01"},
+					}))
+				})
+
+				It("does not include anything when the content is unchanged", func() {
+					request.Version = gcsresource.Version{Path: "folder/copy.tgz", Value: "01"}
+
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(BeEmpty())
+				})
+
+				It("returns the latest version when the previous content is gone", func() {
+					request.Version = gcsresource.Version{Path: "folder/latest.tgz", Value: "ff"}
+
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "folder/latest.tgz", Value: "01"},
+					}))
+				})
+			})
+		})
+
+		Describe("with prefix_regexp", func() {
+			BeforeEach(func() {
+				request.Source.PrefixRegexp = `builds/(\d+)/`
+
+				gcsClient.BucketPrefixesReturns([]string{
+					"builds/9/",
+					"builds/1234/",
+					"builds/100/",
+					"builds/latest/",
+				}, nil)
+			})
+
+			It("lists the common prefixes under the parent dir", func() {
+				_, err := command.Run(request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.BucketPrefixesCallCount()).To(Equal(1))
+				bucketName, prefix := gcsClient.BucketPrefixesArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(prefix).To(Equal("builds/"))
+			})
+
+			Context("when there is no previous version", func() {
+				It("includes the latest version", func() {
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "builds/1234/"},
+					}))
+				})
+			})
+
+			Context("when there is a previous version", func() {
+				BeforeEach(func() {
+					request.Version.Path = "builds/9/"
+				})
+
+				It("includes the most recent versions", func() {
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "builds/100/"},
+						{Path: "builds/1234/"},
+					}))
+				})
+			})
+
+			Context("when the prefix_regexp has no trailing slash", func() {
+				BeforeEach(func() {
+					request.Source.PrefixRegexp = `builds/(\d+)`
+				})
+
+				It("includes the latest version", func() {
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Path: "builds/1234/"},
+					}))
+				})
+			})
+
+			Context("when regexp is also set", func() {
+				BeforeEach(func() {
+					request.Source.Regexp = "builds/file-(.*).tgz"
+				})
+
+				It("returns an error", func() {
+					_, err := command.Run(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("please specify either prefix_regexp, regexp or versioned_file"))
+				})
+			})
+
+			Context("when the prefix_regexp spans more than one level", func() {
+				BeforeEach(func() {
+					request.Source.PrefixRegexp = `builds/(\d+)/linux/`
+				})
+
+				It("returns an error", func() {
+					_, err := command.Run(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("please specify a prefix_regexp matching a single level below its literal prefix"))
+				})
+			})
+		})
+
 		Describe("with versioned_file", func() {
 			BeforeEach(func() {
 				request.Source.VersionedFile = "folder/version"
@@ -238,6 +661,65 @@ var _ = Describe("Check Command", func() {
 				})
 			})
 
+			Context("when there is a previous version and generations are listed out of order", func() {
+				BeforeEach(func() {
+					request.Version.Generation = "100"
+				})
+
+				It("returns the newer generations in ascending order", func() {
+					response, err := command.Run(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response).To(Equal(CheckResponse{
+						{Generation: "123"},
+						{Generation: "456"},
+						{Generation: "789"},
+						{Generation: "1234"},
+					}))
+				})
+
+				Context("when max_versions is set", func() {
+					BeforeEach(func() {
+						request.Source.MaxVersions = 2
+					})
+
+					It("returns only the newest generations", func() {
+						response, err := command.Run(request)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(response).To(Equal(CheckResponse{
+							{Generation: "789"},
+							{Generation: "1234"},
+						}))
+					})
+				})
+
+				Context("when version_since is set", func() {
+					BeforeEach(func() {
+						request.Source.VersionSince = "2018-01-02T00:00:00Z"
+
+						gcsClient.ObjectGenerationsInfoReturns([]*storage.Object{
+							{Name: "folder/version", Generation: 1234, TimeCreated: "2018-01-04T00:00:00Z"},
+							{Name: "folder/version", Generation: 123, TimeCreated: "2018-01-01T00:00:00Z"},
+							{Name: "folder/version", Generation: 789, TimeCreated: "2018-01-03T00:00:00Z"},
+							{Name: "folder/version", Generation: 456, TimeCreated: "2018-01-02T00:00:00Z"},
+						}, nil)
+					})
+
+					It("returns only the generations created since then", func() {
+						response, err := command.Run(request)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(gcsClient.ObjectGenerationsCallCount()).To(Equal(0))
+						Expect(response).To(Equal(CheckResponse{
+							{Generation: "456"},
+							{Generation: "789"},
+							{Generation: "1234"},
+						}))
+					})
+				})
+			})
+
 			Context("when the file does not have generations", func() {
 				BeforeEach(func() {
 					gcsClient.ObjectGenerationsReturns([]int64{}, nil)
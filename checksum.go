@@ -0,0 +1,127 @@
+package gcsresource
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"os"
+
+	"google.golang.org/api/storage/v1"
+)
+
+// checksumReader verifies the checksums gcs reports for an object once the
+// whole object has been read.
+type checksumReader struct {
+	reader io.ReadCloser
+	object *storage.Object
+	crc32c hash.Hash32
+	md5    hash.Hash
+}
+
+func newChecksumReader(response *http.Response, object *storage.Object) io.ReadCloser {
+	// the body no longer matches the stored checksums once it has been
+	// decompressed in transit
+	if response.Uncompressed || object.ContentEncoding == "gzip" {
+		return response.Body
+	}
+
+	return &checksumReader{
+		reader: response.Body,
+		object: object,
+		crc32c: crc32.New(crc32.MakeTable(crc32.Castagnoli)),
+		md5:    md5.New(),
+	}
+}
+
+func (reader *checksumReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	reader.crc32c.Write(p[:n])
+	reader.md5.Write(p[:n])
+
+	if err == io.EOF {
+		if verifyErr := reader.verify(); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+
+	return n, err
+}
+
+func (reader *checksumReader) Close() error {
+	return reader.reader.Close()
+}
+
+func (reader *checksumReader) verify() error {
+	if reader.object.Crc32c != "" {
+		sum := make([]byte, 4)
+		binary.BigEndian.PutUint32(sum, reader.crc32c.Sum32())
+
+		if actual := base64.StdEncoding.EncodeToString(sum); actual != reader.object.Crc32c {
+			return fmt.Errorf("crc32c checksum mismatch for %s: expected %s, got %s", reader.object.Name, reader.object.Crc32c, actual)
+		}
+	}
+
+	// composite objects do not have an md5 hash
+	if reader.object.Md5Hash != "" {
+		if actual := base64.StdEncoding.EncodeToString(reader.md5.Sum(nil)); actual != reader.object.Md5Hash {
+			return fmt.Errorf("md5 checksum mismatch for %s: expected %s, got %s", reader.object.Name, reader.object.Md5Hash, actual)
+		}
+	}
+
+	return nil
+}
+
+// checksumAlgorithms are the digests written to checksum sidecar objects.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// IsChecksumAlgorithm reports whether algorithm can be used in
+// checksum_files.
+func IsChecksumAlgorithm(algorithm string) bool {
+	_, ok := checksumAlgorithms[algorithm]
+	return ok
+}
+
+// FileChecksums computes the hex encoded digests of a file for each of the
+// given algorithms in a single pass.
+func FileChecksums(path string, algorithms []string) (map[string]string, error) {
+	hashes := map[string]hash.Hash{}
+	writers := []io.Writer{}
+	for _, algorithm := range algorithms {
+		newHash, ok := checksumAlgorithms[algorithm]
+		if !ok {
+			return nil, fmt.Errorf("unsupported checksum algorithm: %s", algorithm)
+		}
+
+		hashes[algorithm] = newHash()
+		writers = append(writers, hashes[algorithm])
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return nil, err
+	}
+
+	checksums := map[string]string{}
+	for algorithm, hash := range hashes {
+		checksums[algorithm] = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	return checksums, nil
+}
@@ -2,7 +2,9 @@
 package fakes
 
 import (
+	"io"
 	"sync"
+	"time"
 
 	"github.com/syslxg/gcs-resource"
 	storage "google.golang.org/api/storage/v1"
@@ -23,6 +25,48 @@ type FakeGCSClient struct {
 		result1 []string
 		result2 error
 	}
+	QueryBucketObjectsStub        func(bucketName string, query gcsresource.ObjectQuery) ([]string, error)
+	queryBucketObjectsMutex       sync.RWMutex
+	queryBucketObjectsArgsForCall []struct {
+		bucketName string
+		query      gcsresource.ObjectQuery
+	}
+	queryBucketObjectsReturns struct {
+		result1 []string
+		result2 error
+	}
+	queryBucketObjectsReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
+	BucketObjectsInfoStub        func(bucketName string, query gcsresource.ObjectQuery) ([]*storage.Object, error)
+	bucketObjectsInfoMutex       sync.RWMutex
+	bucketObjectsInfoArgsForCall []struct {
+		bucketName string
+		query      gcsresource.ObjectQuery
+	}
+	bucketObjectsInfoReturns struct {
+		result1 []*storage.Object
+		result2 error
+	}
+	bucketObjectsInfoReturnsOnCall map[int]struct {
+		result1 []*storage.Object
+		result2 error
+	}
+	BucketPrefixesStub        func(bucketName string, prefix string) ([]string, error)
+	bucketPrefixesMutex       sync.RWMutex
+	bucketPrefixesArgsForCall []struct {
+		bucketName string
+		prefix     string
+	}
+	bucketPrefixesReturns struct {
+		result1 []string
+		result2 error
+	}
+	bucketPrefixesReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
 	ObjectGenerationsStub        func(bucketName string, objectPath string) ([]int64, error)
 	objectGenerationsMutex       sync.RWMutex
 	objectGenerationsArgsForCall []struct {
@@ -37,6 +81,20 @@ type FakeGCSClient struct {
 		result1 []int64
 		result2 error
 	}
+	ObjectGenerationsInfoStub        func(bucketName string, objectPath string) ([]*storage.Object, error)
+	objectGenerationsInfoMutex       sync.RWMutex
+	objectGenerationsInfoArgsForCall []struct {
+		bucketName string
+		objectPath string
+	}
+	objectGenerationsInfoReturns struct {
+		result1 []*storage.Object
+		result2 error
+	}
+	objectGenerationsInfoReturnsOnCall map[int]struct {
+		result1 []*storage.Object
+		result2 error
+	}
 	DownloadFileStub        func(bucketName string, objectPath string, generation int64, localPath string) error
 	downloadFileMutex       sync.RWMutex
 	downloadFileArgsForCall []struct {
@@ -51,7 +109,22 @@ type FakeGCSClient struct {
 	downloadFileReturnsOnCall map[int]struct {
 		result1 error
 	}
-	UploadFileStub        func(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string) (int64, error)
+	DownloadStreamStub        func(bucketName string, objectPath string, generation int64) (io.ReadCloser, error)
+	downloadStreamMutex       sync.RWMutex
+	downloadStreamArgsForCall []struct {
+		bucketName string
+		objectPath string
+		generation int64
+	}
+	downloadStreamReturns struct {
+		result1 io.ReadCloser
+		result2 error
+	}
+	downloadStreamReturnsOnCall map[int]struct {
+		result1 io.ReadCloser
+		result2 error
+	}
+	UploadFileStub        func(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string) (int64, *storage.Object, error)
 	uploadFileMutex       sync.RWMutex
 	uploadFileArgsForCall []struct {
 		bucketName        string
@@ -63,11 +136,55 @@ type FakeGCSClient struct {
 	}
 	uploadFileReturns struct {
 		result1 int64
-		result2 error
+		result2 *storage.Object
+		result3 error
 	}
 	uploadFileReturnsOnCall map[int]struct {
 		result1 int64
-		result2 error
+		result2 *storage.Object
+		result3 error
+	}
+	UploadFileIfGenerationMatchStub        func(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int, ifGenerationMatch int64) (int64, *storage.Object, error)
+	uploadFileIfGenerationMatchMutex       sync.RWMutex
+	uploadFileIfGenerationMatchArgsForCall []struct {
+		bucketName              string
+		objectPath              string
+		objectContentType       string
+		localPath               string
+		predefinedACL           string
+		cacheControl            string
+		parallelUploadThreshold int
+		ifGenerationMatch       int64
+	}
+	uploadFileIfGenerationMatchReturns struct {
+		result1 int64
+		result2 *storage.Object
+		result3 error
+	}
+	uploadFileIfGenerationMatchReturnsOnCall map[int]struct {
+		result1 int64
+		result2 *storage.Object
+		result3 error
+	}
+	CopyObjectStub        func(sourceBucketName string, sourceObjectPath string, sourceGeneration int64, bucketName string, objectPath string, predefinedACL string) (int64, *storage.Object, error)
+	copyObjectMutex       sync.RWMutex
+	copyObjectArgsForCall []struct {
+		sourceBucketName string
+		sourceObjectPath string
+		sourceGeneration int64
+		bucketName       string
+		objectPath       string
+		predefinedACL    string
+	}
+	copyObjectReturns struct {
+		result1 int64
+		result2 *storage.Object
+		result3 error
+	}
+	copyObjectReturnsOnCall map[int]struct {
+		result1 int64
+		result2 *storage.Object
+		result3 error
 	}
 	URLStub        func(bucketName string, objectPath string, generation int64) (string, error)
 	uRLMutex       sync.RWMutex
@@ -111,6 +228,69 @@ type FakeGCSClient struct {
 		result1 *storage.Object
 		result2 error
 	}
+	ObjectInfoStub        func(bucketName string, objectPath string, generation int64) (*storage.Object, error)
+	objectInfoMutex       sync.RWMutex
+	objectInfoArgsForCall []struct {
+		bucketName string
+		objectPath string
+		generation int64
+	}
+	objectInfoReturns struct {
+		result1 *storage.Object
+		result2 error
+	}
+	objectInfoReturnsOnCall map[int]struct {
+		result1 *storage.Object
+		result2 error
+	}
+	SetObjectHoldsStub        func(bucketName string, objectPath string, generation int64, holds gcsresource.ObjectHolds) (*storage.Object, error)
+	setObjectHoldsMutex       sync.RWMutex
+	setObjectHoldsArgsForCall []struct {
+		bucketName string
+		objectPath string
+		generation int64
+		holds      gcsresource.ObjectHolds
+	}
+	setObjectHoldsReturns struct {
+		result1 *storage.Object
+		result2 error
+	}
+	setObjectHoldsReturnsOnCall map[int]struct {
+		result1 *storage.Object
+		result2 error
+	}
+	SetObjectRetentionStub        func(bucketName string, objectPath string, generation int64, retention gcsresource.ObjectRetention) (gcsresource.ObjectRetention, error)
+	setObjectRetentionMutex       sync.RWMutex
+	setObjectRetentionArgsForCall []struct {
+		bucketName string
+		objectPath string
+		generation int64
+		retention  gcsresource.ObjectRetention
+	}
+	setObjectRetentionReturns struct {
+		result1 gcsresource.ObjectRetention
+		result2 error
+	}
+	setObjectRetentionReturnsOnCall map[int]struct {
+		result1 gcsresource.ObjectRetention
+		result2 error
+	}
+	SignedURLStub        func(bucketName string, objectPath string, generation int64, ttl time.Duration) (string, error)
+	signedURLMutex       sync.RWMutex
+	signedURLArgsForCall []struct {
+		bucketName string
+		objectPath string
+		generation int64
+		ttl        time.Duration
+	}
+	signedURLReturns struct {
+		result1 string
+		result2 error
+	}
+	signedURLReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -167,6 +347,162 @@ func (fake *FakeGCSClient) BucketObjectsReturnsOnCall(i int, result1 []string, r
 	}{result1, result2}
 }
 
+func (fake *FakeGCSClient) QueryBucketObjects(bucketName string, query gcsresource.ObjectQuery) ([]string, error) {
+	fake.queryBucketObjectsMutex.Lock()
+	ret, specificReturn := fake.queryBucketObjectsReturnsOnCall[len(fake.queryBucketObjectsArgsForCall)]
+	fake.queryBucketObjectsArgsForCall = append(fake.queryBucketObjectsArgsForCall, struct {
+		bucketName string
+		query      gcsresource.ObjectQuery
+	}{bucketName, query})
+	fake.recordInvocation("QueryBucketObjects", []interface{}{bucketName, query})
+	fake.queryBucketObjectsMutex.Unlock()
+	if fake.QueryBucketObjectsStub != nil {
+		return fake.QueryBucketObjectsStub(bucketName, query)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.queryBucketObjectsReturns.result1, fake.queryBucketObjectsReturns.result2
+}
+
+func (fake *FakeGCSClient) QueryBucketObjectsCallCount() int {
+	fake.queryBucketObjectsMutex.RLock()
+	defer fake.queryBucketObjectsMutex.RUnlock()
+	return len(fake.queryBucketObjectsArgsForCall)
+}
+
+func (fake *FakeGCSClient) QueryBucketObjectsArgsForCall(i int) (string, gcsresource.ObjectQuery) {
+	fake.queryBucketObjectsMutex.RLock()
+	defer fake.queryBucketObjectsMutex.RUnlock()
+	return fake.queryBucketObjectsArgsForCall[i].bucketName, fake.queryBucketObjectsArgsForCall[i].query
+}
+
+func (fake *FakeGCSClient) QueryBucketObjectsReturns(result1 []string, result2 error) {
+	fake.QueryBucketObjectsStub = nil
+	fake.queryBucketObjectsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) QueryBucketObjectsReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.QueryBucketObjectsStub = nil
+	if fake.queryBucketObjectsReturnsOnCall == nil {
+		fake.queryBucketObjectsReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.queryBucketObjectsReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) BucketObjectsInfo(bucketName string, query gcsresource.ObjectQuery) ([]*storage.Object, error) {
+	fake.bucketObjectsInfoMutex.Lock()
+	ret, specificReturn := fake.bucketObjectsInfoReturnsOnCall[len(fake.bucketObjectsInfoArgsForCall)]
+	fake.bucketObjectsInfoArgsForCall = append(fake.bucketObjectsInfoArgsForCall, struct {
+		bucketName string
+		query      gcsresource.ObjectQuery
+	}{bucketName, query})
+	fake.recordInvocation("BucketObjectsInfo", []interface{}{bucketName, query})
+	fake.bucketObjectsInfoMutex.Unlock()
+	if fake.BucketObjectsInfoStub != nil {
+		return fake.BucketObjectsInfoStub(bucketName, query)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.bucketObjectsInfoReturns.result1, fake.bucketObjectsInfoReturns.result2
+}
+
+func (fake *FakeGCSClient) BucketObjectsInfoCallCount() int {
+	fake.bucketObjectsInfoMutex.RLock()
+	defer fake.bucketObjectsInfoMutex.RUnlock()
+	return len(fake.bucketObjectsInfoArgsForCall)
+}
+
+func (fake *FakeGCSClient) BucketObjectsInfoArgsForCall(i int) (string, gcsresource.ObjectQuery) {
+	fake.bucketObjectsInfoMutex.RLock()
+	defer fake.bucketObjectsInfoMutex.RUnlock()
+	return fake.bucketObjectsInfoArgsForCall[i].bucketName, fake.bucketObjectsInfoArgsForCall[i].query
+}
+
+func (fake *FakeGCSClient) BucketObjectsInfoReturns(result1 []*storage.Object, result2 error) {
+	fake.BucketObjectsInfoStub = nil
+	fake.bucketObjectsInfoReturns = struct {
+		result1 []*storage.Object
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) BucketObjectsInfoReturnsOnCall(i int, result1 []*storage.Object, result2 error) {
+	fake.BucketObjectsInfoStub = nil
+	if fake.bucketObjectsInfoReturnsOnCall == nil {
+		fake.bucketObjectsInfoReturnsOnCall = make(map[int]struct {
+			result1 []*storage.Object
+			result2 error
+		})
+	}
+	fake.bucketObjectsInfoReturnsOnCall[i] = struct {
+		result1 []*storage.Object
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) BucketPrefixes(bucketName string, prefix string) ([]string, error) {
+	fake.bucketPrefixesMutex.Lock()
+	ret, specificReturn := fake.bucketPrefixesReturnsOnCall[len(fake.bucketPrefixesArgsForCall)]
+	fake.bucketPrefixesArgsForCall = append(fake.bucketPrefixesArgsForCall, struct {
+		bucketName string
+		prefix     string
+	}{bucketName, prefix})
+	fake.recordInvocation("BucketPrefixes", []interface{}{bucketName, prefix})
+	fake.bucketPrefixesMutex.Unlock()
+	if fake.BucketPrefixesStub != nil {
+		return fake.BucketPrefixesStub(bucketName, prefix)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.bucketPrefixesReturns.result1, fake.bucketPrefixesReturns.result2
+}
+
+func (fake *FakeGCSClient) BucketPrefixesCallCount() int {
+	fake.bucketPrefixesMutex.RLock()
+	defer fake.bucketPrefixesMutex.RUnlock()
+	return len(fake.bucketPrefixesArgsForCall)
+}
+
+func (fake *FakeGCSClient) BucketPrefixesArgsForCall(i int) (string, string) {
+	fake.bucketPrefixesMutex.RLock()
+	defer fake.bucketPrefixesMutex.RUnlock()
+	return fake.bucketPrefixesArgsForCall[i].bucketName, fake.bucketPrefixesArgsForCall[i].prefix
+}
+
+func (fake *FakeGCSClient) BucketPrefixesReturns(result1 []string, result2 error) {
+	fake.BucketPrefixesStub = nil
+	fake.bucketPrefixesReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) BucketPrefixesReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.BucketPrefixesStub = nil
+	if fake.bucketPrefixesReturnsOnCall == nil {
+		fake.bucketPrefixesReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.bucketPrefixesReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeGCSClient) ObjectGenerations(bucketName string, objectPath string) ([]int64, error) {
 	fake.objectGenerationsMutex.Lock()
 	ret, specificReturn := fake.objectGenerationsReturnsOnCall[len(fake.objectGenerationsArgsForCall)]
@@ -219,6 +555,58 @@ func (fake *FakeGCSClient) ObjectGenerationsReturnsOnCall(i int, result1 []int64
 	}{result1, result2}
 }
 
+func (fake *FakeGCSClient) ObjectGenerationsInfo(bucketName string, objectPath string) ([]*storage.Object, error) {
+	fake.objectGenerationsInfoMutex.Lock()
+	ret, specificReturn := fake.objectGenerationsInfoReturnsOnCall[len(fake.objectGenerationsInfoArgsForCall)]
+	fake.objectGenerationsInfoArgsForCall = append(fake.objectGenerationsInfoArgsForCall, struct {
+		bucketName string
+		objectPath string
+	}{bucketName, objectPath})
+	fake.recordInvocation("ObjectGenerationsInfo", []interface{}{bucketName, objectPath})
+	fake.objectGenerationsInfoMutex.Unlock()
+	if fake.ObjectGenerationsInfoStub != nil {
+		return fake.ObjectGenerationsInfoStub(bucketName, objectPath)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.objectGenerationsInfoReturns.result1, fake.objectGenerationsInfoReturns.result2
+}
+
+func (fake *FakeGCSClient) ObjectGenerationsInfoCallCount() int {
+	fake.objectGenerationsInfoMutex.RLock()
+	defer fake.objectGenerationsInfoMutex.RUnlock()
+	return len(fake.objectGenerationsInfoArgsForCall)
+}
+
+func (fake *FakeGCSClient) ObjectGenerationsInfoArgsForCall(i int) (string, string) {
+	fake.objectGenerationsInfoMutex.RLock()
+	defer fake.objectGenerationsInfoMutex.RUnlock()
+	return fake.objectGenerationsInfoArgsForCall[i].bucketName, fake.objectGenerationsInfoArgsForCall[i].objectPath
+}
+
+func (fake *FakeGCSClient) ObjectGenerationsInfoReturns(result1 []*storage.Object, result2 error) {
+	fake.ObjectGenerationsInfoStub = nil
+	fake.objectGenerationsInfoReturns = struct {
+		result1 []*storage.Object
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) ObjectGenerationsInfoReturnsOnCall(i int, result1 []*storage.Object, result2 error) {
+	fake.ObjectGenerationsInfoStub = nil
+	if fake.objectGenerationsInfoReturnsOnCall == nil {
+		fake.objectGenerationsInfoReturnsOnCall = make(map[int]struct {
+			result1 []*storage.Object
+			result2 error
+		})
+	}
+	fake.objectGenerationsInfoReturnsOnCall[i] = struct {
+		result1 []*storage.Object
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeGCSClient) DownloadFile(bucketName string, objectPath string, generation int64, localPath string) error {
 	fake.downloadFileMutex.Lock()
 	ret, specificReturn := fake.downloadFileReturnsOnCall[len(fake.downloadFileArgsForCall)]
@@ -270,7 +658,60 @@ func (fake *FakeGCSClient) DownloadFileReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *FakeGCSClient) UploadFile(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int) (int64, error) {
+func (fake *FakeGCSClient) DownloadStream(bucketName string, objectPath string, generation int64) (io.ReadCloser, error) {
+	fake.downloadStreamMutex.Lock()
+	ret, specificReturn := fake.downloadStreamReturnsOnCall[len(fake.downloadStreamArgsForCall)]
+	fake.downloadStreamArgsForCall = append(fake.downloadStreamArgsForCall, struct {
+		bucketName string
+		objectPath string
+		generation int64
+	}{bucketName, objectPath, generation})
+	fake.recordInvocation("DownloadStream", []interface{}{bucketName, objectPath, generation})
+	fake.downloadStreamMutex.Unlock()
+	if fake.DownloadStreamStub != nil {
+		return fake.DownloadStreamStub(bucketName, objectPath, generation)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.downloadStreamReturns.result1, fake.downloadStreamReturns.result2
+}
+
+func (fake *FakeGCSClient) DownloadStreamCallCount() int {
+	fake.downloadStreamMutex.RLock()
+	defer fake.downloadStreamMutex.RUnlock()
+	return len(fake.downloadStreamArgsForCall)
+}
+
+func (fake *FakeGCSClient) DownloadStreamArgsForCall(i int) (string, string, int64) {
+	fake.downloadStreamMutex.RLock()
+	defer fake.downloadStreamMutex.RUnlock()
+	return fake.downloadStreamArgsForCall[i].bucketName, fake.downloadStreamArgsForCall[i].objectPath, fake.downloadStreamArgsForCall[i].generation
+}
+
+func (fake *FakeGCSClient) DownloadStreamReturns(result1 io.ReadCloser, result2 error) {
+	fake.DownloadStreamStub = nil
+	fake.downloadStreamReturns = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) DownloadStreamReturnsOnCall(i int, result1 io.ReadCloser, result2 error) {
+	fake.DownloadStreamStub = nil
+	if fake.downloadStreamReturnsOnCall == nil {
+		fake.downloadStreamReturnsOnCall = make(map[int]struct {
+			result1 io.ReadCloser
+			result2 error
+		})
+	}
+	fake.downloadStreamReturnsOnCall[i] = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) UploadFile(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int) (int64, *storage.Object, error) {
 	fake.uploadFileMutex.Lock()
 	ret, specificReturn := fake.uploadFileReturnsOnCall[len(fake.uploadFileArgsForCall)]
 	fake.uploadFileArgsForCall = append(fake.uploadFileArgsForCall, struct {
@@ -287,9 +728,9 @@ func (fake *FakeGCSClient) UploadFile(bucketName string, objectPath string, obje
 		return fake.UploadFileStub(bucketName, objectPath, objectContentType, localPath, predefinedACL, cacheControl)
 	}
 	if specificReturn {
-		return ret.result1, ret.result2
+		return ret.result1, ret.result2, ret.result3
 	}
-	return fake.uploadFileReturns.result1, fake.uploadFileReturns.result2
+	return fake.uploadFileReturns.result1, fake.uploadFileReturns.result2, fake.uploadFileReturns.result3
 }
 
 func (fake *FakeGCSClient) UploadFileCallCount() int {
@@ -304,26 +745,149 @@ func (fake *FakeGCSClient) UploadFileArgsForCall(i int) (string, string, string,
 	return fake.uploadFileArgsForCall[i].bucketName, fake.uploadFileArgsForCall[i].objectPath, fake.uploadFileArgsForCall[i].objectContentType, fake.uploadFileArgsForCall[i].localPath, fake.uploadFileArgsForCall[i].predefinedACL, fake.uploadFileArgsForCall[i].cacheControl
 }
 
-func (fake *FakeGCSClient) UploadFileReturns(result1 int64, result2 error) {
+func (fake *FakeGCSClient) UploadFileReturns(result1 int64, result2 *storage.Object, result3 error) {
 	fake.UploadFileStub = nil
 	fake.uploadFileReturns = struct {
 		result1 int64
-		result2 error
-	}{result1, result2}
+		result2 *storage.Object
+		result3 error
+	}{result1, result2, result3}
 }
 
-func (fake *FakeGCSClient) UploadFileReturnsOnCall(i int, result1 int64, result2 error) {
+func (fake *FakeGCSClient) UploadFileReturnsOnCall(i int, result1 int64, result2 *storage.Object, result3 error) {
 	fake.UploadFileStub = nil
 	if fake.uploadFileReturnsOnCall == nil {
 		fake.uploadFileReturnsOnCall = make(map[int]struct {
 			result1 int64
-			result2 error
+			result2 *storage.Object
+			result3 error
 		})
 	}
 	fake.uploadFileReturnsOnCall[i] = struct {
 		result1 int64
-		result2 error
-	}{result1, result2}
+		result2 *storage.Object
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeGCSClient) UploadFileIfGenerationMatch(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int, ifGenerationMatch int64) (int64, *storage.Object, error) {
+	fake.uploadFileIfGenerationMatchMutex.Lock()
+	ret, specificReturn := fake.uploadFileIfGenerationMatchReturnsOnCall[len(fake.uploadFileIfGenerationMatchArgsForCall)]
+	fake.uploadFileIfGenerationMatchArgsForCall = append(fake.uploadFileIfGenerationMatchArgsForCall, struct {
+		bucketName              string
+		objectPath              string
+		objectContentType       string
+		localPath               string
+		predefinedACL           string
+		cacheControl            string
+		parallelUploadThreshold int
+		ifGenerationMatch       int64
+	}{bucketName, objectPath, objectContentType, localPath, predefinedACL, cacheControl, parallelUploadThreshold, ifGenerationMatch})
+	fake.recordInvocation("UploadFileIfGenerationMatch", []interface{}{bucketName, objectPath, objectContentType, localPath, predefinedACL, cacheControl, parallelUploadThreshold, ifGenerationMatch})
+	fake.uploadFileIfGenerationMatchMutex.Unlock()
+	if fake.UploadFileIfGenerationMatchStub != nil {
+		return fake.UploadFileIfGenerationMatchStub(bucketName, objectPath, objectContentType, localPath, predefinedACL, cacheControl, parallelUploadThreshold, ifGenerationMatch)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fake.uploadFileIfGenerationMatchReturns.result1, fake.uploadFileIfGenerationMatchReturns.result2, fake.uploadFileIfGenerationMatchReturns.result3
+}
+
+func (fake *FakeGCSClient) UploadFileIfGenerationMatchCallCount() int {
+	fake.uploadFileIfGenerationMatchMutex.RLock()
+	defer fake.uploadFileIfGenerationMatchMutex.RUnlock()
+	return len(fake.uploadFileIfGenerationMatchArgsForCall)
+}
+
+func (fake *FakeGCSClient) UploadFileIfGenerationMatchArgsForCall(i int) (string, string, string, string, string, string, int, int64) {
+	fake.uploadFileIfGenerationMatchMutex.RLock()
+	defer fake.uploadFileIfGenerationMatchMutex.RUnlock()
+	return fake.uploadFileIfGenerationMatchArgsForCall[i].bucketName, fake.uploadFileIfGenerationMatchArgsForCall[i].objectPath, fake.uploadFileIfGenerationMatchArgsForCall[i].objectContentType, fake.uploadFileIfGenerationMatchArgsForCall[i].localPath, fake.uploadFileIfGenerationMatchArgsForCall[i].predefinedACL, fake.uploadFileIfGenerationMatchArgsForCall[i].cacheControl, fake.uploadFileIfGenerationMatchArgsForCall[i].parallelUploadThreshold, fake.uploadFileIfGenerationMatchArgsForCall[i].ifGenerationMatch
+}
+
+func (fake *FakeGCSClient) UploadFileIfGenerationMatchReturns(result1 int64, result2 *storage.Object, result3 error) {
+	fake.UploadFileIfGenerationMatchStub = nil
+	fake.uploadFileIfGenerationMatchReturns = struct {
+		result1 int64
+		result2 *storage.Object
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeGCSClient) UploadFileIfGenerationMatchReturnsOnCall(i int, result1 int64, result2 *storage.Object, result3 error) {
+	fake.UploadFileIfGenerationMatchStub = nil
+	if fake.uploadFileIfGenerationMatchReturnsOnCall == nil {
+		fake.uploadFileIfGenerationMatchReturnsOnCall = make(map[int]struct {
+			result1 int64
+			result2 *storage.Object
+			result3 error
+		})
+	}
+	fake.uploadFileIfGenerationMatchReturnsOnCall[i] = struct {
+		result1 int64
+		result2 *storage.Object
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeGCSClient) CopyObject(sourceBucketName string, sourceObjectPath string, sourceGeneration int64, bucketName string, objectPath string, predefinedACL string) (int64, *storage.Object, error) {
+	fake.copyObjectMutex.Lock()
+	ret, specificReturn := fake.copyObjectReturnsOnCall[len(fake.copyObjectArgsForCall)]
+	fake.copyObjectArgsForCall = append(fake.copyObjectArgsForCall, struct {
+		sourceBucketName string
+		sourceObjectPath string
+		sourceGeneration int64
+		bucketName       string
+		objectPath       string
+		predefinedACL    string
+	}{sourceBucketName, sourceObjectPath, sourceGeneration, bucketName, objectPath, predefinedACL})
+	fake.recordInvocation("CopyObject", []interface{}{sourceBucketName, sourceObjectPath, sourceGeneration, bucketName, objectPath, predefinedACL})
+	fake.copyObjectMutex.Unlock()
+	if fake.CopyObjectStub != nil {
+		return fake.CopyObjectStub(sourceBucketName, sourceObjectPath, sourceGeneration, bucketName, objectPath, predefinedACL)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fake.copyObjectReturns.result1, fake.copyObjectReturns.result2, fake.copyObjectReturns.result3
+}
+
+func (fake *FakeGCSClient) CopyObjectCallCount() int {
+	fake.copyObjectMutex.RLock()
+	defer fake.copyObjectMutex.RUnlock()
+	return len(fake.copyObjectArgsForCall)
+}
+
+func (fake *FakeGCSClient) CopyObjectArgsForCall(i int) (string, string, int64, string, string, string) {
+	fake.copyObjectMutex.RLock()
+	defer fake.copyObjectMutex.RUnlock()
+	return fake.copyObjectArgsForCall[i].sourceBucketName, fake.copyObjectArgsForCall[i].sourceObjectPath, fake.copyObjectArgsForCall[i].sourceGeneration, fake.copyObjectArgsForCall[i].bucketName, fake.copyObjectArgsForCall[i].objectPath, fake.copyObjectArgsForCall[i].predefinedACL
+}
+
+func (fake *FakeGCSClient) CopyObjectReturns(result1 int64, result2 *storage.Object, result3 error) {
+	fake.CopyObjectStub = nil
+	fake.copyObjectReturns = struct {
+		result1 int64
+		result2 *storage.Object
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeGCSClient) CopyObjectReturnsOnCall(i int, result1 int64, result2 *storage.Object, result3 error) {
+	fake.CopyObjectStub = nil
+	if fake.copyObjectReturnsOnCall == nil {
+		fake.copyObjectReturnsOnCall = make(map[int]struct {
+			result1 int64
+			result2 *storage.Object
+			result3 error
+		})
+	}
+	fake.copyObjectReturnsOnCall[i] = struct {
+		result1 int64
+		result2 *storage.Object
+		result3 error
+	}{result1, result2, result3}
 }
 
 func (fake *FakeGCSClient) URL(bucketName string, objectPath string, generation int64) (string, error) {
@@ -481,23 +1045,260 @@ func (fake *FakeGCSClient) GetBucketObjectInfoReturnsOnCall(i int, result1 *stor
 	}{result1, result2}
 }
 
+func (fake *FakeGCSClient) ObjectInfo(bucketName string, objectPath string, generation int64) (*storage.Object, error) {
+	fake.objectInfoMutex.Lock()
+	ret, specificReturn := fake.objectInfoReturnsOnCall[len(fake.objectInfoArgsForCall)]
+	fake.objectInfoArgsForCall = append(fake.objectInfoArgsForCall, struct {
+		bucketName string
+		objectPath string
+		generation int64
+	}{bucketName, objectPath, generation})
+	fake.recordInvocation("ObjectInfo", []interface{}{bucketName, objectPath, generation})
+	fake.objectInfoMutex.Unlock()
+	if fake.ObjectInfoStub != nil {
+		return fake.ObjectInfoStub(bucketName, objectPath, generation)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.objectInfoReturns.result1, fake.objectInfoReturns.result2
+}
+
+func (fake *FakeGCSClient) ObjectInfoCallCount() int {
+	fake.objectInfoMutex.RLock()
+	defer fake.objectInfoMutex.RUnlock()
+	return len(fake.objectInfoArgsForCall)
+}
+
+func (fake *FakeGCSClient) ObjectInfoArgsForCall(i int) (string, string, int64) {
+	fake.objectInfoMutex.RLock()
+	defer fake.objectInfoMutex.RUnlock()
+	return fake.objectInfoArgsForCall[i].bucketName, fake.objectInfoArgsForCall[i].objectPath, fake.objectInfoArgsForCall[i].generation
+}
+
+func (fake *FakeGCSClient) ObjectInfoReturns(result1 *storage.Object, result2 error) {
+	fake.ObjectInfoStub = nil
+	fake.objectInfoReturns = struct {
+		result1 *storage.Object
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) ObjectInfoReturnsOnCall(i int, result1 *storage.Object, result2 error) {
+	fake.ObjectInfoStub = nil
+	if fake.objectInfoReturnsOnCall == nil {
+		fake.objectInfoReturnsOnCall = make(map[int]struct {
+			result1 *storage.Object
+			result2 error
+		})
+	}
+	fake.objectInfoReturnsOnCall[i] = struct {
+		result1 *storage.Object
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) SetObjectHolds(bucketName string, objectPath string, generation int64, holds gcsresource.ObjectHolds) (*storage.Object, error) {
+	fake.setObjectHoldsMutex.Lock()
+	ret, specificReturn := fake.setObjectHoldsReturnsOnCall[len(fake.setObjectHoldsArgsForCall)]
+	fake.setObjectHoldsArgsForCall = append(fake.setObjectHoldsArgsForCall, struct {
+		bucketName string
+		objectPath string
+		generation int64
+		holds      gcsresource.ObjectHolds
+	}{bucketName, objectPath, generation, holds})
+	fake.recordInvocation("SetObjectHolds", []interface{}{bucketName, objectPath, generation, holds})
+	fake.setObjectHoldsMutex.Unlock()
+	if fake.SetObjectHoldsStub != nil {
+		return fake.SetObjectHoldsStub(bucketName, objectPath, generation, holds)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.setObjectHoldsReturns.result1, fake.setObjectHoldsReturns.result2
+}
+
+func (fake *FakeGCSClient) SetObjectHoldsCallCount() int {
+	fake.setObjectHoldsMutex.RLock()
+	defer fake.setObjectHoldsMutex.RUnlock()
+	return len(fake.setObjectHoldsArgsForCall)
+}
+
+func (fake *FakeGCSClient) SetObjectHoldsArgsForCall(i int) (string, string, int64, gcsresource.ObjectHolds) {
+	fake.setObjectHoldsMutex.RLock()
+	defer fake.setObjectHoldsMutex.RUnlock()
+	return fake.setObjectHoldsArgsForCall[i].bucketName, fake.setObjectHoldsArgsForCall[i].objectPath, fake.setObjectHoldsArgsForCall[i].generation, fake.setObjectHoldsArgsForCall[i].holds
+}
+
+func (fake *FakeGCSClient) SetObjectHoldsReturns(result1 *storage.Object, result2 error) {
+	fake.SetObjectHoldsStub = nil
+	fake.setObjectHoldsReturns = struct {
+		result1 *storage.Object
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) SetObjectHoldsReturnsOnCall(i int, result1 *storage.Object, result2 error) {
+	fake.SetObjectHoldsStub = nil
+	if fake.setObjectHoldsReturnsOnCall == nil {
+		fake.setObjectHoldsReturnsOnCall = make(map[int]struct {
+			result1 *storage.Object
+			result2 error
+		})
+	}
+	fake.setObjectHoldsReturnsOnCall[i] = struct {
+		result1 *storage.Object
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) SetObjectRetention(bucketName string, objectPath string, generation int64, retention gcsresource.ObjectRetention) (gcsresource.ObjectRetention, error) {
+	fake.setObjectRetentionMutex.Lock()
+	ret, specificReturn := fake.setObjectRetentionReturnsOnCall[len(fake.setObjectRetentionArgsForCall)]
+	fake.setObjectRetentionArgsForCall = append(fake.setObjectRetentionArgsForCall, struct {
+		bucketName string
+		objectPath string
+		generation int64
+		retention  gcsresource.ObjectRetention
+	}{bucketName, objectPath, generation, retention})
+	fake.recordInvocation("SetObjectRetention", []interface{}{bucketName, objectPath, generation, retention})
+	fake.setObjectRetentionMutex.Unlock()
+	if fake.SetObjectRetentionStub != nil {
+		return fake.SetObjectRetentionStub(bucketName, objectPath, generation, retention)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.setObjectRetentionReturns.result1, fake.setObjectRetentionReturns.result2
+}
+
+func (fake *FakeGCSClient) SetObjectRetentionCallCount() int {
+	fake.setObjectRetentionMutex.RLock()
+	defer fake.setObjectRetentionMutex.RUnlock()
+	return len(fake.setObjectRetentionArgsForCall)
+}
+
+func (fake *FakeGCSClient) SetObjectRetentionArgsForCall(i int) (string, string, int64, gcsresource.ObjectRetention) {
+	fake.setObjectRetentionMutex.RLock()
+	defer fake.setObjectRetentionMutex.RUnlock()
+	return fake.setObjectRetentionArgsForCall[i].bucketName, fake.setObjectRetentionArgsForCall[i].objectPath, fake.setObjectRetentionArgsForCall[i].generation, fake.setObjectRetentionArgsForCall[i].retention
+}
+
+func (fake *FakeGCSClient) SetObjectRetentionReturns(result1 gcsresource.ObjectRetention, result2 error) {
+	fake.SetObjectRetentionStub = nil
+	fake.setObjectRetentionReturns = struct {
+		result1 gcsresource.ObjectRetention
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) SetObjectRetentionReturnsOnCall(i int, result1 gcsresource.ObjectRetention, result2 error) {
+	fake.SetObjectRetentionStub = nil
+	if fake.setObjectRetentionReturnsOnCall == nil {
+		fake.setObjectRetentionReturnsOnCall = make(map[int]struct {
+			result1 gcsresource.ObjectRetention
+			result2 error
+		})
+	}
+	fake.setObjectRetentionReturnsOnCall[i] = struct {
+		result1 gcsresource.ObjectRetention
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) SignedURL(bucketName string, objectPath string, generation int64, ttl time.Duration) (string, error) {
+	fake.signedURLMutex.Lock()
+	ret, specificReturn := fake.signedURLReturnsOnCall[len(fake.signedURLArgsForCall)]
+	fake.signedURLArgsForCall = append(fake.signedURLArgsForCall, struct {
+		bucketName string
+		objectPath string
+		generation int64
+		ttl        time.Duration
+	}{bucketName, objectPath, generation, ttl})
+	fake.recordInvocation("SignedURL", []interface{}{bucketName, objectPath, generation, ttl})
+	fake.signedURLMutex.Unlock()
+	if fake.SignedURLStub != nil {
+		return fake.SignedURLStub(bucketName, objectPath, generation, ttl)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.signedURLReturns.result1, fake.signedURLReturns.result2
+}
+
+func (fake *FakeGCSClient) SignedURLCallCount() int {
+	fake.signedURLMutex.RLock()
+	defer fake.signedURLMutex.RUnlock()
+	return len(fake.signedURLArgsForCall)
+}
+
+func (fake *FakeGCSClient) SignedURLArgsForCall(i int) (string, string, int64, time.Duration) {
+	fake.signedURLMutex.RLock()
+	defer fake.signedURLMutex.RUnlock()
+	return fake.signedURLArgsForCall[i].bucketName, fake.signedURLArgsForCall[i].objectPath, fake.signedURLArgsForCall[i].generation, fake.signedURLArgsForCall[i].ttl
+}
+
+func (fake *FakeGCSClient) SignedURLReturns(result1 string, result2 error) {
+	fake.SignedURLStub = nil
+	fake.signedURLReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGCSClient) SignedURLReturnsOnCall(i int, result1 string, result2 error) {
+	fake.SignedURLStub = nil
+	if fake.signedURLReturnsOnCall == nil {
+		fake.signedURLReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.signedURLReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeGCSClient) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.bucketObjectsMutex.RLock()
 	defer fake.bucketObjectsMutex.RUnlock()
+	fake.queryBucketObjectsMutex.RLock()
+	defer fake.queryBucketObjectsMutex.RUnlock()
+	fake.bucketObjectsInfoMutex.RLock()
+	defer fake.bucketObjectsInfoMutex.RUnlock()
+	fake.bucketPrefixesMutex.RLock()
+	defer fake.bucketPrefixesMutex.RUnlock()
 	fake.objectGenerationsMutex.RLock()
 	defer fake.objectGenerationsMutex.RUnlock()
+	fake.objectGenerationsInfoMutex.RLock()
+	defer fake.objectGenerationsInfoMutex.RUnlock()
 	fake.downloadFileMutex.RLock()
 	defer fake.downloadFileMutex.RUnlock()
+	fake.downloadStreamMutex.RLock()
+	defer fake.downloadStreamMutex.RUnlock()
 	fake.uploadFileMutex.RLock()
 	defer fake.uploadFileMutex.RUnlock()
+	fake.uploadFileIfGenerationMatchMutex.RLock()
+	defer fake.uploadFileIfGenerationMatchMutex.RUnlock()
+	fake.copyObjectMutex.RLock()
+	defer fake.copyObjectMutex.RUnlock()
 	fake.uRLMutex.RLock()
 	defer fake.uRLMutex.RUnlock()
 	fake.deleteObjectMutex.RLock()
 	defer fake.deleteObjectMutex.RUnlock()
 	fake.getBucketObjectInfoMutex.RLock()
 	defer fake.getBucketObjectInfoMutex.RUnlock()
+	fake.objectInfoMutex.RLock()
+	defer fake.objectInfoMutex.RUnlock()
+	fake.setObjectHoldsMutex.RLock()
+	defer fake.setObjectHoldsMutex.RUnlock()
+	fake.setObjectRetentionMutex.RLock()
+	defer fake.setObjectRetentionMutex.RUnlock()
+	fake.signedURLMutex.RLock()
+	defer fake.signedURLMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
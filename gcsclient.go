@@ -1,12 +1,16 @@
 package gcsresource
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"time"
 
 	"golang.org/x/oauth2"
 	oauthgoogle "golang.org/x/oauth2/google"
@@ -18,17 +22,38 @@ import (
 //go:generate counterfeiter -o fakes/fake_gcsclient.go . GCSClient
 type GCSClient interface {
 	BucketObjects(bucketName string, prefix string) ([]string, error)
+	QueryBucketObjects(bucketName string, query ObjectQuery) ([]string, error)
+	BucketObjectsInfo(bucketName string, query ObjectQuery) ([]*storage.Object, error)
+	BucketPrefixes(bucketName string, prefix string) ([]string, error)
 	ObjectGenerations(bucketName string, objectPath string) ([]int64, error)
+	ObjectGenerationsInfo(bucketName string, objectPath string) ([]*storage.Object, error)
 	DownloadFile(bucketName string, objectPath string, generation int64, localPath string) error
-	UploadFile(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int) (int64, error)
+	DownloadStream(bucketName string, objectPath string, generation int64) (io.ReadCloser, error)
+	UploadFile(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int) (int64, *storage.Object, error)
+	UploadFileIfGenerationMatch(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int, ifGenerationMatch int64) (int64, *storage.Object, error)
+	CopyObject(sourceBucketName string, sourceObjectPath string, sourceGeneration int64, bucketName string, objectPath string, predefinedACL string) (int64, *storage.Object, error)
 	URL(bucketName string, objectPath string, generation int64) (string, error)
 	DeleteObject(bucketName string, objectPath string, generation int64) error
 	GetBucketObjectInfo(bucketName, objectPath string) (*storage.Object, error)
+	ObjectInfo(bucketName string, objectPath string, generation int64) (*storage.Object, error)
+	SetObjectHolds(bucketName string, objectPath string, generation int64, holds ObjectHolds) (*storage.Object, error)
+	SetObjectRetention(bucketName string, objectPath string, generation int64, retention ObjectRetention) (ObjectRetention, error)
+	SignedURL(bucketName string, objectPath string, generation int64, ttl time.Duration) (string, error)
 }
 
+// ErrPreconditionFailed is returned by UploadFileIfGenerationMatch when the
+// live generation of the object does not match.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ErrObjectNotExist is returned by GetBucketObjectInfo when there is no such
+// object.
+var ErrObjectNotExist = errors.New("object does not exist")
+
 type gcsclient struct {
 	storageService *storage.Service
+	storageClient  *http.Client
 	progressOutput io.Writer
+	jsonKey        string
 }
 
 func NewGCSClient(
@@ -60,12 +85,18 @@ func NewGCSClient(
 
 	return &gcsclient{
 		storageService: storageService,
+		storageClient:  storageClient,
 		progressOutput: progressOutput,
+		jsonKey:        jsonKey,
 	}, nil
 }
 
 func (gcsclient *gcsclient) BucketObjects(bucketName string, prefix string) ([]string, error) {
-	bucketObjects, err := gcsclient.getBucketObjects(bucketName, prefix)
+	return gcsclient.QueryBucketObjects(bucketName, ObjectQuery{Prefix: prefix})
+}
+
+func (gcsclient *gcsclient) QueryBucketObjects(bucketName string, query ObjectQuery) ([]string, error) {
+	bucketObjects, err := gcsclient.getBucketObjects(bucketName, query)
 	if err != nil {
 		return []string{}, err
 	}
@@ -73,34 +104,80 @@ func (gcsclient *gcsclient) BucketObjects(bucketName string, prefix string) ([]s
 	return bucketObjects, nil
 }
 
+func (gcsclient *gcsclient) BucketObjectsInfo(bucketName string, query ObjectQuery) ([]*storage.Object, error) {
+	bucketObjects, err := gcsclient.getBucketObjectsInfo(bucketName, query)
+	if err != nil {
+		return []*storage.Object{}, err
+	}
+
+	return bucketObjects, nil
+}
+
+func (gcsclient *gcsclient) BucketPrefixes(bucketName string, prefix string) ([]string, error) {
+	bucketPrefixes, err := gcsclient.getBucketPrefixes(bucketName, prefix)
+	if err != nil {
+		return []string{}, err
+	}
+
+	return bucketPrefixes, nil
+}
+
 func (gcsclient *gcsclient) ObjectGenerations(bucketName string, objectPath string) ([]int64, error) {
-	isBucketVersioned, err := gcsclient.getBucketVersioning(bucketName)
+	objects, err := gcsclient.ObjectGenerationsInfo(bucketName, objectPath)
 	if err != nil {
 		return []int64{}, err
 	}
 
+	objectGenerations := make([]int64, 0, len(objects))
+	for _, object := range objects {
+		objectGenerations = append(objectGenerations, object.Generation)
+	}
+
+	return objectGenerations, nil
+}
+
+func (gcsclient *gcsclient) ObjectGenerationsInfo(bucketName string, objectPath string) ([]*storage.Object, error) {
+	isBucketVersioned, err := gcsclient.getBucketVersioning(bucketName)
+	if err != nil {
+		return []*storage.Object{}, err
+	}
+
 	if !isBucketVersioned {
-		return []int64{}, errors.New("bucket is not versioned")
+		return []*storage.Object{}, errors.New("bucket is not versioned")
 	}
 
 	objectGenerations, err := gcsclient.getObjectGenerations(bucketName, objectPath)
 	if err != nil {
-		return []int64{}, err
+		return []*storage.Object{}, err
 	}
 
 	return objectGenerations, nil
 }
 
 func (gcsclient *gcsclient) DownloadFile(bucketName string, objectPath string, generation int64, localPath string) error {
-	isBucketVersioned, err := gcsclient.getBucketVersioning(bucketName)
+	localFile, err := os.Create(localPath)
 	if err != nil {
 		return err
 	}
+	defer localFile.Close()
 
-	if !isBucketVersioned && generation != 0 {
-		return errors.New("bucket is not versioned")
+	reader, err := gcsclient.DownloadStream(bucketName, objectPath, generation)
+	if err != nil {
+		return err
 	}
+	defer reader.Close()
 
+	_, err = io.Copy(localFile, reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DownloadStream returns the content of an object. Its checksums are
+// verified when the end of the content is read.
+func (gcsclient *gcsclient) DownloadStream(bucketName string, objectPath string, generation int64) (io.ReadCloser, error) {
 	getCall := gcsclient.storageService.Objects.Get(bucketName, objectPath)
 	if generation != 0 {
 		getCall = getCall.Generation(generation)
@@ -108,43 +185,56 @@ func (gcsclient *gcsclient) DownloadFile(bucketName string, objectPath string, g
 
 	object, err := getCall.Do()
 	if err != nil {
-		return err
+		return nil, gcsclient.generationError(bucketName, objectPath, generation, err)
 	}
 
-	localFile, err := os.Create(localPath)
+	response, err := getCall.Download()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer localFile.Close()
 
 	progress := gcsclient.newProgressBar(int64(object.Size))
 	progress.Start()
-	defer progress.Finish()
 
-	response, err := getCall.Download()
-	if err != nil {
-		return err
-	}
-	defer response.Body.Close()
+	return &progressReadCloser{
+		Reader:   progress.NewProxyReader(newChecksumReader(response, object)),
+		closer:   response.Body,
+		progress: progress,
+	}, nil
+}
 
-	reader := progress.NewProxyReader(response.Body)
-	_, err = io.Copy(localFile, reader)
-	if err != nil {
-		return err
-	}
+type progressReadCloser struct {
+	io.Reader
+	closer   io.Closer
+	progress *pb.ProgressBar
+}
 
-	return nil
+func (reader *progressReadCloser) Close() error {
+	reader.progress.Finish()
+	return reader.closer.Close()
+}
+
+// UploadFile uploads a file and returns the generation of the object, 0 in
+// an unversioned bucket, along with the object resource.
+func (gcsclient *gcsclient) UploadFile(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int) (int64, *storage.Object, error) {
+	return gcsclient.uploadFile(bucketName, objectPath, objectContentType, localPath, predefinedACL, cacheControl, parallelUploadThreshold, nil)
+}
+
+// UploadFileIfGenerationMatch uploads a file only if the live generation of
+// the object is ifGenerationMatch, where 0 means there is no live object.
+func (gcsclient *gcsclient) UploadFileIfGenerationMatch(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int, ifGenerationMatch int64) (int64, *storage.Object, error) {
+	return gcsclient.uploadFile(bucketName, objectPath, objectContentType, localPath, predefinedACL, cacheControl, parallelUploadThreshold, &ifGenerationMatch)
 }
 
-func (gcsclient *gcsclient) UploadFile(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int) (int64, error) {
+func (gcsclient *gcsclient) uploadFile(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int, ifGenerationMatch *int64) (int64, *storage.Object, error) {
 	isBucketVersioned, err := gcsclient.getBucketVersioning(bucketName)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	stat, err := os.Stat(localPath)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 	fileSize := stat.Size()
 	parallelMode := false
@@ -173,7 +263,7 @@ func (gcsclient *gcsclient) UploadFile(bucketName string, objectPath string, obj
 			localFile, err := os.Open(localPath)
 			//TODO: close the files
 			if err != nil {
-				return 0, err
+				return 0, nil, err
 			}
 			localFile.Seek(trunkSize*i, 0)
 			if i == threads-1 {
@@ -203,7 +293,7 @@ func (gcsclient *gcsclient) UploadFile(bucketName string, objectPath string, obj
 		for i := int64(0); i < threads; i++ {
 			err = <-errChannel
 			if err != nil {
-				return 0, err
+				return 0, nil, err
 			}
 		}
 
@@ -213,10 +303,10 @@ func (gcsclient *gcsclient) UploadFile(bucketName string, objectPath string, obj
 			SourceObjects: sourceObjects,
 		}
 		composeCall := gcsclient.storageService.Objects.Compose(bucketName, objectPath, composeReqest)
-		_, err = composeCall.Do()
-		if err != nil {
-			return 0, err
+		if ifGenerationMatch != nil {
+			composeCall = composeCall.IfGenerationMatch(*ifGenerationMatch)
 		}
+		composedObject, composeErr := composeCall.Do()
 
 		fmt.Fprintf(os.Stderr, "Cleanup...\n")
 		for i := int64(0); i < threads; i++ {
@@ -226,11 +316,15 @@ func (gcsclient *gcsclient) UploadFile(bucketName string, objectPath string, obj
 				fmt.Fprintf(os.Stderr, "Warning: Failed to delete file %s: %v\n", partName, err)
 			}
 		}
-		return 0, nil
+
+		if composeErr != nil {
+			return 0, nil, preconditionError(composeErr)
+		}
+		return 0, composedObject, nil
 	} else { //parallelMode  disabled
 		localFile, err := os.Open(localPath)
 		if err != nil {
-			return 0, err
+			return 0, nil, err
 		}
 		defer localFile.Close()
 
@@ -244,17 +338,20 @@ func (gcsclient *gcsclient) UploadFile(bucketName string, objectPath string, obj
 		if predefinedACL != "" {
 			insertCall = insertCall.PredefinedAcl(predefinedACL)
 		}
+		if ifGenerationMatch != nil {
+			insertCall = insertCall.IfGenerationMatch(*ifGenerationMatch)
+		}
 
 		uploadedObject, err := insertCall.Do()
 		if err != nil {
-			return 0, err
+			return 0, nil, preconditionError(err)
 		}
 
 		if isBucketVersioned {
-			return uploadedObject.Generation, nil
+			return uploadedObject.Generation, uploadedObject, nil
 		}
 
-		return 0, nil
+		return 0, uploadedObject, nil
 	}
 }
 
@@ -273,6 +370,82 @@ func (gcsclient *gcsclient) planParallelUpload(fileSize int64, trunkSize int64)
 	return threads, trunkSize
 }
 
+// CopyObject copies an object server-side, keeping its metadata. Large
+// objects and copies across locations or storage classes take several
+// rewrite calls, continued with the returned rewrite token. Like UploadFile,
+// it returns the generation of the copy and its object resource.
+func (gcsclient *gcsclient) CopyObject(sourceBucketName string, sourceObjectPath string, sourceGeneration int64, bucketName string, objectPath string, predefinedACL string) (int64, *storage.Object, error) {
+	isBucketVersioned, err := gcsclient.getBucketVersioning(bucketName)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rewriteToken := ""
+	for {
+		// an empty object keeps the metadata of the source
+		rewriteCall := gcsclient.storageService.Objects.Rewrite(sourceBucketName, sourceObjectPath, bucketName, objectPath, &storage.Object{})
+		if sourceGeneration != 0 {
+			rewriteCall = rewriteCall.SourceGeneration(sourceGeneration)
+		}
+		if predefinedACL != "" {
+			rewriteCall = rewriteCall.DestinationPredefinedAcl(predefinedACL)
+		}
+		if rewriteToken != "" {
+			rewriteCall = rewriteCall.RewriteToken(rewriteToken)
+		}
+
+		response, err := rewriteCall.Do()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if response.Done {
+			if isBucketVersioned {
+				return response.Resource.Generation, response.Resource, nil
+			}
+
+			return 0, response.Resource, nil
+		}
+
+		fmt.Fprintf(os.Stderr, "Copied %d of %d bytes...\n", response.TotalBytesRewritten, response.ObjectSize)
+		rewriteToken = response.RewriteToken
+	}
+}
+
+// preconditionError translates a failed precondition to
+// ErrPreconditionFailed.
+func preconditionError(err error) error {
+	if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == http.StatusPreconditionFailed {
+		return ErrPreconditionFailed
+	}
+
+	return err
+}
+
+// notFoundError translates a missing object to ErrObjectNotExist.
+func notFoundError(err error) error {
+	if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == http.StatusNotFound {
+		return ErrObjectNotExist
+	}
+
+	return err
+}
+
+// generationError reports a missing generation in an unversioned bucket,
+// where only the live generation of an object can be found.
+func (gcsclient *gcsclient) generationError(bucketName string, objectPath string, generation int64, err error) error {
+	if apiErr, ok := err.(*googleapi.Error); !ok || apiErr.Code != http.StatusNotFound || generation == 0 {
+		return err
+	}
+
+	isBucketVersioned, versioningErr := gcsclient.getBucketVersioning(bucketName)
+	if versioningErr != nil || isBucketVersioned {
+		return err
+	}
+
+	return fmt.Errorf("bucket is not versioned: generation %d of '%s' is not the live object", generation, objectPath)
+}
+
 func (gcsclient *gcsclient) URL(bucketName string, objectPath string, generation int64) (string, error) {
 	getCall := gcsclient.storageService.Objects.Get(bucketName, objectPath)
 	if generation != 0 {
@@ -312,23 +485,113 @@ func (gcsclient *gcsclient) GetBucketObjectInfo(bucketName, objectPath string) (
 	getCall := gcsclient.storageService.Objects.Get(bucketName, objectPath)
 	object, err := getCall.Do()
 	if err != nil {
-		return nil, err
+		return nil, notFoundError(err)
 	}
 
 	return object, nil
 }
 
-func (gcsclient *gcsclient) getBucketObjects(bucketName string, prefix string) ([]string, error) {
+// ObjectInfo returns the object at the given generation, or the live object
+// for generation 0.
+func (gcsclient *gcsclient) ObjectInfo(bucketName string, objectPath string, generation int64) (*storage.Object, error) {
+	getCall := gcsclient.storageService.Objects.Get(bucketName, objectPath)
+	if generation != 0 {
+		getCall = getCall.Generation(generation)
+	}
+
+	object, err := getCall.Do()
+	if err != nil {
+		return nil, gcsclient.generationError(bucketName, objectPath, generation, err)
+	}
+
+	return object, nil
+}
+
+// SetObjectHolds places or releases the holds set in holds, leaving the
+// others as they are.
+func (gcsclient *gcsclient) SetObjectHolds(bucketName string, objectPath string, generation int64, holds ObjectHolds) (*storage.Object, error) {
+	object := &storage.Object{}
+	if holds.TemporaryHold != nil {
+		object.TemporaryHold = *holds.TemporaryHold
+		object.ForceSendFields = append(object.ForceSendFields, "TemporaryHold")
+	}
+	if holds.EventBasedHold != nil {
+		object.EventBasedHold = *holds.EventBasedHold
+		object.ForceSendFields = append(object.ForceSendFields, "EventBasedHold")
+	}
+
+	patchCall := gcsclient.storageService.Objects.Patch(bucketName, objectPath, object)
+	if generation != 0 {
+		patchCall = patchCall.Generation(generation)
+	}
+
+	return patchCall.Do()
+}
+
+// SetObjectRetention sets the retention configuration of an object. The
+// vendored storage API predates object retention, so the patch is sent the
+// way the generated Objects.Patch call sends it, with the field added.
+func (gcsclient *gcsclient) SetObjectRetention(bucketName string, objectPath string, generation int64, retention ObjectRetention) (ObjectRetention, error) {
+	body, err := json.Marshal(objectRetentionPatch{Retention: retention})
+	if err != nil {
+		return ObjectRetention{}, err
+	}
+
+	params := url.Values{}
+	params.Set("alt", "json")
+	params.Set("fields", "retention")
+	if generation != 0 {
+		params.Set("generation", strconv.FormatInt(generation, 10))
+	}
+
+	urls := googleapi.ResolveRelative(gcsclient.storageService.BasePath, "b/{bucket}/o/{object}") + "?" + params.Encode()
+	request, err := http.NewRequest("PATCH", urls, bytes.NewReader(body))
+	if err != nil {
+		return ObjectRetention{}, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", gcsclient.storageService.UserAgent)
+	googleapi.Expand(request.URL, map[string]string{
+		"bucket": bucketName,
+		"object": objectPath,
+	})
+
+	response, err := gcsclient.storageClient.Do(request)
+	if err != nil {
+		return ObjectRetention{}, err
+	}
+	defer googleapi.CloseBody(response)
+
+	if err := googleapi.CheckResponse(response); err != nil {
+		return ObjectRetention{}, err
+	}
+
+	var patched objectRetentionPatch
+	if err := json.NewDecoder(response.Body).Decode(&patched); err != nil {
+		return ObjectRetention{}, err
+	}
+
+	return patched.Retention, nil
+}
+
+// objectRetentionPatch is the body of a retention patch and the fields of
+// its response.
+type objectRetentionPatch struct {
+	Retention ObjectRetention `json:"retention"`
+}
+
+func (gcsclient *gcsclient) getBucketObjects(bucketName string, query ObjectQuery) ([]string, error) {
 	var bucketObjects []string
 
 	pageToken := ""
 	for {
 		listCall := gcsclient.storageService.Objects.List(bucketName)
 		listCall = listCall.PageToken(pageToken)
-		listCall = listCall.Prefix(prefix)
+		listCall = listCall.Prefix(query.Prefix)
 		listCall = listCall.Versions(false)
+		listCall = listCall.Fields("items(name)", "nextPageToken")
 
-		objects, err := listCall.Do()
+		objects, err := listCall.Do(queryOptions(query)...)
 		if err != nil {
 			return bucketObjects, err
 		}
@@ -347,6 +610,86 @@ func (gcsclient *gcsclient) getBucketObjects(bucketName string, prefix string) (
 	return bucketObjects, nil
 }
 
+func (gcsclient *gcsclient) getBucketObjectsInfo(bucketName string, query ObjectQuery) ([]*storage.Object, error) {
+	var bucketObjects []*storage.Object
+
+	pageToken := ""
+	for {
+		listCall := gcsclient.storageService.Objects.List(bucketName)
+		listCall = listCall.PageToken(pageToken)
+		listCall = listCall.Prefix(query.Prefix)
+		listCall = listCall.Versions(false)
+
+		objects, err := listCall.Do(queryOptions(query)...)
+		if err != nil {
+			return bucketObjects, err
+		}
+
+		bucketObjects = append(bucketObjects, objects.Items...)
+
+		if objects.NextPageToken != "" {
+			pageToken = objects.NextPageToken
+		} else {
+			break
+		}
+	}
+
+	return bucketObjects, nil
+}
+
+func (gcsclient *gcsclient) getBucketPrefixes(bucketName string, prefix string) ([]string, error) {
+	var bucketPrefixes []string
+
+	pageToken := ""
+	for {
+		listCall := gcsclient.storageService.Objects.List(bucketName)
+		listCall = listCall.PageToken(pageToken)
+		listCall = listCall.Prefix(prefix)
+		listCall = listCall.Delimiter("/")
+		listCall = listCall.Versions(false)
+
+		objects, err := listCall.Do()
+		if err != nil {
+			return bucketPrefixes, err
+		}
+
+		bucketPrefixes = append(bucketPrefixes, objects.Prefixes...)
+
+		if objects.NextPageToken != "" {
+			pageToken = objects.NextPageToken
+		} else {
+			break
+		}
+	}
+
+	return bucketPrefixes, nil
+}
+
+// queryParameter sets list parameters the generated storage client does not
+// know about yet.
+type queryParameter struct {
+	key   string
+	value string
+}
+
+func (parameter queryParameter) Get() (string, string) {
+	return parameter.key, parameter.value
+}
+
+func queryOptions(query ObjectQuery) []googleapi.CallOption {
+	var options []googleapi.CallOption
+
+	if query.StartOffset != "" {
+		options = append(options, queryParameter{"startOffset", query.StartOffset})
+	}
+
+	if query.MatchGlob != "" {
+		options = append(options, queryParameter{"matchGlob", query.MatchGlob})
+	}
+
+	return options
+}
+
 func (gcsclient *gcsclient) getBucketVersioning(bucketName string) (bool, error) {
 	bucket, err := gcsclient.storageService.Buckets.Get(bucketName).Do()
 	if err != nil {
@@ -360,8 +703,8 @@ func (gcsclient *gcsclient) getBucketVersioning(bucketName string) (bool, error)
 	return false, nil
 }
 
-func (gcsclient *gcsclient) getObjectGenerations(bucketName string, objectPath string) ([]int64, error) {
-	var objectGenerations []int64
+func (gcsclient *gcsclient) getObjectGenerations(bucketName string, objectPath string) ([]*storage.Object, error) {
+	var objectGenerations []*storage.Object
 
 	pageToken := ""
 	for {
@@ -377,7 +720,7 @@ func (gcsclient *gcsclient) getObjectGenerations(bucketName string, objectPath s
 
 		for _, object := range objects.Items {
 			if object.Name == objectPath {
-				objectGenerations = append(objectGenerations, object.Generation)
+				objectGenerations = append(objectGenerations, object)
 			}
 		}
 
@@ -0,0 +1,37 @@
+package gcsresource
+
+import (
+	storage "google.golang.org/api/storage/v1"
+)
+
+// HoldsMetadata reports the holds placed on an object and the time its
+// retention expires, if any.
+func HoldsMetadata(object *storage.Object) []MetadataPair {
+	var metadata []MetadataPair
+
+	if object.TemporaryHold {
+		metadata = append(metadata, MetadataPair{Name: "temporary_hold", Value: "true"})
+	}
+
+	if object.EventBasedHold {
+		metadata = append(metadata, MetadataPair{Name: "event_based_hold", Value: "true"})
+	}
+
+	if object.RetentionExpirationTime != "" {
+		metadata = append(metadata, MetadataPair{Name: "retention_expiration_time", Value: object.RetentionExpirationTime})
+	}
+
+	return metadata
+}
+
+// RetentionMetadata reports the retention configuration of an object.
+func RetentionMetadata(retention ObjectRetention) []MetadataPair {
+	if retention.Mode == "" {
+		return nil
+	}
+
+	return []MetadataPair{
+		{Name: "retention_mode", Value: retention.Mode},
+		{Name: "retain_until_time", Value: retention.RetainUntilTime},
+	}
+}
@@ -1,26 +1,59 @@
 package in
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bufio"
 	"compress/gzip"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 
+	"github.com/dsnet/compress/bzip2"
 	"github.com/h2non/filetype"
+	"github.com/klauspost/compress/zstd"
+	"github.com/nwaples/rardecode"
+	"github.com/ulikunitz/xz"
 )
 
 const (
-	mimeTypeZip  = "application/zip"
-	mimeTypeTar  = "application/x-tar"
-	mimeTypeGzip = "application/gzip"
+	mimeTypeZip   = "application/zip"
+	mimeTypeTar   = "application/x-tar"
+	mimeTypeRar   = "application/x-rar-compressed"
+	mimeType7z    = "application/x-7z-compressed"
+	mimeTypeGzip  = "application/gzip"
+	mimeTypeBzip2 = "application/x-bzip2"
+	mimeTypeXz    = "application/x-xz"
+	mimeTypeZstd  = "application/zstd"
 )
 
+func init() {
+	// filetype does not know zstd frames yet
+	filetype.NewMatcher(filetype.NewType("zst", mimeTypeZstd), func(buf []byte) bool {
+		return len(buf) > 3 &&
+			buf[0] == 0x28 && buf[1] == 0xB5 && buf[2] == 0x2F && buf[3] == 0xFD
+	})
+}
+
 func isSupportedMimeType(mimeType string) bool {
 	return mimeType == mimeTypeZip ||
 		mimeType == mimeTypeTar ||
-		mimeType == mimeTypeGzip
+		mimeType == mimeTypeRar ||
+		mimeType == mimeType7z ||
+		isCompressedMimeType(mimeType)
+}
+
+// isCompressedMimeType reports whether mimeType is a single compressed
+// stream, which may wrap another archive.
+func isCompressedMimeType(mimeType string) bool {
+	return mimeType == mimeTypeGzip ||
+		mimeType == mimeTypeBzip2 ||
+		mimeType == mimeTypeXz ||
+		mimeType == mimeTypeZstd
 }
 
 func getMimeType(path string) (string, error) {
@@ -30,7 +63,12 @@ func getMimeType(path string) (string, error) {
 	}
 	defer f.Close()
 
-	bs, err := bufio.NewReader(f).Peek(512)
+	return peekMimeType(bufio.NewReader(f))
+}
+
+// peekMimeType detects the MIME type of a stream without consuming it.
+func peekMimeType(reader *bufio.Reader) (string, error) {
+	bs, err := reader.Peek(512)
 	if err != nil && err != io.EOF {
 		return "", err
 	}
@@ -43,13 +81,35 @@ func getMimeType(path string) (string, error) {
 	return kind.MIME.Value, nil
 }
 
-func unpack(mimeType, sourcePath string) error {
-	for mimeType == mimeTypeGzip {
+type unpackOptions struct {
+	// directory the archive entries are extracted to
+	destinationDir string
+
+	// number of leading path components removed from entry names
+	stripComponents int
+
+	// glob patterns selecting entries, matched after stripping components
+	include []string
+	exclude []string
+
+	// keep the downloaded archive and decompressed intermediates
+	keepArchive bool
+
+	// restore the owner of tar entries, only effective as root
+	preserveOwnership bool
+}
+
+func unpack(mimeType, sourcePath string, options unpackOptions) error {
+	archivePath := sourcePath
+	intermediates := []string{}
+
+	for isCompressedMimeType(mimeType) {
 		var err error
-		sourcePath, err = unpackGzip(sourcePath)
+		sourcePath, err = decompress(mimeType, sourcePath)
 		if err != nil {
 			return err
 		}
+		intermediates = append(intermediates, sourcePath)
 
 		mimeType, err = getMimeType(sourcePath)
 		if err != nil {
@@ -57,56 +117,450 @@ func unpack(mimeType, sourcePath string) error {
 		}
 	}
 
-	destinationDir := filepath.Dir(sourcePath)
+	if err := os.MkdirAll(options.destinationDir, 0755); err != nil {
+		return err
+	}
 
+	var err error
 	switch mimeType {
 	case mimeTypeZip:
-		return unpackZip(sourcePath, destinationDir)
+		err = unpackZip(sourcePath, options)
 	case mimeTypeTar:
-		return unpackTar(sourcePath, destinationDir)
+		err = unpackTar(sourcePath, options)
+	case mimeTypeRar:
+		err = unpackRar(sourcePath, options)
+	case mimeType7z:
+		err = unpack7z(sourcePath, options)
+	default:
+		// a single compressed file is the result itself
+		intermediates = intermediates[:len(intermediates)-1]
+		err = moveInto(sourcePath, decompressedName(sourcePath), options.destinationDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if options.keepArchive {
+		return nil
+	}
+
+	for _, path := range append([]string{archivePath}, intermediates...) {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func unpackZip(sourcePath, destinationDir string) error {
-	cmd := exec.Command("unzip", "-d", destinationDir, sourcePath)
+// decompressedName names a decompressed file after the compressed one when
+// the stream did not carry a name, e.g. 'file.txt' for 'file.txt.xz'.
+func decompressedName(path string) string {
+	name := filepath.Base(path)
+	if !strings.HasSuffix(name, ".uncompressed") {
+		return name
+	}
+
+	compressedName := strings.TrimSuffix(name, ".uncompressed")
+	switch extension := filepath.Ext(compressedName); extension {
+	case ".gz", ".bz2", ".xz", ".zst":
+		if trimmed := strings.TrimSuffix(compressedName, extension); trimmed != "" {
+			return trimmed
+		}
+	}
+
+	return name
+}
+
+func moveInto(path string, name string, destinationDir string) error {
+	destinationPath := filepath.Join(destinationDir, name)
+	if destinationPath == path {
+		return nil
+	}
+
+	if err := removeExisting(destinationPath); err != nil {
+		return err
+	}
 
-	return cmd.Run()
+	return os.Rename(path, destinationPath)
 }
 
-func unpackGzip(sourcePath string) (string, error) {
+// moveTree moves the entries of sourceDir into destinationDir, merging them
+// into the directories which already exist there. Existing files and
+// symlinks are replaced, the way extracting over them does.
+func moveTree(sourceDir string, destinationDir string) error {
+	entries, err := ioutil.ReadDir(sourceDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		destinationPath := filepath.Join(destinationDir, entry.Name())
+
+		existing, err := os.Lstat(destinationPath)
+		if err == nil && existing.IsDir() && entry.IsDir() {
+			if err := moveTree(filepath.Join(sourceDir, entry.Name()), destinationPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := moveInto(filepath.Join(sourceDir, entry.Name()), entry.Name(), destinationDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decompress writes the decompressed stream next to sourcePath, using the
+// original file name when the stream carries a plain one.
+func decompress(mimeType, sourcePath string) (string, error) {
 	reader, err := os.Open(sourcePath)
 	if err != nil {
 		return "", err
 	}
 	defer reader.Close()
 
-	archive, err := gzip.NewReader(reader)
+	stream, name, err := decompressor(mimeType, reader)
 	if err != nil {
 		return "", err
 	}
-	defer archive.Close()
+	defer stream.Close()
+
+	destinationPath := sourcePath + ".uncompressed"
+	if name != "" && filepath.Join(filepath.Dir(sourcePath), name) != sourcePath {
+		destinationPath = filepath.Join(filepath.Dir(sourcePath), name)
+	}
 
-	var destinationPath string
-	if archive.Name != "" {
-		destinationPath = filepath.Join(filepath.Dir(sourcePath), archive.Name)
-	} else {
-		destinationPath = sourcePath + ".uncompressed"
+	if err := removeExisting(destinationPath); err != nil {
+		return "", err
 	}
 
-	writer, err := os.Create(destinationPath)
+	writer, err := os.OpenFile(destinationPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
 	if err != nil {
 		return "", err
 	}
 	defer writer.Close()
 
-	_, err = io.Copy(writer, archive)
+	_, err = io.Copy(writer, stream)
 	return destinationPath, err
 }
 
-func unpackTar(sourcePath, destinationDir string) error {
-	cmd := exec.Command("tar", "xf", sourcePath, "-C", destinationDir)
+// decompressor wraps reader with the decompression for mimeType. It also
+// returns the original file name if the stream carries one.
+func decompressor(mimeType string, reader io.Reader) (io.ReadCloser, string, error) {
+	var (
+		stream io.ReadCloser
+		name   string
+	)
+
+	switch mimeType {
+	case mimeTypeGzip:
+		archive, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, "", err
+		}
+
+		stream, name = archive, archive.Name
+	case mimeTypeBzip2:
+		archive, err := bzip2.NewReader(reader, nil)
+		if err != nil {
+			return nil, "", err
+		}
+
+		stream = archive
+	case mimeTypeXz:
+		archive, err := xz.NewReader(reader)
+		if err != nil {
+			return nil, "", err
+		}
+
+		stream = ioutil.NopCloser(archive)
+	case mimeTypeZstd:
+		archive, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, "", err
+		}
+
+		stream = archive.IOReadCloser()
+	default:
+		return nil, "", fmt.Errorf("unsupported compression %s", mimeType)
+	}
+
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		stream.Close()
+		return nil, "", fmt.Errorf("invalid file name '%s' in compression header", name)
+	}
+
+	return stream, name, nil
+}
+
+// unpackStream extracts an archive while it is being read, without keeping
+// a copy on disk. The stream is read to its end, so checksums covering the
+// whole stream are verified.
+func unpackStream(reader io.Reader, name string, options unpackOptions) error {
+	source := bufio.NewReader(reader)
+
+	stream := source
+	mimeType, err := peekMimeType(stream)
+	if err != nil {
+		return err
+	}
+
+	if !isSupportedMimeType(mimeType) {
+		return errors.New("unsupported MIME type " + mimeType)
+	}
+
+	var decompressors []io.ReadCloser
+	closeDecompressors := func() {
+		for i := len(decompressors) - 1; i >= 0; i-- {
+			decompressors[i].Close()
+		}
+		decompressors = nil
+	}
+	defer closeDecompressors()
+
+	for isCompressedMimeType(mimeType) {
+		decompressed, streamName, err := decompressor(mimeType, stream)
+		if err != nil {
+			return err
+		}
+		decompressors = append(decompressors, decompressed)
+
+		if streamName != "" {
+			name = streamName
+		} else {
+			name = decompressedName(name + ".uncompressed")
+		}
+
+		stream = bufio.NewReader(decompressed)
+		mimeType, err = peekMimeType(stream)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(options.destinationDir, 0755); err != nil {
+		return err
+	}
+
+	switch mimeType {
+	case mimeTypeTar:
+		err = extractTar(stream, options)
+	case mimeTypeRar:
+		err = extractRar(stream, options)
+	case mimeTypeZip, mimeType7z:
+		err = fmt.Errorf("%s archives can not be streamed", mimeType)
+	default:
+		err = writeFile(options.destinationDir, filepath.Join(options.destinationDir, name), stream, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the zstd decoder reads ahead from source in the background, so the
+	// decompressors are closed before the rest of source is drained
+	closeDecompressors()
+
+	_, err = io.Copy(ioutil.Discard, source)
+	return err
+}
+
+func unpackZip(sourcePath string, options unpackOptions) error {
+	archive, err := zip.OpenReader(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	extraction := newExtraction(options)
+	for _, file := range archive.File {
+		if err := extractZipEntry(file, extraction); err != nil {
+			return entryError(file.Name, err)
+		}
+	}
+
+	return extraction.finish()
+}
+
+func extractZipEntry(file *zip.File, extraction *extraction) error {
+	path, ok, err := extraction.entryPath(file.Name)
+	if err != nil || !ok {
+		return err
+	}
+
+	mode := file.Mode()
+
+	if mode.IsDir() {
+		return extraction.dir(path, mode, file.Modified)
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if mode&os.ModeSymlink != 0 {
+		target, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+
+		return extraction.symlink(path, string(target))
+	}
+
+	return extraction.file(path, reader, mode, file.Modified)
+}
+
+func unpackTar(sourcePath string, options unpackOptions) error {
+	reader, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return extractTar(reader, options)
+}
+
+func extractTar(reader io.Reader, options unpackOptions) error {
+	extraction := newExtraction(options)
+
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return extraction.finish()
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := extractTarEntry(archive, header, extraction); err != nil {
+			return entryError(header.Name, err)
+		}
+	}
+}
+
+func extractTarEntry(archive *tar.Reader, header *tar.Header, extraction *extraction) error {
+	path, ok, err := extraction.entryPath(header.Name)
+	if err != nil || !ok {
+		return err
+	}
+
+	mode := header.FileInfo().Mode()
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		err = extraction.dir(path, mode, header.ModTime)
+	case tar.TypeReg:
+		err = extraction.file(path, archive, mode, header.ModTime)
+	case tar.TypeSymlink:
+		err = extraction.symlink(path, header.Linkname)
+	case tar.TypeLink:
+		target, linkErr := extraction.linkPath(header.Linkname)
+		if linkErr != nil {
+			return fmt.Errorf("link target: %s", linkErr)
+		}
+
+		err = extraction.link(path, target)
+	default:
+		// devices and fifos are not extracted
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return extraction.chown(path, header.Uid, header.Gid)
+}
+
+func unpackRar(sourcePath string, options unpackOptions) error {
+	reader, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return extractRar(reader, options)
+}
+
+func extractRar(reader io.Reader, options unpackOptions) error {
+	archive, err := rardecode.NewReader(reader, "")
+	if err != nil {
+		return err
+	}
+
+	extraction := newExtraction(options)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return extraction.finish()
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := extractRarEntry(archive, header, extraction); err != nil {
+			return entryError(header.Name, err)
+		}
+	}
+}
+
+func extractRarEntry(archive *rardecode.Reader, header *rardecode.FileHeader, extraction *extraction) error {
+	path, ok, err := extraction.entryPath(header.Name)
+	if err != nil || !ok {
+		return err
+	}
+
+	if header.IsDir {
+		return extraction.dir(path, header.Mode(), header.ModificationTime)
+	}
+
+	return extraction.file(path, archive, header.Mode(), header.ModificationTime)
+}
+
+func unpack7z(sourcePath string, options unpackOptions) error {
+	archive, err := openSevenZip(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	extraction := newExtraction(options)
+	err = archive.walk(func(entry sevenZipEntry, reader io.Reader) error {
+		if err := extract7zEntry(entry, reader, extraction); err != nil {
+			return entryError(entry.name, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return extraction.finish()
+}
+
+func extract7zEntry(entry sevenZipEntry, reader io.Reader, extraction *extraction) error {
+	path, ok, err := extraction.entryPath(entry.name)
+	if err != nil || !ok {
+		return err
+	}
+
+	if entry.mode.IsDir() {
+		return extraction.dir(path, entry.mode, entry.modTime)
+	}
+
+	if entry.mode&os.ModeSymlink != 0 {
+		target, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+
+		return extraction.symlink(path, string(target))
+	}
 
-	return cmd.Run()
+	return extraction.file(path, reader, entry.mode, entry.modTime)
 }
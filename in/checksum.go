@@ -0,0 +1,79 @@
+package in
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	gcsresource "github.com/syslxg/gcs-resource"
+)
+
+// checksumSidecars finds the checksum sidecar objects next to an object, as
+// uploaded by out with params.checksum_files. Missing sidecars are skipped,
+// any other lookup error is returned.
+func (command *InCommand) checksumSidecars(bucketName string, objectPath string, algorithms []string) (map[string]relatedObject, error) {
+	sidecars := map[string]relatedObject{}
+
+	for _, algorithm := range algorithms {
+		sidecarPath := objectPath + "." + algorithm
+
+		info, err := command.gcsClient.GetBucketObjectInfo(bucketName, sidecarPath)
+		if err == gcsresource.ErrObjectNotExist {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to look up %s checksum file '%s': %s", algorithm, sidecarPath, err)
+		}
+
+		sidecars[algorithm] = relatedObject{kind: "checksum", path: sidecarPath, live: info}
+	}
+
+	return sidecars, nil
+}
+
+// checkChecksums computes the digests of a downloaded object, verifies them
+// against the downloaded sidecars and writes each to a file named after its
+// algorithm in the destination dir.
+func checkChecksums(localPath string, destinationDir string, algorithms []string, sidecars map[string]relatedObject) ([]gcsresource.MetadataPair, error) {
+	checksums, err := gcsresource.FileChecksums(localPath, algorithms)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, algorithm := range algorithms {
+		sidecar, ok := sidecars[algorithm]
+		if !ok {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(destinationDir, path.Base(sidecar.path)))
+		if err != nil {
+			return nil, err
+		}
+
+		// sidecars are in the format of sha256sum: the digest, then the file name
+		fields := strings.Fields(string(contents))
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%s checksum file '%s' is empty", algorithm, sidecar.path)
+		}
+
+		if !strings.EqualFold(fields[0], checksums[algorithm]) {
+			return nil, fmt.Errorf("%s checksum mismatch for %s: expected %s, got %s", algorithm, filepath.Base(localPath), fields[0], checksums[algorithm])
+		}
+	}
+
+	var metadata []gcsresource.MetadataPair
+	for _, algorithm := range algorithms {
+		if err := ioutil.WriteFile(filepath.Join(destinationDir, algorithm), []byte(checksums[algorithm]), 0644); err != nil {
+			return nil, err
+		}
+
+		metadata = append(metadata, gcsresource.MetadataPair{
+			Name:  algorithm,
+			Value: checksums[algorithm],
+		})
+	}
+
+	return metadata, nil
+}
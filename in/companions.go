@@ -0,0 +1,217 @@
+package in
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	gcsresource "github.com/syslxg/gcs-resource"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// relatedObject is an object fetched alongside the object of a version,
+// listed in params.companions or params.files.
+type relatedObject struct {
+	// the params list the object comes from, "companion" or "file"
+	kind string
+
+	path       string
+	generation int64
+
+	// the live object found when the related object was looked up
+	live *storage.Object
+}
+
+// objectTemplate is the data companion and file templates are rendered with.
+type objectTemplate struct {
+	Path    string
+	Dir     string
+	Name    string
+	Version string
+}
+
+func newObjectTemplate(objectPath string, version string) objectTemplate {
+	return objectTemplate{
+		Path:    objectPath,
+		Dir:     path.Dir(objectPath),
+		Name:    path.Base(objectPath),
+		Version: version,
+	}
+}
+
+func parseObjectTemplate(text string) (*template.Template, error) {
+	return template.New("object").Option("missingkey=error").Parse(text)
+}
+
+// relatedObjects resolves the companions and files of objectPath. Companions
+// without a template are suffixes of objectPath.
+func (params Params) relatedObjects(data objectTemplate) ([]relatedObject, error) {
+	var related []relatedObject
+
+	for _, companion := range params.Companions {
+		if !strings.Contains(companion, "{{") {
+			companion = "{{.Path}}" + companion
+		}
+
+		objectPath, err := renderObjectPath(companion, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid companion '%s': %s", companion, err)
+		}
+		related = append(related, relatedObject{kind: "companion", path: objectPath})
+	}
+
+	for _, file := range params.Files {
+		objectPath, err := renderObjectPath(file, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file '%s': %s", file, err)
+		}
+		related = append(related, relatedObject{kind: "file", path: objectPath})
+	}
+
+	localPaths := map[string]string{path.Base(data.Path): data.Path}
+	for _, object := range related {
+		name := path.Base(object.path)
+		if other, ok := localPaths[name]; ok {
+			return nil, fmt.Errorf("objects '%s' and '%s' would both be written to '%s'", other, object.path, name)
+		}
+		localPaths[name] = object.path
+	}
+
+	return related, nil
+}
+
+func containsObject(related []relatedObject, objectPath string) bool {
+	for _, object := range related {
+		if object.path == objectPath {
+			return true
+		}
+	}
+
+	return false
+}
+
+func renderObjectPath(text string, data objectTemplate) (string, error) {
+	tmpl, err := parseObjectTemplate(text)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+
+	objectPath := rendered.String()
+	if objectPath == "" || strings.HasSuffix(objectPath, "/") {
+		return "", fmt.Errorf("'%s' is not an object path", objectPath)
+	}
+
+	return objectPath, nil
+}
+
+// checkRelatedObjects makes sure every related object exists before anything
+// is downloaded.
+func (command *InCommand) checkRelatedObjects(bucketName string, related []relatedObject) error {
+	for i, object := range related {
+		info, err := command.gcsClient.GetBucketObjectInfo(bucketName, object.path)
+		if err != nil {
+			return fmt.Errorf("required %s '%s' could not be found: %s", object.kind, object.path, err)
+		}
+
+		related[i].live = info
+	}
+
+	return nil
+}
+
+// pinRelatedObjects pins every related object to its generation matching the
+// version object, the newest one created no later than it. Related objects
+// are uploaded before the object by out, so these are the ones it was
+// published with.
+func (command *InCommand) pinRelatedObjects(bucketName string, object *storage.Object, related []relatedObject) error {
+	created, err := time.Parse(time.RFC3339Nano, object.TimeCreated)
+	if err != nil {
+		return fmt.Errorf("invalid creation time of '%s': %s", object.Name, err)
+	}
+
+	for i := range related {
+		generation, err := command.relatedGeneration(bucketName, related[i], created)
+		if err != nil {
+			return err
+		}
+
+		related[i].generation = generation
+	}
+
+	return nil
+}
+
+func (command *InCommand) relatedGeneration(bucketName string, object relatedObject, created time.Time) (int64, error) {
+	liveCreated, err := time.Parse(time.RFC3339Nano, object.live.TimeCreated)
+	if err != nil {
+		return 0, fmt.Errorf("invalid creation time of '%s': %s", object.path, err)
+	}
+
+	if !liveCreated.After(created) {
+		return object.live.Generation, nil
+	}
+
+	generations, err := command.gcsClient.ObjectGenerationsInfo(bucketName, object.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list the generations of %s '%s': %s", object.kind, object.path, err)
+	}
+
+	var generation int64
+	var generationCreated time.Time
+	for _, info := range generations {
+		infoCreated, err := time.Parse(time.RFC3339Nano, info.TimeCreated)
+		if err != nil {
+			return 0, fmt.Errorf("invalid creation time of '%s': %s", object.path, err)
+		}
+
+		if !infoCreated.After(created) && (generation == 0 || infoCreated.After(generationCreated)) {
+			generation = info.Generation
+			generationCreated = infoCreated
+		}
+	}
+
+	if generation == 0 {
+		return 0, fmt.Errorf("required %s '%s' did not exist when the version was created", object.kind, object.path)
+	}
+
+	return generation, nil
+}
+
+func (command *InCommand) downloadRelatedObjects(bucketName string, related []relatedObject, destinationDir string) error {
+	for _, object := range related {
+		localPath := filepath.Join(destinationDir, path.Base(object.path))
+
+		if err := command.downloadFile(bucketName, object.path, object.generation, localPath); err != nil {
+			return fmt.Errorf("failed to download %s '%s': %s", object.kind, object.path, err)
+		}
+	}
+
+	return nil
+}
+
+func (command *InCommand) relatedMetadata(bucketName string, related []relatedObject) ([]gcsresource.MetadataPair, error) {
+	var metadata []gcsresource.MetadataPair
+
+	for _, object := range related {
+		url, err := command.gcsClient.URL(bucketName, object.path, object.generation)
+		if err != nil {
+			return nil, err
+		}
+
+		metadata = append(metadata, gcsresource.MetadataPair{
+			Name:  object.kind,
+			Value: url,
+		})
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,325 @@
+package in
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// extraction writes the entries of one archive. The metadata of directories
+// is restored last, as writing their entries changes it.
+type extraction struct {
+	unpackOptions
+
+	directories []entryMetadata
+}
+
+type entryMetadata struct {
+	path    string
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func newExtraction(options unpackOptions) *extraction {
+	return &extraction{
+		unpackOptions: options,
+	}
+}
+
+func (extraction *extraction) dir(path string, mode os.FileMode, modTime time.Time) error {
+	if err := writeDir(extraction.destinationDir, path); err != nil {
+		return err
+	}
+
+	extraction.directories = append(extraction.directories, entryMetadata{
+		path:    path,
+		mode:    mode,
+		modTime: modTime,
+	})
+
+	return nil
+}
+
+func (extraction *extraction) file(path string, reader io.Reader, mode os.FileMode, modTime time.Time) error {
+	if err := writeFile(extraction.destinationDir, path, reader, mode); err != nil {
+		return err
+	}
+
+	return restoreMetadata(path, mode, modTime)
+}
+
+func (extraction *extraction) symlink(path string, target string) error {
+	return writeSymlink(extraction.destinationDir, path, target)
+}
+
+func (extraction *extraction) link(path string, target string) error {
+	return writeLink(extraction.destinationDir, path, target)
+}
+
+// chown restores the owner of an entry when preserve_ownership is set. Only
+// root may give files away, so ownership is kept otherwise.
+func (extraction *extraction) chown(path string, uid int, gid int) error {
+	if !extraction.preserveOwnership || os.Geteuid() != 0 {
+		return nil
+	}
+
+	return os.Lchown(path, uid, gid)
+}
+
+// finish restores the metadata of the extracted directories, innermost
+// first.
+func (extraction *extraction) finish() error {
+	for i := len(extraction.directories) - 1; i >= 0; i-- {
+		directory := extraction.directories[i]
+
+		if err := restoreMetadata(directory.path, directory.mode, directory.modTime); err != nil {
+			return entryError(directory.path, err)
+		}
+	}
+
+	return nil
+}
+
+// restoreMetadata applies the permission bits and modification time of an
+// archive entry, regardless of the umask. Entries without permission bits
+// keep the defaults they were written with.
+func restoreMetadata(path string, mode os.FileMode, modTime time.Time) error {
+	if mode.Perm() != 0 {
+		if err := os.Chmod(path, mode.Perm()); err != nil {
+			return err
+		}
+	}
+
+	if modTime.IsZero() {
+		return nil
+	}
+
+	return os.Chtimes(path, modTime, modTime)
+}
+
+func entryError(name string, err error) error {
+	return fmt.Errorf("entry '%s': %s", name, err)
+}
+
+// entryPath returns the local path of an archive entry. It returns false if
+// the entry is stripped or not selected by the include and exclude patterns.
+func (options unpackOptions) entryPath(name string) (string, bool, error) {
+	stripped, err := options.strip(name)
+	if err != nil || stripped == "" {
+		return "", false, err
+	}
+
+	if len(options.include) > 0 && !matchesAny(options.include, stripped) {
+		return "", false, nil
+	}
+
+	if matchesAny(options.exclude, stripped) {
+		return "", false, nil
+	}
+
+	return filepath.Join(options.destinationDir, filepath.FromSlash(stripped)), true, nil
+}
+
+// linkPath returns the local path of a hard link target.
+func (options unpackOptions) linkPath(name string) (string, error) {
+	stripped, err := options.strip(name)
+	if err != nil {
+		return "", err
+	}
+
+	if stripped == "" {
+		return "", errors.New("stripped by strip_components")
+	}
+
+	return filepath.Join(options.destinationDir, filepath.FromSlash(stripped)), nil
+}
+
+// strip removes the leading path components of name, rejecting names which
+// are absolute or contain '..' components.
+func (options unpackOptions) strip(name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return "", errors.New("absolute paths are not allowed")
+	}
+
+	components := []string{}
+	for _, component := range strings.FieldsFunc(name, isPathSeparator) {
+		if component == ".." {
+			return "", errors.New("'..' path components are not allowed")
+		}
+
+		if component != "." {
+			components = append(components, component)
+		}
+	}
+
+	if len(components) <= options.stripComponents {
+		return "", nil
+	}
+
+	return strings.Join(components[options.stripComponents:], "/"), nil
+}
+
+// matchesAny reports whether name, or one of the directories containing it,
+// matches any of patterns. Patterns without a '/' match any path component,
+// the way .gitignore patterns do.
+func matchesAny(patterns []string, name string) bool {
+	components := strings.Split(name, "/")
+
+	for _, pattern := range patterns {
+		candidates := components
+		if strings.Contains(pattern, "/") {
+			candidates = make([]string, len(components))
+			for i := range components {
+				candidates[i] = strings.Join(components[:i+1], "/")
+			}
+		}
+
+		for _, candidate := range candidates {
+			if matched, _ := path.Match(pattern, candidate); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+// checkWithin ensures path resolves inside destinationDir, following the
+// symlinks extracted so far.
+func checkWithin(destinationDir string, path string) error {
+	root, err := resolve(destinationDir)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := resolve(path)
+	if err != nil {
+		return err
+	}
+
+	relative, err := filepath.Rel(root, resolved)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(os.PathSeparator)) {
+		return errors.New("would be written outside of the destination dir")
+	}
+
+	return nil
+}
+
+// resolve evaluates the symlinks of the existing part of path and appends
+// the remainder. '..' components are applied after resolving the symlinks
+// before them, the way the filesystem does.
+func resolve(path string) (string, error) {
+	existing := path
+	remainder := []string{}
+
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, remainder...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		separator := strings.LastIndex(existing, string(os.PathSeparator))
+		if separator <= 0 {
+			return filepath.Clean(path), nil
+		}
+
+		remainder = append([]string{existing[separator+1:]}, remainder...)
+		existing = existing[:separator]
+	}
+}
+
+func writeDir(destinationDir string, path string) error {
+	if err := checkWithin(destinationDir, path); err != nil {
+		return err
+	}
+
+	return os.MkdirAll(path, 0755)
+}
+
+func writeFile(destinationDir string, path string, reader io.Reader, mode os.FileMode) error {
+	if err := writeDir(destinationDir, filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	if err := removeExisting(path); err != nil {
+		return err
+	}
+
+	writer, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(writer, reader); err != nil {
+		writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
+
+func writeSymlink(destinationDir string, path string, target string) error {
+	if filepath.IsAbs(target) {
+		return fmt.Errorf("symlink target '%s' is absolute", target)
+	}
+
+	if err := writeDir(destinationDir, filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	if err := checkWithin(destinationDir, filepath.Dir(path)+string(os.PathSeparator)+target); err != nil {
+		return fmt.Errorf("symlink target '%s' %s", target, err)
+	}
+
+	if err := removeExisting(path); err != nil {
+		return err
+	}
+
+	return os.Symlink(target, path)
+}
+
+func writeLink(destinationDir string, path string, target string) error {
+	if err := checkWithin(destinationDir, target); err != nil {
+		return fmt.Errorf("link target %s", err)
+	}
+
+	if err := writeDir(destinationDir, filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	if err := removeExisting(path); err != nil {
+		return err
+	}
+
+	return os.Link(target, path)
+}
+
+// removeExisting replaces files the way tar does, rather than writing
+// through an existing file or symlink.
+func removeExisting(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+
+	return os.Remove(path)
+}
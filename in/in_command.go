@@ -1,15 +1,19 @@
 package in
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	gcsresource "github.com/syslxg/gcs-resource"
 	"github.com/syslxg/gcs-resource/versions"
+	storage "google.golang.org/api/storage/v1"
 )
 
 type InCommand struct {
@@ -27,6 +31,36 @@ func (command *InCommand) Run(destinationDir string, request InRequest) (InRespo
 		return InResponse{}, errors.New(message)
 	}
 
+	if ok, message := request.Params.IsValid(); !ok {
+		return InResponse{}, errors.New(message)
+	}
+
+	if request.Source.PrefixRegexp != "" && (len(request.Params.Companions) > 0 || len(request.Params.Files) > 0) {
+		return InResponse{}, errors.New("companions and files are not supported with prefix_regexp")
+	}
+
+	if request.Source.PrefixRegexp != "" && len(request.Params.ChecksumFiles) > 0 {
+		return InResponse{}, errors.New("checksum_files is not supported with prefix_regexp")
+	}
+
+	if request.Source.PrefixRegexp != "" && request.Params.SignedURLTTL != "" {
+		return InResponse{}, errors.New("signed_url_ttl is not supported with prefix_regexp")
+	}
+
+	if request.Params.Verify {
+		if request.Source.PrefixRegexp != "" {
+			return InResponse{}, errors.New("verify is not supported with prefix_regexp")
+		}
+
+		if len(request.Source.TrustedKeys) == 0 {
+			return InResponse{}, errors.New("please specify trusted_keys when using verify")
+		}
+
+		if _, err := parseTrustedKeys(request.Source.TrustedKeys); err != nil {
+			return InResponse{}, err
+		}
+	}
+
 	err := command.createDirectory(destinationDir)
 	if err != nil {
 		return InResponse{}, err
@@ -45,6 +79,8 @@ func (command *InCommand) Run(destinationDir string, request InRequest) (InRespo
 
 	if request.Source.Regexp != "" {
 		return command.inByRegex(destinationDir, request, skipDownload)
+	} else if request.Source.PrefixRegexp != "" {
+		return command.inByPrefix(destinationDir, request, skipDownload)
 	} else {
 		return command.inByVersionedFile(destinationDir, request, skipDownload)
 	}
@@ -57,34 +93,76 @@ func (command *InCommand) createDirectory(destinationDir string) error {
 func (command *InCommand) inByRegex(destinationDir string, request InRequest, skipDownload bool) (InResponse, error) {
 	bucketName := request.Source.Bucket
 
-	objectPath, err := command.pathToDownload(request)
+	var versionFrom versions.VersionFrom
+	if request.Source.VersionFrom != "" {
+		var err error
+		versionFrom, err = versions.ParseVersionFrom(request.Source.VersionFrom)
+		if err != nil {
+			return InResponse{}, err
+		}
+	}
+
+	requestedVersion, err := command.versionToDownload(request)
 	if err != nil {
 		return InResponse{}, err
 	}
+	objectPath := requestedVersion.Path
 
-	if !skipDownload {
-		localPath := filepath.Join(destinationDir, filepath.Base(objectPath))
+	var generation int64
+	if request.Source.TrackGenerations {
+		if requestedVersion.Generation == "" {
+			return InResponse{}, fmt.Errorf("please specify the generation of '%s' when using track_generations", objectPath)
+		}
 
-		if err := command.downloadFile(bucketName, objectPath, 0, localPath); err != nil {
+		generation, err = requestedVersion.GenerationValue()
+		if err != nil {
 			return InResponse{}, err
 		}
+	}
 
-		if request.Params.Unpack {
-			if err := command.unpackFile(localPath); err != nil {
-				return InResponse{}, err
-			}
+	versionNumber, hasVersion := command.versionNumber(requestedVersion, request.Source)
+
+	var checksumMetadata []gcsresource.MetadataPair
+
+	related, err := request.Params.relatedObjects(newObjectTemplate(objectPath, versionNumber))
+	if err != nil {
+		return InResponse{}, err
+	}
+
+	object, err := command.gcsClient.ObjectInfo(bucketName, objectPath, generation)
+	if err != nil {
+		return InResponse{}, err
+	}
+
+	// the object may have been replaced since the version was emitted
+	if request.Source.VersionFrom != "" {
+		attribution, _ := versions.Attribute(object, versionFrom)
+		if attribution.Value != requestedVersion.Value {
+			return InResponse{}, fmt.Errorf("object '%s' has changed: version_from value is '%s', not '%s'", objectPath, attribution.Value, requestedVersion.Value)
 		}
 	}
 
-	version, ok := versions.Extract(objectPath, request.Source.Regexp)
-	if ok {
-		err := command.writeVersionFile(version.VersionNumber, destinationDir)
+	if !skipDownload {
+		related, checksumMetadata, err = command.fetchFiles(bucketName, objectPath, generation, object, related, destinationDir, request)
+		if err != nil {
+			return InResponse{}, err
+		}
+	}
+
+	if hasVersion {
+		err := command.writeVersionFile(versionNumber, destinationDir)
 		if err != nil {
 			return InResponse{}, err
 		}
 	}
 
-	url, err := command.gcsClient.URL(bucketName, objectPath, 0)
+	if request.Source.TrackGenerations {
+		if err := command.writeGenerationFile(object.Generation, destinationDir); err != nil {
+			return InResponse{}, err
+		}
+	}
+
+	url, err := command.gcsClient.URL(bucketName, objectPath, generation)
 	if err != nil {
 		return InResponse{}, err
 	}
@@ -93,27 +171,165 @@ func (command *InCommand) inByRegex(destinationDir string, request InRequest, sk
 		return InResponse{}, err
 	}
 
+	signedURLMetadata, err := command.writeSignedURLFile(request, objectPath, generation, destinationDir)
+	if err != nil {
+		return InResponse{}, err
+	}
+
+	responseVersion := gcsresource.Version{
+		Path: objectPath,
+	}
+	if request.Source.TrackGenerations {
+		responseVersion.Generation = fmt.Sprintf("%d", object.Generation)
+	}
+	if request.Source.VersionFrom != "" {
+		responseVersion.Value = requestedVersion.Value
+	}
+
+	relatedMetadata, err := command.relatedMetadata(bucketName, related)
+	if err != nil {
+		return InResponse{}, err
+	}
+
+	if err := command.writeObjectFile(object, destinationDir); err != nil {
+		return InResponse{}, err
+	}
+
+	metadata := append(append(command.metadata(objectPath, url, object), relatedMetadata...), checksumMetadata...)
+
 	return InResponse{
-		Version: gcsresource.Version{
-			Path: objectPath,
-		},
-		Metadata: command.metadata(objectPath, url),
+		Version:  responseVersion,
+		Metadata: append(append(metadata, signedURLMetadata...), gcsresource.HoldsMetadata(object)...),
 	}, nil
 }
 
-func (command *InCommand) pathToDownload(request InRequest) (string, error) {
+// versionNumber returns the version number of a regexp version, if any.
+func (command *InCommand) versionNumber(version gcsresource.Version, source gcsresource.Source) (string, bool) {
+	if source.VersionFrom != "" {
+		return version.Value, true
+	}
+
+	if extraction, ok := versions.Extract(version.Path, source.Regexp); ok {
+		return extraction.VersionNumber, true
+	}
+
+	return "", false
+}
+
+func (command *InCommand) versionToDownload(request InRequest) (gcsresource.Version, error) {
 	if request.Version.Path != "" {
-		return request.Version.Path, nil
+		return request.Version, nil
 	}
 
-	extractions := versions.GetBucketObjectVersions(command.gcsClient, request.Source)
+	if request.Source.VersionFrom != "" {
+		attributions := versions.GetBucketObjectAttributions(command.gcsClient, request.Source)
+
+		if len(attributions) == 0 {
+			return gcsresource.Version{}, errors.New("no objects could be found - is your regexp correct?")
+		}
+
+		lastAttribution := attributions[len(attributions)-1]
+		return gcsresource.Version{
+			Path:  lastAttribution.Path,
+			Value: lastAttribution.Value,
+		}, nil
+	}
+
+	var extractions versions.Extractions
+	if request.Source.TrackGenerations {
+		extractions = versions.GetBucketObjectGenerations(command.gcsClient, request.Source)
+	} else {
+		extractions = versions.GetBucketObjectVersions(command.gcsClient, request.Source)
+	}
 
 	if len(extractions) == 0 {
-		return "", errors.New("no extractions could be found - is your regexp correct?")
+		return gcsresource.Version{}, errors.New("no extractions could be found - is your regexp correct?")
 	}
 
 	lastExtraction := extractions[len(extractions)-1]
-	return lastExtraction.Path, nil
+	version := gcsresource.Version{
+		Path: lastExtraction.Path,
+	}
+	if request.Source.TrackGenerations {
+		version.Generation = fmt.Sprintf("%d", lastExtraction.Generation)
+	}
+
+	return version, nil
+}
+
+func (command *InCommand) inByPrefix(destinationDir string, request InRequest, skipDownload bool) (InResponse, error) {
+	bucketName := request.Source.Bucket
+	pattern := versions.PrefixPattern(request.Source.PrefixRegexp)
+
+	prefix := request.Version.Path
+	if prefix == "" {
+		extractions := versions.GetBucketPrefixVersions(command.gcsClient, request.Source)
+
+		if len(extractions) == 0 {
+			return InResponse{}, errors.New("no extractions could be found - is your prefix_regexp correct?")
+		}
+
+		prefix = extractions[len(extractions)-1].Path
+	}
+
+	if !skipDownload {
+		if err := command.downloadPrefix(bucketName, prefix, destinationDir); err != nil {
+			return InResponse{}, err
+		}
+	}
+
+	version, ok := versions.Extract(prefix, pattern)
+	if ok {
+		err := command.writeVersionFile(version.VersionNumber, destinationDir)
+		if err != nil {
+			return InResponse{}, err
+		}
+	}
+
+	url := fmt.Sprintf("gs://%s/%s", bucketName, prefix)
+	if err := command.writeURLFile(url, destinationDir); err != nil {
+		return InResponse{}, err
+	}
+
+	return InResponse{
+		Version: gcsresource.Version{
+			Path: prefix,
+		},
+		Metadata: command.metadata(prefix, url, nil),
+	}, nil
+}
+
+func (command *InCommand) downloadPrefix(bucketName string, prefix string, destinationDir string) error {
+	objectPaths, err := command.gcsClient.BucketObjects(bucketName, prefix)
+	if err != nil {
+		return err
+	}
+
+	if len(objectPaths) == 0 {
+		return fmt.Errorf("no objects found under prefix: %s", prefix)
+	}
+
+	for _, objectPath := range objectPaths {
+		relativePath := strings.TrimPrefix(objectPath, prefix)
+		if relativePath == "" || strings.HasSuffix(relativePath, "/") {
+			continue
+		}
+
+		localPath := filepath.Join(destinationDir, filepath.FromSlash(relativePath))
+		if !strings.HasPrefix(localPath, filepath.Clean(destinationDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("object '%s' would be written outside of the destination dir", objectPath)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			return err
+		}
+
+		if err := command.downloadFile(bucketName, objectPath, 0, localPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (command *InCommand) inByVersionedFile(destinationDir string, request InRequest, skipDownload bool) (InResponse, error) {
@@ -124,17 +340,22 @@ func (command *InCommand) inByVersionedFile(destinationDir string, request InReq
 		return InResponse{}, err
 	}
 
-	if !skipDownload {
-		localPath := filepath.Join(destinationDir, filepath.Base(objectPath))
+	var checksumMetadata []gcsresource.MetadataPair
 
-		if err := command.downloadFile(bucketName, objectPath, generation, localPath); err != nil {
-			return InResponse{}, err
-		}
+	related, err := request.Params.relatedObjects(newObjectTemplate(objectPath, strconv.FormatInt(generation, 10)))
+	if err != nil {
+		return InResponse{}, err
+	}
 
-		if request.Params.Unpack {
-			if err := command.unpackFile(localPath); err != nil {
-				return InResponse{}, err
-			}
+	object, err := command.gcsClient.ObjectInfo(bucketName, objectPath, generation)
+	if err != nil {
+		return InResponse{}, err
+	}
+
+	if !skipDownload {
+		related, checksumMetadata, err = command.fetchFiles(bucketName, objectPath, generation, object, related, destinationDir, request)
+		if err != nil {
+			return InResponse{}, err
 		}
 	}
 
@@ -151,11 +372,27 @@ func (command *InCommand) inByVersionedFile(destinationDir string, request InReq
 		return InResponse{}, err
 	}
 
+	signedURLMetadata, err := command.writeSignedURLFile(request, objectPath, generation, destinationDir)
+	if err != nil {
+		return InResponse{}, err
+	}
+
+	relatedMetadata, err := command.relatedMetadata(bucketName, related)
+	if err != nil {
+		return InResponse{}, err
+	}
+
+	if err := command.writeObjectFile(object, destinationDir); err != nil {
+		return InResponse{}, err
+	}
+
+	metadata := append(append(command.metadata(objectPath, url, object), relatedMetadata...), checksumMetadata...)
+
 	return InResponse{
 		Version: gcsresource.Version{
 			Generation: fmt.Sprintf("%d", generation),
 		},
-		Metadata: command.metadata(objectPath, url),
+		Metadata: append(append(metadata, signedURLMetadata...), gcsresource.HoldsMetadata(object)...),
 	}, nil
 }
 
@@ -163,6 +400,37 @@ func (command *InCommand) writeVersionFile(version string, destinationDir string
 	return ioutil.WriteFile(filepath.Join(destinationDir, "version"), []byte(version), 0644)
 }
 
+// writeObjectFile writes the object resource to object.json, so tasks can
+// read its metadata without credentials.
+func (command *InCommand) writeObjectFile(object *storage.Object, destinationDir string) error {
+	contents, err := json.MarshalIndent(object, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(destinationDir, "object.json"), append(contents, '\n'), 0644)
+}
+
+// writeSignedURLFile writes a signed https url of the object, valid for
+// signed_url_ttl, to the signed_url file and returns it as metadata.
+func (command *InCommand) writeSignedURLFile(request InRequest, objectPath string, generation int64, destinationDir string) ([]gcsresource.MetadataPair, error) {
+	if request.Params.SignedURLTTL == "" {
+		return nil, nil
+	}
+
+	ttl, _ := gcsresource.ParseSignedURLTTL(request.Params.SignedURLTTL)
+	signedURL, err := command.gcsClient.SignedURL(request.Source.Bucket, objectPath, generation, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(destinationDir, "signed_url"), []byte(signedURL), 0644); err != nil {
+		return nil, err
+	}
+
+	return []gcsresource.MetadataPair{{Name: "signed_url", Value: signedURL}}, nil
+}
+
 func (command *InCommand) writeGenerationFile(generation int64, destinationDir string) error {
 	return ioutil.WriteFile(filepath.Join(destinationDir, "generation"), []byte(strconv.FormatInt(generation, 10)), 0644)
 }
@@ -171,6 +439,109 @@ func (command *InCommand) writeURLFile(url string, destinationDir string) error
 	return ioutil.WriteFile(filepath.Join(destinationDir, "url"), []byte(url), 0644)
 }
 
+// fetchFiles downloads an object and its related objects to the destination
+// dir. Related objects are checked before anything is downloaded and, if the
+// object is fetched by generation, are pinned to the generations matching it.
+// With verify and checksum_files, the signature and checksum sidecars of the
+// object are added to the related objects, and the checksums are returned as
+// metadata.
+func (command *InCommand) fetchFiles(bucketName string, objectPath string, generation int64, object *storage.Object, related []relatedObject, destinationDir string, request InRequest) ([]relatedObject, []gcsresource.MetadataPair, error) {
+	if err := command.checkRelatedObjects(bucketName, related); err != nil {
+		return nil, nil, err
+	}
+
+	var checks []func(localPath string) error
+
+	if request.Params.Verify {
+		trusted, err := parseTrustedKeys(request.Source.TrustedKeys)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		signature, err := command.signatureObject(bucketName, objectPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !containsObject(related, signature.path) {
+			related = append(related, signature)
+		}
+
+		checks = append(checks, func(localPath string) error {
+			if err := trusted.verify(localPath, filepath.Join(destinationDir, path.Base(signature.path))); err != nil {
+				return fmt.Errorf("failed to verify '%s' with the 'params.verify' option enabled: %s", filepath.Base(objectPath), err)
+			}
+
+			return nil
+		})
+	}
+
+	var metadata []gcsresource.MetadataPair
+
+	if len(request.Params.ChecksumFiles) > 0 {
+		sidecars, err := command.checksumSidecars(bucketName, objectPath, request.Params.ChecksumFiles)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, algorithm := range request.Params.ChecksumFiles {
+			if sidecar, ok := sidecars[algorithm]; ok && !containsObject(related, sidecar.path) {
+				related = append(related, sidecar)
+			}
+		}
+
+		checks = append(checks, func(localPath string) error {
+			var err error
+			metadata, err = checkChecksums(localPath, destinationDir, request.Params.ChecksumFiles, sidecars)
+			return err
+		})
+	}
+
+	if generation != 0 && len(related) > 0 {
+		if err := command.pinRelatedObjects(bucketName, object, related); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := command.downloadRelatedObjects(bucketName, related, destinationDir); err != nil {
+		return nil, nil, err
+	}
+
+	if err := command.fetchFile(bucketName, objectPath, generation, destinationDir, request.Params, checks); err != nil {
+		return nil, nil, err
+	}
+
+	return related, metadata, nil
+}
+
+// fetchFile downloads an object to the destination dir, checking, unpacking
+// or streaming it as requested by params. An object failing a check is
+// removed.
+func (command *InCommand) fetchFile(bucketName string, objectPath string, generation int64, destinationDir string, params Params, checks []func(localPath string) error) error {
+	if params.Stream {
+		return command.streamFile(bucketName, objectPath, generation, destinationDir, params)
+	}
+
+	localPath := filepath.Join(destinationDir, filepath.Base(objectPath))
+
+	if err := command.downloadFile(bucketName, objectPath, generation, localPath); err != nil {
+		return err
+	}
+
+	for _, check := range checks {
+		if err := check(localPath); err != nil {
+			os.Remove(localPath)
+			return err
+		}
+	}
+
+	if params.Unpack {
+		return command.unpackFile(localPath, destinationDir, params)
+	}
+
+	return nil
+}
+
 func (command *InCommand) downloadFile(bucketName string, objectPath string, generation int64, localPath string) error {
 	return command.gcsClient.DownloadFile(
 		bucketName,
@@ -180,7 +551,7 @@ func (command *InCommand) downloadFile(bucketName string, objectPath string, gen
 	)
 }
 
-func (command *InCommand) unpackFile(sourcePath string) error {
+func (command *InCommand) unpackFile(sourcePath string, destinationDir string, params Params) error {
 
 	var (
 		errorMessage = "failed to extract '%s' with the 'params.unpack' option enabled: %s"
@@ -196,13 +567,63 @@ func (command *InCommand) unpackFile(sourcePath string) error {
 		return fmt.Errorf(errorMessage, fileName, "unsupported MIME type "+mimeType)
 	}
 
-	if err := unpack(mimeType, sourcePath); err != nil {
+	if err := unpack(mimeType, sourcePath, newUnpackOptions(destinationDir, params)); err != nil {
+		return fmt.Errorf(errorMessage, fileName, err)
+	}
+
+	return nil
+}
+
+func (command *InCommand) streamFile(bucketName string, objectPath string, generation int64, destinationDir string, params Params) error {
+	var (
+		errorMessage = "failed to extract '%s' with the 'params.stream' option enabled: %s"
+		fileName     = filepath.Base(objectPath)
+	)
+
+	reader, err := command.gcsClient.DownloadStream(bucketName, objectPath, generation)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// the checksum of the stream is only verified once it was read to its
+	// end, so the entries are extracted next to the destination and only
+	// moved into place afterwards
+	if err := os.MkdirAll(destinationDir, 0755); err != nil {
+		return err
+	}
+
+	extractDir, err := ioutil.TempDir(destinationDir, ".stream-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(extractDir)
+
+	if err := unpackStream(reader, fileName, newUnpackOptions(extractDir, params)); err != nil {
+		return fmt.Errorf(errorMessage, fileName, err)
+	}
+
+	if err := moveTree(extractDir, destinationDir); err != nil {
 		return fmt.Errorf(errorMessage, fileName, err)
 	}
 
 	return nil
 }
-func (command *InCommand) metadata(objectPath string, url string) []gcsresource.MetadataPair {
+
+func newUnpackOptions(destinationDir string, params Params) unpackOptions {
+	return unpackOptions{
+		destinationDir:    filepath.Join(destinationDir, params.UnpackDir),
+		stripComponents:   params.StripComponents,
+		include:           params.Include,
+		exclude:           params.Exclude,
+		keepArchive:       params.KeepArchiveValue(),
+		preserveOwnership: params.PreserveOwnership,
+	}
+}
+
+// metadata describes the fetched object, along with the object resource if
+// there is one.
+func (command *InCommand) metadata(objectPath string, url string, object *storage.Object) []gcsresource.MetadataPair {
 	objectFilename := filepath.Base(objectPath)
 
 	metadata := []gcsresource.MetadataPair{
@@ -216,5 +637,9 @@ func (command *InCommand) metadata(objectPath string, url string) []gcsresource.
 		},
 	}
 
+	if object != nil {
+		metadata = append(metadata, gcsresource.ObjectMetadata(object)...)
+	}
+
 	return metadata
 }
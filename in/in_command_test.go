@@ -1,16 +1,33 @@
 package in_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	gcsresource "github.com/syslxg/gcs-resource"
 	"github.com/syslxg/gcs-resource/fakes"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	storage "google.golang.org/api/storage/v1"
 
 	. "github.com/syslxg/gcs-resource/in"
 )
@@ -40,6 +57,7 @@ var _ = Describe("In Command", func() {
 			}
 
 			gcsClient = &fakes.FakeGCSClient{}
+			gcsClient.ObjectInfoReturns(&storage.Object{}, nil)
 			command = NewInCommand(gcsClient)
 		})
 
@@ -84,7 +102,7 @@ var _ = Describe("In Command", func() {
 				BeforeEach(func() {
 					request.Version.Path = ""
 
-					gcsClient.BucketObjectsReturns([]string{
+					gcsClient.QueryBucketObjectsReturns([]string{
 						"folder/file-1.5.6-build.10.tgz",
 						"folder/file-1.5.6-build.100.tgz",
 						"folder/file-1.5.6-build.9.tgz",
@@ -109,7 +127,7 @@ var _ = Describe("In Command", func() {
 				BeforeEach(func() {
 					request.Version.Path = ""
 
-					gcsClient.BucketObjectsReturns([]string{
+					gcsClient.QueryBucketObjectsReturns([]string{
 						"folder/file-0.0.1.tgz",
 						"folder/file-3.53.tgz",
 						"folder/file-2.33.333.tgz",
@@ -328,129 +346,1362 @@ var _ = Describe("In Command", func() {
 
 						Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
 					})
+
+					It("still writes the 'object.json' file", func() {
+						gcsClient.ObjectInfoReturns(&storage.Object{Name: "folder/file-1.3.tgz", Generation: 12345}, nil)
+
+						_, err := command.Run(destDir, request)
+						Expect(err).ToNot(HaveOccurred())
+
+						contents, err := ioutil.ReadFile(filepath.Join(destDir, "object.json"))
+						Expect(err).ToNot(HaveOccurred())
+						Expect(contents).To(MatchJSON(`{"name": "folder/file-1.3.tgz", "generation": "12345"}`))
+					})
+				})
+
+				Describe("when 'skip_download' is specified locally", func() {
+					BeforeEach(func() {
+						request.Params.SkipDownload = "true"
+					})
+
+					It("skips the download of the file", func() {
+						_, err := command.Run(destDir, request)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+					})
+				})
+
+				Describe("when 'skip_download' is specified globally, but overridden locally", func() {
+					BeforeEach(func() {
+						request.Source.SkipDownload = true
+						request.Params.SkipDownload = "false"
+					})
+
+					It("downloads the existing version of the file", func() {
+						_, err := command.Run(destDir, request)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(gcsClient.DownloadFileCallCount()).To(Equal(1))
+						bucketName, objectPath, generation, localPath := gcsClient.DownloadFileArgsForCall(0)
+
+						Expect(bucketName).To(Equal("bucket-name"))
+						Expect(objectPath).To(Equal("folder/file-1.3.tgz"))
+						Expect(generation).To(Equal(int64(0)))
+						Expect(localPath).To(Equal(filepath.Join(destDir, "file-1.3.tgz")))
+					})
+				})
+
+				Describe("when 'companions' and 'files' are specified", func() {
+					BeforeEach(func() {
+						request.Params.Companions = []string{".sha256", "{{.Dir}}/signatures/{{.Name}}.sig"}
+						request.Params.Files = []string{"folder/release-notes-{{.Version}}.md"}
+
+						gcsClient.GetBucketObjectInfoStub = func(bucketName string, objectPath string) (*storage.Object, error) {
+							return &storage.Object{Name: objectPath, Generation: 42}, nil
+						}
+						gcsClient.URLStub = func(bucketName string, objectPath string, generation int64) (string, error) {
+							return "gs://" + bucketName + "/" + objectPath, nil
+						}
+					})
+
+					It("downloads the companions and files next to the object", func() {
+						_, err := command.Run(destDir, request)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(gcsClient.DownloadFileCallCount()).To(Equal(4))
+
+						downloads := map[string]string{}
+						for i := 0; i < gcsClient.DownloadFileCallCount(); i++ {
+							_, objectPath, generation, localPath := gcsClient.DownloadFileArgsForCall(i)
+							Expect(generation).To(Equal(int64(0)))
+							downloads[objectPath] = localPath
+						}
+
+						Expect(downloads).To(Equal(map[string]string{
+							"folder/file-1.3.tgz":                filepath.Join(destDir, "file-1.3.tgz"),
+							"folder/file-1.3.tgz.sha256":         filepath.Join(destDir, "file-1.3.tgz.sha256"),
+							"folder/signatures/file-1.3.tgz.sig": filepath.Join(destDir, "file-1.3.tgz.sig"),
+							"folder/release-notes-1.3.md":        filepath.Join(destDir, "release-notes-1.3.md"),
+						}))
+					})
+
+					It("reports each object in the metadata", func() {
+						response, err := command.Run(destDir, request)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(response.Metadata[3:]).To(Equal([]gcsresource.MetadataPair{
+							{Name: "companion", Value: "gs://bucket-name/folder/file-1.3.tgz.sha256"},
+							{Name: "companion", Value: "gs://bucket-name/folder/signatures/file-1.3.tgz.sig"},
+							{Name: "file", Value: "gs://bucket-name/folder/release-notes-1.3.md"},
+						}))
+					})
+
+					It("fails before downloading anything if a companion is missing", func() {
+						gcsClient.GetBucketObjectInfoStub = func(bucketName string, objectPath string) (*storage.Object, error) {
+							if objectPath == "folder/file-1.3.tgz.sha256" {
+								return nil, errors.New("object not found")
+							}
+							return &storage.Object{Name: objectPath}, nil
+						}
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(Equal("required companion 'folder/file-1.3.tgz.sha256' could not be found: object not found"))
+						Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+					})
+
+					It("does not check or download them when skipping the download", func() {
+						request.Params.SkipDownload = "true"
+
+						response, err := command.Run(destDir, request)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(gcsClient.GetBucketObjectInfoCallCount()).To(Equal(0))
+						Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+						Expect(response.Metadata).To(HaveLen(6))
+					})
+
+					It("returns an error if two objects would be written to the same file", func() {
+						request.Params.Files = []string{"other/file-1.3.tgz.sha256"}
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(Equal("objects 'folder/file-1.3.tgz.sha256' and 'other/file-1.3.tgz.sha256' would both be written to 'file-1.3.tgz.sha256'"))
+					})
+
+					It("returns an error for an invalid template", func() {
+						request.Params.Companions = []string{"{{.Path"}
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("invalid template specified: {{.Path"))
+					})
+
+					It("returns an error for a template using an unknown field", func() {
+						request.Params.Companions = []string{"{{.Checksum}}"}
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("invalid companion '{{.Checksum}}'"))
+					})
+				})
+
+				Describe("when 'verify' is specified", func() {
+					var (
+						artifact   []byte
+						signatures map[string][]byte
+					)
+
+					pemKey := func(publicKey interface{}) string {
+						der, err := x509.MarshalPKIXPublicKey(publicKey)
+						Expect(err).NotTo(HaveOccurred())
+
+						return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+					}
+
+					BeforeEach(func() {
+						artifact = []byte("artifact-content")
+						signatures = map[string][]byte{}
+						request.Params.Verify = true
+
+						gcsClient.GetBucketObjectInfoStub = func(bucketName string, objectPath string) (*storage.Object, error) {
+							if _, ok := signatures[objectPath]; !ok {
+								return nil, gcsresource.ErrObjectNotExist
+							}
+							return &storage.Object{Name: objectPath}, nil
+						}
+						gcsClient.DownloadFileStub = func(bucketName string, objectPath string, generation int64, localPath string) error {
+							objects := map[string][]byte{"folder/file-1.3.tgz": artifact}
+							for signaturePath, signature := range signatures {
+								objects[signaturePath] = signature
+							}
+
+							return gcsDownloadObjectsStub(objects)(bucketName, objectPath, generation, localPath)
+						}
+					})
+
+					Context("with an OpenPGP key", func() {
+						var entity *openpgp.Entity
+
+						BeforeEach(func() {
+							var err error
+							entity, err = openpgp.NewEntity("publisher", "", "publisher@example.com", nil)
+							Expect(err).NotTo(HaveOccurred())
+
+							var key bytes.Buffer
+							writer, err := armor.Encode(&key, openpgp.PublicKeyType, nil)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(entity.Serialize(writer)).To(Succeed())
+							Expect(writer.Close()).To(Succeed())
+
+							request.Source.TrustedKeys = []string{key.String()}
+						})
+
+						It("verifies an armored signature in the .asc object", func() {
+							var signature bytes.Buffer
+							err := openpgp.ArmoredDetachSign(&signature, entity, bytes.NewReader(artifact), nil)
+							Expect(err).NotTo(HaveOccurred())
+							signatures["folder/file-1.3.tgz.asc"] = signature.Bytes()
+
+							response, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							Expect(filepath.Join(destDir, "file-1.3.tgz")).To(BeAnExistingFile())
+							Expect(filepath.Join(destDir, "file-1.3.tgz.asc")).To(BeAnExistingFile())
+							Expect(response.Metadata[3].Name).To(Equal("signature"))
+						})
+
+						It("verifies a binary signature in the .sig object", func() {
+							var signature bytes.Buffer
+							err := openpgp.DetachSign(&signature, entity, bytes.NewReader(artifact), nil)
+							Expect(err).NotTo(HaveOccurred())
+							signatures["folder/file-1.3.tgz.sig"] = signature.Bytes()
+
+							_, err = command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+						})
+
+						It("fails and removes the artifact if the signature does not match", func() {
+							var signature bytes.Buffer
+							err := openpgp.ArmoredDetachSign(&signature, entity, bytes.NewReader([]byte("other-content")), nil)
+							Expect(err).NotTo(HaveOccurred())
+							signatures["folder/file-1.3.tgz.asc"] = signature.Bytes()
+
+							_, err = command.Run(destDir, request)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("failed to verify 'file-1.3.tgz' with the 'params.verify' option enabled"))
+							Expect(filepath.Join(destDir, "file-1.3.tgz")).NotTo(BeAnExistingFile())
+						})
+					})
+
+					Context("with an ECDSA key", func() {
+						var privateKey *ecdsa.PrivateKey
+
+						BeforeEach(func() {
+							var err error
+							privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+							Expect(err).NotTo(HaveOccurred())
+
+							request.Source.TrustedKeys = []string{pemKey(privateKey.Public())}
+						})
+
+						It("verifies a base64 blob signature", func() {
+							digest := sha256.Sum256(artifact)
+							signature, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+							Expect(err).NotTo(HaveOccurred())
+							signatures["folder/file-1.3.tgz.sig"] = []byte(base64.StdEncoding.EncodeToString(signature) + "\n")
+
+							_, err = command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+						})
+
+						It("fails and removes the artifact if the signature does not match", func() {
+							digest := sha256.Sum256([]byte("other-content"))
+							signature, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+							Expect(err).NotTo(HaveOccurred())
+							signatures["folder/file-1.3.tgz.sig"] = []byte(base64.StdEncoding.EncodeToString(signature))
+
+							_, err = command.Run(destDir, request)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("signature does not match any trusted key"))
+							Expect(filepath.Join(destDir, "file-1.3.tgz")).NotTo(BeAnExistingFile())
+						})
+					})
+
+					Context("with an ed25519 key", func() {
+						It("verifies a raw blob signature before unpacking", func() {
+							publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+							Expect(err).NotTo(HaveOccurred())
+							request.Source.TrustedKeys = []string{pemKey(publicKey)}
+
+							artifact = gzipArchive("", tarArchive(archiveEntry{Name: "tool", Body: "tool"}))
+							signatures["folder/file-1.3.tgz.sig"] = ed25519.Sign(privateKey, artifact)
+							request.Params.Unpack = true
+
+							_, err = command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(filepath.Join(destDir, "tool")).To(BeAnExistingFile())
+						})
+					})
+
+					It("fails without downloading anything if a signature can not be looked up", func() {
+						request.Source.TrustedKeys = []string{pemKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))}
+						gcsClient.GetBucketObjectInfoReturns(nil, errors.New("forbidden"))
+						gcsClient.GetBucketObjectInfoStub = nil
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(Equal("failed to look up signature 'folder/file-1.3.tgz.sig': forbidden"))
+						Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+					})
+
+					It("returns an error if there is no signature", func() {
+						request.Source.TrustedKeys = []string{pemKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))}
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(Equal("no signature found for 'folder/file-1.3.tgz', expected 'folder/file-1.3.tgz.sig' or 'folder/file-1.3.tgz.asc'"))
+						Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+					})
+
+					It("returns an error if there are no trusted_keys", func() {
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(Equal("please specify trusted_keys when using verify"))
+					})
+
+					It("returns an error for an invalid trusted key", func() {
+						request.Source.TrustedKeys = []string{"not-a-key"}
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(Equal("invalid trusted key 0: not an armored OpenPGP or PEM public key"))
+					})
+
+					It("returns an error if stream is specified", func() {
+						request.Params.Unpack = true
+						request.Params.Stream = true
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(Equal("verify is not supported with stream"))
+					})
+				})
+
+				Describe("when 'checksum_files' is specified", func() {
+					var (
+						sha256Digest string
+						sidecars     map[string][]byte
+					)
+
+					BeforeEach(func() {
+						request.Params.ChecksumFiles = []string{"sha256", "md5"}
+						sha256Digest = fmt.Sprintf("%x", sha256.Sum256([]byte("artifact-content")))
+						sidecars = map[string][]byte{
+							"folder/file-1.3.tgz.sha256": []byte(sha256Digest + "  file-1.3.tgz\n"),
+						}
+
+						gcsClient.GetBucketObjectInfoStub = func(bucketName string, objectPath string) (*storage.Object, error) {
+							if _, ok := sidecars[objectPath]; !ok {
+								return nil, gcsresource.ErrObjectNotExist
+							}
+							return &storage.Object{Name: objectPath}, nil
+						}
+						gcsClient.DownloadFileStub = func(bucketName string, objectPath string, generation int64, localPath string) error {
+							objects := map[string][]byte{"folder/file-1.3.tgz": []byte("artifact-content")}
+							for sidecarPath, sidecar := range sidecars {
+								objects[sidecarPath] = sidecar
+							}
+
+							return gcsDownloadObjectsStub(objects)(bucketName, objectPath, generation, localPath)
+						}
+					})
+
+					It("verifies the download against the sidecars which are present", func() {
+						_, err := command.Run(destDir, request)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(gcsClient.DownloadFileCallCount()).To(Equal(2))
+						Expect(filepath.Join(destDir, "file-1.3.tgz.sha256")).To(BeAnExistingFile())
+					})
+
+					It("writes each digest to a file and the metadata", func() {
+						response, err := command.Run(destDir, request)
+						Expect(err).ToNot(HaveOccurred())
+
+						contents, err := ioutil.ReadFile(filepath.Join(destDir, "sha256"))
+						Expect(err).ToNot(HaveOccurred())
+						Expect(string(contents)).To(Equal(sha256Digest))
+
+						contents, err = ioutil.ReadFile(filepath.Join(destDir, "md5"))
+						Expect(err).ToNot(HaveOccurred())
+						Expect(string(contents)).To(Equal("95639c83887e2bc02c6809a0c2b97bb4"))
+
+						Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "sha256", Value: sha256Digest}))
+						Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "md5", Value: "95639c83887e2bc02c6809a0c2b97bb4"}))
+					})
+
+					It("fails and removes the object if it does not match a sidecar", func() {
+						sidecars["folder/file-1.3.tgz.sha256"] = []byte(strings.Repeat("0", 64) + "  file-1.3.tgz\n")
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(Equal("sha256 checksum mismatch for file-1.3.tgz: expected " + strings.Repeat("0", 64) + ", got " + sha256Digest))
+						Expect(filepath.Join(destDir, "file-1.3.tgz")).NotTo(BeAnExistingFile())
+						Expect(filepath.Join(destDir, "sha256")).NotTo(BeAnExistingFile())
+					})
+
+					It("fails without downloading anything if a sidecar can not be looked up", func() {
+						gcsClient.GetBucketObjectInfoStub = func(bucketName string, objectPath string) (*storage.Object, error) {
+							if objectPath == "folder/file-1.3.tgz.md5" {
+								return nil, errors.New("forbidden")
+							}
+							return &storage.Object{Name: objectPath}, nil
+						}
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(Equal("failed to look up md5 checksum file 'folder/file-1.3.tgz.md5': forbidden"))
+						Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+					})
+
+					It("returns an error for an unsupported algorithm", func() {
+						request.Params.ChecksumFiles = []string{"sha1"}
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(Equal("invalid checksum_files value specified: sha1"))
+					})
+				})
+
+				Describe("when 'stream' is specified", func() {
+					var stream *streamReader
+
+					BeforeEach(func() {
+						request.Params.Unpack = true
+						request.Params.Stream = true
+						request.Version.Path = "file-0.tgz"
+
+						gcsClient.DownloadStreamStub, stream = gcsDownloadStreamStub("file-0.tgz", nil)
+					})
+
+					It("extracts the streamed object without downloading it", func() {
+						_, err := command.Run(destDir, request)
+						Expect(err).NotTo(HaveOccurred())
+
+						contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+						Expect(string(contents)).To(Equal("some-tgz-file-content"))
+
+						Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+						Expect(filepath.Join(destDir, "file-0.tgz")).NotTo(BeAnExistingFile())
+						Expect(filepath.Join(destDir, "file-0.tgz.uncompressed")).NotTo(BeAnExistingFile())
+					})
+
+					It("streams the requested object", func() {
+						_, err := command.Run(destDir, request)
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(gcsClient.DownloadStreamCallCount()).To(Equal(1))
+						bucketName, objectPath, generation := gcsClient.DownloadStreamArgsForCall(0)
+						Expect(bucketName).To(Equal("bucket-name"))
+						Expect(objectPath).To(Equal("file-0.tgz"))
+						Expect(generation).To(Equal(int64(0)))
+					})
+
+					It("reads the stream to its end and closes it", func() {
+						_, err := command.Run(destDir, request)
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(stream.done).To(BeTrue())
+						Expect(stream.closed).To(BeTrue())
+					})
+
+					It("returns an error if the checksum of the stream does not match", func() {
+						gcsClient.DownloadStreamStub, _ = gcsDownloadStreamStub("file-0.tgz", errors.New("crc32c checksum mismatch for file-0.tgz"))
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("failed to extract 'file-0.tgz' with the 'params.stream' option enabled: crc32c checksum mismatch"))
+
+						entries, err := ioutil.ReadDir(destDir)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(entries).To(BeEmpty())
+					})
+
+					It("merges the streamed entries into existing directories", func() {
+						request.Params.UnpackDir = "unpacked"
+						Expect(os.MkdirAll(filepath.Join(destDir, "unpacked"), 0755)).To(Succeed())
+						Expect(ioutil.WriteFile(filepath.Join(destDir, "unpacked", "existing.txt"), []byte("existing"), 0644)).To(Succeed())
+
+						_, err := command.Run(destDir, request)
+						Expect(err).NotTo(HaveOccurred())
+
+						contents, _ := ioutil.ReadFile(filepath.Join(destDir, "unpacked", "file-0.txt"))
+						Expect(string(contents)).To(Equal("some-tgz-file-content"))
+						Expect(filepath.Join(destDir, "unpacked", "existing.txt")).To(BeAnExistingFile())
+
+						extractDirs, _ := filepath.Glob(filepath.Join(destDir, ".stream-*"))
+						Expect(extractDirs).To(BeEmpty())
+					})
+
+					It("extracts streamed tar.zst files", func() {
+						request.Version.Path = "file-0.tar.zst"
+						gcsClient.DownloadStreamStub, _ = gcsDownloadStreamStub("file-0.tar.zst", nil)
+
+						_, err := command.Run(destDir, request)
+						Expect(err).NotTo(HaveOccurred())
+
+						contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+						Expect(string(contents)).To(Equal("some-tar-zst-file-content"))
+					})
+
+					It("decompresses streamed single files", func() {
+						request.Version.Path = "file-0.txt.xz"
+						gcsClient.DownloadStreamStub, _ = gcsDownloadStreamStub("file-0.txt.xz", nil)
+
+						_, err := command.Run(destDir, request)
+						Expect(err).NotTo(HaveOccurred())
+
+						contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+						Expect(string(contents)).To(Equal("some-xz-file-content"))
+					})
+
+					It("returns an error for archives which can not be streamed", func() {
+						request.Version.Path = "file-0.zip"
+						gcsClient.DownloadStreamStub, _ = gcsDownloadStreamStub("file-0.zip", nil)
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("application/zip archives can not be streamed"))
+					})
+
+					It("returns an error if unpack is not enabled", func() {
+						request.Params.Unpack = false
+
+						_, err := command.Run(destDir, request)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("please specify unpack when using stream"))
+					})
+				})
+
+				Describe("when 'unpack' is specified", func() {
+					BeforeEach(func() {
+						request.Params.Unpack = true
+					})
+
+					Context("when a zip file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.zip"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.zip")
+						})
+
+						It("extracts the zip file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-zip-file-content"))
+						})
+					})
+
+					Context("when a tar file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.tar"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.tar")
+						})
+
+						It("extracts the tar file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-tar-file-content"))
+						})
+					})
+
+					Context("when a gzip file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.txt.gz"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.txt.gz")
+						})
+
+						It("extracts the gzip file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-gzip-file-content"))
+						})
+					})
+
+					Context("when a tgz file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.tgz"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.tgz")
+						})
+
+						It("extracts the tgz file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-tgz-file-content"))
+						})
+					})
+
+					Context("when a tar.bz2 file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.tar.bz2"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.tar.bz2")
+						})
+
+						It("extracts the tar.bz2 file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-tar-bz2-file-content"))
+						})
+					})
+
+					Context("when a tar.xz file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.tar.xz"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.tar.xz")
+						})
+
+						It("extracts the tar.xz file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-tar-xz-file-content"))
+						})
+					})
+
+					Context("when a tar.zst file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.tar.zst"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.tar.zst")
+						})
+
+						It("extracts the tar.zst file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-tar-zst-file-content"))
+						})
+					})
+
+					Context("when a xz file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.txt.xz"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.txt.xz")
+						})
+
+						It("extracts the xz file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-xz-file-content"))
+						})
+					})
+
+					Context("when a bzip2 file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.txt.bz2"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.txt.bz2")
+						})
+
+						It("extracts the bzip2 file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-bzip2-file-content"))
+						})
+					})
+
+					Context("when a zstd file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.txt.zst"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.txt.zst")
+						})
+
+						It("extracts the zstd file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-zstd-file-content"))
+						})
+					})
+
+					Context("when a 7z file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.7z"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.7z")
+						})
+
+						It("extracts the 7z file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-7z-file-content"))
+						})
+
+						It("extracts directories, modes and symlinks of compressed 7z files", func() {
+							request.Version.Path = "dir-0.7z"
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("dir-0.7z")
+
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "dir", "a.txt"))
+							Expect(string(contents)).To(Equal("a-content"))
+
+							info, err := os.Stat(filepath.Join(destDir, "dir", "b.txt"))
+							Expect(err).NotTo(HaveOccurred())
+							Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+							info, err = os.Stat(filepath.Join(destDir, "dir", "empty.txt"))
+							Expect(err).NotTo(HaveOccurred())
+							Expect(info.Size()).To(BeZero())
+
+							target, err := os.Readlink(filepath.Join(destDir, "dir", "link"))
+							Expect(err).NotTo(HaveOccurred())
+							Expect(target).To(Equal("a.txt"))
+						})
+
+						It("returns an error if it is streamed", func() {
+							request.Params.Stream = true
+							gcsClient.DownloadStreamStub, _ = gcsDownloadStreamStub("file-0.7z", nil)
+
+							_, err := command.Run(destDir, request)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("application/x-7z-compressed archives can not be streamed"))
+						})
+					})
+
+					Context("when a rar file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.rar"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.rar")
+						})
+
+						It("extracts the rar file to the destination dir", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-rar-file-content"))
+						})
+					})
+
+					Context("when an entry can not be extracted", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.tar"
+
+							gcsClient.DownloadFileStub = gcsDownloadContentStub("file-0.tar", tarArchive(
+								archiveEntry{Name: "file-0.txt", Body: "some-tar-file-content"},
+								archiveEntry{Name: "file-0.txt/nested.txt", Body: "nested"},
+							))
+						})
+
+						It("returns an error naming the entry", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("failed to extract 'file-0.tar' with the 'params.unpack' option enabled: entry 'file-0.txt/nested.txt'"))
+						})
+					})
+
+					Context("when unpack options are specified", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "app-1.0.tgz"
+
+							gcsClient.DownloadFileStub = gcsDownloadContentStub("app-1.0.tgz", gzipArchive("", tarArchive(
+								archiveEntry{Name: "app-1.0/", Typeflag: tar.TypeDir},
+								archiveEntry{Name: "app-1.0/bin/tool", Body: "tool"},
+								archiveEntry{Name: "app-1.0/bin/tool.debug", Body: "debug"},
+								archiveEntry{Name: "app-1.0/docs/readme.md", Body: "readme"},
+							)))
+						})
+
+						It("strips leading path components", func() {
+							request.Params.StripComponents = 1
+
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							Expect(filepath.Join(destDir, "bin", "tool")).To(BeAnExistingFile())
+							Expect(filepath.Join(destDir, "docs", "readme.md")).To(BeAnExistingFile())
+							Expect(filepath.Join(destDir, "app-1.0")).NotTo(BeAnExistingFile())
+						})
+
+						It("extracts only included entries which are not excluded", func() {
+							request.Params.StripComponents = 1
+							request.Params.Include = []string{"bin"}
+							request.Params.Exclude = []string{"*.debug"}
+
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							Expect(filepath.Join(destDir, "bin", "tool")).To(BeAnExistingFile())
+							Expect(filepath.Join(destDir, "bin", "tool.debug")).NotTo(BeAnExistingFile())
+							Expect(filepath.Join(destDir, "docs")).NotTo(BeAnExistingFile())
+						})
+
+						It("extracts into the unpack_dir", func() {
+							request.Params.UnpackDir = "unpacked"
+
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							Expect(filepath.Join(destDir, "unpacked", "app-1.0", "bin", "tool")).To(BeAnExistingFile())
+							Expect(filepath.Join(destDir, "app-1.0.tgz")).To(BeAnExistingFile())
+						})
+
+						It("keeps the archive by default", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							Expect(filepath.Join(destDir, "app-1.0.tgz")).To(BeAnExistingFile())
+							Expect(filepath.Join(destDir, "app-1.0.tgz.uncompressed")).To(BeAnExistingFile())
+						})
+
+						It("removes the archive and intermediates when keep_archive is false", func() {
+							request.Params.KeepArchive = "false"
+
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							Expect(filepath.Join(destDir, "app-1.0", "bin", "tool")).To(BeAnExistingFile())
+							Expect(filepath.Join(destDir, "app-1.0.tgz")).NotTo(BeAnExistingFile())
+							Expect(filepath.Join(destDir, "app-1.0.tgz.uncompressed")).NotTo(BeAnExistingFile())
+						})
+
+						It("moves a single compressed file into the unpack_dir", func() {
+							request.Version.Path = "file-0.txt.gz"
+							request.Params.UnpackDir = "unpacked"
+							request.Params.KeepArchive = "false"
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.txt.gz")
+
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "unpacked", "file-0.txt"))
+							Expect(string(contents)).To(Equal("some-gzip-file-content"))
+							Expect(filepath.Join(destDir, "file-0.txt.gz")).NotTo(BeAnExistingFile())
+						})
+
+						It("returns an error if unpack is not enabled", func() {
+							request.Params.Unpack = false
+							request.Params.StripComponents = 1
+
+							_, err := command.Run(destDir, request)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("please specify unpack when using strip_components, include, exclude, unpack_dir, keep_archive or preserve_ownership"))
+						})
+
+						It("returns an error if the unpack_dir is outside of the destination dir", func() {
+							request.Params.UnpackDir = "../unpacked"
+
+							_, err := command.Run(destDir, request)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("please specify an unpack_dir inside the destination dir"))
+						})
+
+						It("returns an error for an invalid keep_archive", func() {
+							request.Params.KeepArchive = "maybe"
+
+							_, err := command.Run(destDir, request)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("invalid keep_archive value specified: maybe"))
+						})
+					})
+
+					Context("when the archive records file metadata", func() {
+						var modTime time.Time
+
+						BeforeEach(func() {
+							modTime = time.Date(2019, time.March, 14, 15, 9, 26, 0, time.UTC)
+						})
+
+						owner := func(path string) (int, int) {
+							info, err := os.Lstat(path)
+							Expect(err).NotTo(HaveOccurred())
+
+							stat := info.Sys().(*syscall.Stat_t)
+							return int(stat.Uid), int(stat.Gid)
+						}
+
+						Context("when a tar file is returned", func() {
+							BeforeEach(func() {
+								request.Version.Path = "app-1.0.tar"
+
+								gcsClient.DownloadFileStub = gcsDownloadContentStub("app-1.0.tar", tarArchive(
+									archiveEntry{Name: "app-1.0/", Typeflag: tar.TypeDir, Mode: 0750, ModTime: modTime},
+									archiveEntry{Name: "app-1.0/tool", Body: "tool", Mode: 0755, ModTime: modTime, Uid: 1234, Gid: 5678},
+									archiveEntry{Name: "app-1.0/secret", Body: "secret", Mode: 0600, ModTime: modTime},
+								))
+							})
+
+							It("preserves mode bits and modification times", func() {
+								_, err := command.Run(destDir, request)
+								Expect(err).NotTo(HaveOccurred())
+
+								for path, mode := range map[string]os.FileMode{
+									"app-1.0":        os.ModeDir | 0750,
+									"app-1.0/tool":   0755,
+									"app-1.0/secret": 0600,
+								} {
+									info, err := os.Stat(filepath.Join(destDir, path))
+									Expect(err).NotTo(HaveOccurred())
+									Expect(info.Mode()).To(Equal(mode), path)
+									Expect(info.ModTime().Equal(modTime)).To(BeTrue(), path)
+								}
+							})
+
+							It("does not preserve ownership by default", func() {
+								_, err := command.Run(destDir, request)
+								Expect(err).NotTo(HaveOccurred())
+
+								uid, gid := owner(filepath.Join(destDir, "app-1.0", "tool"))
+								Expect(uid).To(Equal(os.Geteuid()))
+								Expect(gid).To(Equal(os.Getegid()))
+							})
+
+							It("preserves ownership when preserve_ownership is specified and running as root", func() {
+								if os.Geteuid() != 0 {
+									Skip("ownership can only be preserved as root")
+								}
+
+								request.Params.PreserveOwnership = true
+
+								_, err := command.Run(destDir, request)
+								Expect(err).NotTo(HaveOccurred())
+
+								uid, gid := owner(filepath.Join(destDir, "app-1.0", "tool"))
+								Expect(uid).To(Equal(1234))
+								Expect(gid).To(Equal(5678))
+							})
+						})
+
+						Context("when a zip file is returned", func() {
+							BeforeEach(func() {
+								request.Version.Path = "app-1.0.zip"
+
+								gcsClient.DownloadFileStub = gcsDownloadContentStub("app-1.0.zip", zipArchive(
+									archiveEntry{Name: "tool", Body: "tool", Mode: 0755, ModTime: modTime},
+								))
+							})
+
+							It("preserves mode bits and modification times", func() {
+								_, err := command.Run(destDir, request)
+								Expect(err).NotTo(HaveOccurred())
+
+								info, err := os.Stat(filepath.Join(destDir, "tool"))
+								Expect(err).NotTo(HaveOccurred())
+								Expect(info.Mode()).To(Equal(os.FileMode(0755)))
+								Expect(info.ModTime().Equal(modTime)).To(BeTrue())
+							})
+						})
+					})
+
+					Context("when an archive tries to write outside of the destination dir", func() {
+						var escapedPath string
+
+						BeforeEach(func() {
+							request.Version.Path = "file-0.tar"
+							escapedPath = filepath.Join(tmpPath, "evil.txt")
+						})
+
+						unpackFails := func(entry string, message string) {
+							_, err := command.Run(destDir, request)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("entry '" + entry + "': " + message))
+							Expect(escapedPath).NotTo(BeAnExistingFile())
+						}
+
+						It("rejects '..' path components", func() {
+							gcsClient.DownloadFileStub = gcsDownloadContentStub("file-0.tar", tarArchive(
+								archiveEntry{Name: "sub/../../evil.txt", Body: "evil"},
+							))
+
+							unpackFails("sub/../../evil.txt", "'..' path components are not allowed")
+						})
+
+						It("rejects absolute paths", func() {
+							gcsClient.DownloadFileStub = gcsDownloadContentStub("file-0.tar", tarArchive(
+								archiveEntry{Name: escapedPath, Body: "evil"},
+							))
+
+							unpackFails(escapedPath, "absolute paths are not allowed")
+						})
+
+						It("rejects symlinks escaping the destination dir", func() {
+							gcsClient.DownloadFileStub = gcsDownloadContentStub("file-0.tar", tarArchive(
+								archiveEntry{Name: "link", Linkname: "..", Typeflag: tar.TypeSymlink},
+								archiveEntry{Name: "link/evil.txt", Body: "evil"},
+							))
+
+							unpackFails("link", "symlink target '..' would be written outside of the destination dir")
+							Expect(filepath.Join(destDir, "link")).NotTo(BeAnExistingFile())
+						})
+
+						It("rejects absolute symlink targets", func() {
+							gcsClient.DownloadFileStub = gcsDownloadContentStub("file-0.tar", tarArchive(
+								archiveEntry{Name: "link", Linkname: tmpPath, Typeflag: tar.TypeSymlink},
+							))
+
+							unpackFails("link", "symlink target '"+tmpPath+"' is absolute")
+						})
+
+						It("rejects symlinks escaping through other symlinks", func() {
+							gcsClient.DownloadFileStub = gcsDownloadContentStub("file-0.tar", tarArchive(
+								archiveEntry{Name: "here", Linkname: ".", Typeflag: tar.TypeSymlink},
+								archiveEntry{Name: "link", Linkname: "here/../evil.txt", Typeflag: tar.TypeSymlink},
+							))
+
+							unpackFails("link", "symlink target 'here/../evil.txt' would be written outside of the destination dir")
+						})
+
+						It("rejects hard links escaping the destination dir", func() {
+							gcsClient.DownloadFileStub = gcsDownloadContentStub("file-0.tar", tarArchive(
+								archiveEntry{Name: "link", Linkname: "../evil.txt", Typeflag: tar.TypeLink},
+							))
+
+							unpackFails("link", "link target: '..' path components are not allowed")
+						})
+
+						It("rejects '..' path components in zip files", func() {
+							request.Version.Path = "file-0.zip"
+							gcsClient.DownloadFileStub = gcsDownloadContentStub("file-0.zip", zipArchive(
+								archiveEntry{Name: "../evil.txt", Body: "evil"},
+							))
+
+							unpackFails("../evil.txt", "'..' path components are not allowed")
+						})
+
+						It("rejects paths in gzip headers", func() {
+							request.Version.Path = "file-0.txt.gz"
+							gcsClient.DownloadFileStub = gcsDownloadContentStub("file-0.txt.gz", gzipArchive("../evil.txt", []byte("evil")))
+
+							_, err := command.Run(destDir, request)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("invalid file name '../evil.txt' in compression header"))
+							Expect(escapedPath).NotTo(BeAnExistingFile())
+						})
+
+						It("allows symlinks within the destination dir", func() {
+							gcsClient.DownloadFileStub = gcsDownloadContentStub("file-0.tar", tarArchive(
+								archiveEntry{Name: "sub/file-0.txt", Body: "some-tar-file-content"},
+								archiveEntry{Name: "link", Linkname: "sub/file-0.txt", Typeflag: tar.TypeSymlink},
+							))
+
+							_, err := command.Run(destDir, request)
+							Expect(err).NotTo(HaveOccurred())
+
+							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "link"))
+							Expect(string(contents)).To(Equal("some-tar-file-content"))
+						})
+					})
+
+					Context("when an uncompressed or unsupported file is returned", func() {
+
+						BeforeEach(func() {
+							request.Version.Path = "file.txt"
+
+							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file.txt")
+						})
+
+						It("returns an error to the user", func() {
+							_, err := command.Run(destDir, request)
+							Expect(err).To(HaveOccurred())
+							Expect(err.Error()).To(ContainSubstring("failed to extract 'file.txt' with the 'params.unpack' option enabled"))
+						})
+					})
+				})
+			})
+		})
+
+		Describe("with regexp and track_generations", func() {
+			BeforeEach(func() {
+				request.Source.Regexp = "folder/file-(.*).tgz"
+				request.Source.TrackGenerations = true
+			})
+
+			Describe("when there is no existing version in the request", func() {
+				BeforeEach(func() {
+					gcsClient.BucketObjectsInfoReturns([]*storage.Object{
+						{Name: "folder/file-0.0.1.tgz", Generation: 300},
+						{Name: "folder/file-3.53.tgz", Generation: 200},
+						{Name: "folder/file-2.4.3.tgz", Generation: 100},
+					}, nil)
+				})
+
+				It("downloads the latest generation of the latest file", func() {
+					_, err := command.Run(destDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.DownloadFileCallCount()).To(Equal(1))
+					bucketName, objectPath, generation, localPath := gcsClient.DownloadFileArgsForCall(0)
+
+					Expect(bucketName).To(Equal("bucket-name"))
+					Expect(objectPath).To(Equal("folder/file-3.53.tgz"))
+					Expect(generation).To(Equal(int64(200)))
+					Expect(localPath).To(Equal(filepath.Join(destDir, "file-3.53.tgz")))
+				})
+			})
+
+			Describe("when there is an existing version in the request", func() {
+				BeforeEach(func() {
+					request.Version.Path = "folder/file-1.3.tgz"
+					request.Version.Generation = "12345"
+					gcsClient.ObjectInfoReturns(&storage.Object{Name: "folder/file-1.3.tgz", Generation: 12345}, nil)
+				})
+
+				It("downloads the requested generation of the file", func() {
+					_, err := command.Run(destDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.DownloadFileCallCount()).To(Equal(1))
+					bucketName, objectPath, generation, localPath := gcsClient.DownloadFileArgsForCall(0)
+
+					Expect(bucketName).To(Equal("bucket-name"))
+					Expect(objectPath).To(Equal("folder/file-1.3.tgz"))
+					Expect(generation).To(Equal(int64(12345)))
+					Expect(localPath).To(Equal(filepath.Join(destDir, "file-1.3.tgz")))
+				})
+
+				It("creates 'version' and 'generation' files", func() {
+					_, err := command.Run(destDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					contents, err := ioutil.ReadFile(filepath.Join(destDir, "version"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(contents)).To(Equal("1.3"))
+
+					contents, err = ioutil.ReadFile(filepath.Join(destDir, "generation"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(contents)).To(Equal("12345"))
+				})
+
+				It("returns a response", func() {
+					gcsClient.URLReturns("gs://bucket-name/folder/file-1.3.tgz#12345", nil)
+
+					response, err := command.Run(destDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					bucketName, objectPath, generation := gcsClient.URLArgsForCall(0)
+					Expect(bucketName).To(Equal("bucket-name"))
+					Expect(objectPath).To(Equal("folder/file-1.3.tgz"))
+					Expect(generation).To(Equal(int64(12345)))
+
+					Expect(response.Version.Path).To(Equal("folder/file-1.3.tgz"))
+					Expect(response.Version.Generation).To(Equal("12345"))
+
+					Expect(response.Metadata[1].Name).To(Equal("url"))
+					Expect(response.Metadata[1].Value).To(Equal("gs://bucket-name/folder/file-1.3.tgz#12345"))
+				})
+
+				It("reports the holds of the requested generation", func() {
+					gcsClient.ObjectInfoReturns(&storage.Object{Name: "folder/file-1.3.tgz", Generation: 12345, EventBasedHold: true}, nil)
+
+					response, err := command.Run(destDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					_, objectPath, generation := gcsClient.ObjectInfoArgsForCall(0)
+					Expect(objectPath).To(Equal("folder/file-1.3.tgz"))
+					Expect(generation).To(Equal(int64(12345)))
+					Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "event_based_hold", Value: "true"}))
+				})
+
+				It("returns an error when the generation is invalid", func() {
+					request.Version.Generation = "foo"
+
+					_, err := command.Run(destDir, request)
+					Expect(err).To(HaveOccurred())
 				})
 
-				Describe("when 'skip_download' is specified locally", func() {
-					BeforeEach(func() {
-						request.Params.SkipDownload = "true"
-					})
+				It("returns an error without downloading when the version has no generation", func() {
+					request.Version.Generation = ""
 
-					It("skips the download of the file", func() {
-						_, err := command.Run(destDir, request)
-						Expect(err).ToNot(HaveOccurred())
+					_, err := command.Run(destDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("please specify the generation of 'folder/file-1.3.tgz' when using track_generations"))
+					Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+				})
+			})
+		})
 
-						Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
-					})
+		Describe("with regexp and version_from", func() {
+			BeforeEach(func() {
+				request.Source.Regexp = "folder/latest.tgz"
+				request.Source.VersionFrom = "updated"
+			})
+
+			Describe("when there is no existing version in the request", func() {
+				BeforeEach(func() {
+					gcsClient.BucketObjectsInfoReturns([]*storage.Object{
+						{Name: "folder/latest.tgz", Updated: "2018-01-03T00:00:00Z"},
+					}, nil)
+					gcsClient.ObjectInfoReturns(&storage.Object{Name: "folder/latest.tgz", Updated: "2018-01-03T00:00:00Z"}, nil)
 				})
 
-				Describe("when 'skip_download' is specified globally, but overridden locally", func() {
-					BeforeEach(func() {
-						request.Source.SkipDownload = true
-						request.Params.SkipDownload = "false"
-					})
+				It("downloads the latest object", func() {
+					response, err := command.Run(destDir, request)
+					Expect(err).ToNot(HaveOccurred())
 
-					It("downloads the existing version of the file", func() {
-						_, err := command.Run(destDir, request)
-						Expect(err).ToNot(HaveOccurred())
+					Expect(gcsClient.DownloadFileCallCount()).To(Equal(1))
+					_, objectPath, generation, _ := gcsClient.DownloadFileArgsForCall(0)
+					Expect(objectPath).To(Equal("folder/latest.tgz"))
+					Expect(generation).To(Equal(int64(0)))
 
-						Expect(gcsClient.DownloadFileCallCount()).To(Equal(1))
-						bucketName, objectPath, generation, localPath := gcsClient.DownloadFileArgsForCall(0)
+					Expect(response.Version).To(Equal(gcsresource.Version{Path: "folder/latest.tgz", Value: "2018-01-03T00:00:00Z"}))
+				})
+			})
 
-						Expect(bucketName).To(Equal("bucket-name"))
-						Expect(objectPath).To(Equal("folder/file-1.3.tgz"))
-						Expect(generation).To(Equal(int64(0)))
-						Expect(localPath).To(Equal(filepath.Join(destDir, "file-1.3.tgz")))
-					})
+			Describe("when there is an existing version in the request", func() {
+				BeforeEach(func() {
+					request.Version = gcsresource.Version{Path: "folder/latest.tgz", Value: "2018-01-02T00:00:00Z"}
+					gcsClient.ObjectInfoReturns(&storage.Object{Name: "folder/latest.tgz", Updated: "2018-01-02T00:00:00Z"}, nil)
 				})
 
-				Describe("when 'unpack' is specified", func() {
-					BeforeEach(func() {
-						request.Params.Unpack = true
-					})
+				It("creates a 'version' file that contains the value", func() {
+					response, err := command.Run(destDir, request)
+					Expect(err).ToNot(HaveOccurred())
 
-					Context("when a zip file is returned", func() {
+					contents, err := ioutil.ReadFile(filepath.Join(destDir, "version"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(contents)).To(Equal("2018-01-02T00:00:00Z"))
 
-						BeforeEach(func() {
-							request.Version.Path = "file-0.zip"
+					Expect(response.Version).To(Equal(request.Version))
+				})
 
-							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.zip")
-						})
+				It("returns an error without downloading when the object has changed", func() {
+					gcsClient.ObjectInfoReturns(&storage.Object{Name: "folder/latest.tgz", Updated: "2018-01-03T00:00:00Z"}, nil)
 
-						It("extracts the zip file to the destination dir", func() {
-							_, err := command.Run(destDir, request)
-							Expect(err).NotTo(HaveOccurred())
+					_, err := command.Run(destDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("object 'folder/latest.tgz' has changed: version_from value is '2018-01-03T00:00:00Z', not '2018-01-02T00:00:00Z'"))
+					Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+				})
+			})
 
-							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
-							Expect(string(contents)).To(Equal("some-zip-file-content"))
-						})
-					})
+			It("returns an error when version_from is invalid", func() {
+				request.Source.VersionFrom = "size"
+				request.Version = gcsresource.Version{Path: "folder/latest.tgz", Value: "1"}
 
-					Context("when a tar file is returned", func() {
+				_, err := command.Run(destDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("invalid version_from value specified"))
+			})
+		})
 
-						BeforeEach(func() {
-							request.Version.Path = "file-0.tar"
+		Describe("with prefix_regexp", func() {
+			BeforeEach(func() {
+				request.Source.PrefixRegexp = `builds/(\d+)/`
+				request.Version.Path = "builds/1234/"
+
+				gcsClient.BucketObjectsReturns([]string{
+					"builds/1234/",
+					"builds/1234/a.tgz",
+					"builds/1234/meta/b.json",
+				}, nil)
+			})
 
-							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.tar")
-						})
+			It("downloads every object under the prefix preserving relative paths", func() {
+				_, err := command.Run(destDir, request)
+				Expect(err).ToNot(HaveOccurred())
 
-						It("extracts the tar file to the destination dir", func() {
-							_, err := command.Run(destDir, request)
-							Expect(err).NotTo(HaveOccurred())
+				bucketName, prefix := gcsClient.BucketObjectsArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(prefix).To(Equal("builds/1234/"))
 
-							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
-							Expect(string(contents)).To(Equal("some-tar-file-content"))
-						})
-					})
+				Expect(gcsClient.DownloadFileCallCount()).To(Equal(2))
+				_, objectPath, _, localPath := gcsClient.DownloadFileArgsForCall(0)
+				Expect(objectPath).To(Equal("builds/1234/a.tgz"))
+				Expect(localPath).To(Equal(filepath.Join(destDir, "a.tgz")))
 
-					Context("when a gzip file is returned", func() {
+				_, objectPath, _, localPath = gcsClient.DownloadFileArgsForCall(1)
+				Expect(objectPath).To(Equal("builds/1234/meta/b.json"))
+				Expect(localPath).To(Equal(filepath.Join(destDir, "meta", "b.json")))
+				Expect(filepath.Join(destDir, "meta")).To(BeADirectory())
+			})
 
-						BeforeEach(func() {
-							request.Version.Path = "file-0.txt.gz"
+			It("returns an error if companions are specified", func() {
+				request.Params.Companions = []string{".sig"}
 
-							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.txt.gz")
-						})
+				_, err := command.Run(destDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("companions and files are not supported with prefix_regexp"))
+			})
 
-						It("extracts the gzip file to the destination dir", func() {
-							_, err := command.Run(destDir, request)
-							Expect(err).NotTo(HaveOccurred())
+			It("creates 'version' and 'url' files", func() {
+				_, err := command.Run(destDir, request)
+				Expect(err).ToNot(HaveOccurred())
 
-							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
-							Expect(string(contents)).To(Equal("some-gzip-file-content"))
-						})
-					})
+				contents, err := ioutil.ReadFile(filepath.Join(destDir, "version"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).To(Equal("1234"))
 
-					Context("when a tgz file is returned", func() {
+				contents, err = ioutil.ReadFile(filepath.Join(destDir, "url"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).To(Equal("gs://bucket-name/builds/1234/"))
+			})
 
-						BeforeEach(func() {
-							request.Version.Path = "file-0.tgz"
+			It("returns a response", func() {
+				response, err := command.Run(destDir, request)
+				Expect(err).ToNot(HaveOccurred())
 
-							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file-0.tgz")
-						})
+				Expect(response.Version.Path).To(Equal("builds/1234/"))
+				Expect(response.Metadata[0].Name).To(Equal("filename"))
+				Expect(response.Metadata[0].Value).To(Equal("1234"))
+			})
 
-						It("extracts the tgz file to the destination dir", func() {
-							_, err := command.Run(destDir, request)
-							Expect(err).NotTo(HaveOccurred())
+			It("uses the latest prefix when there is no existing version in the request", func() {
+				request.Version.Path = ""
+				gcsClient.BucketPrefixesReturns([]string{"builds/99/", "builds/1234/"}, nil)
 
-							contents, _ := ioutil.ReadFile(filepath.Join(destDir, "file-0.txt"))
-							Expect(string(contents)).To(Equal("some-tgz-file-content"))
-						})
-					})
+				response, err := command.Run(destDir, request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.Version.Path).To(Equal("builds/1234/"))
+			})
 
-					Context("when an uncompressed or unsupported file is returned", func() {
+			It("returns an error when the prefix is empty", func() {
+				gcsClient.BucketObjectsReturns([]string{}, nil)
 
-						BeforeEach(func() {
-							request.Version.Path = "file.txt"
+				_, err := command.Run(destDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("no objects found under prefix: builds/1234/"))
+			})
 
-							gcsClient.DownloadFileStub = gcsDownloadTaskStub("file.txt")
-						})
+			It("refuses objects that would escape the destination dir", func() {
+				gcsClient.BucketObjectsReturns([]string{"builds/1234/../../evil"}, nil)
 
-						It("returns an error to the user", func() {
-							_, err := command.Run(destDir, request)
-							Expect(err).To(HaveOccurred())
-							Expect(err.Error()).To(ContainSubstring("failed to extract 'file.txt' with the 'params.unpack' option enabled"))
-						})
-					})
-				})
+				_, err := command.Run(destDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("would be written outside of the destination dir"))
+				Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+			})
+
+			It("skips the download when 'skip_download' is specified", func() {
+				request.Params.SkipDownload = "true"
+
+				_, err := command.Run(destDir, request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(gcsClient.BucketObjectsCallCount()).To(Equal(0))
 			})
 		})
 
@@ -547,6 +1798,234 @@ var _ = Describe("In Command", func() {
 				Expect(err.Error()).To(ContainSubstring("error url"))
 			})
 
+			It("writes a signed url with signed_url_ttl", func() {
+				request.Params.SignedURLTTL = "1h"
+				gcsClient.SignedURLReturns("https://storage.googleapis.com/bucket-name/folder/version?generation=12345&X-Goog-Signature=abc", nil)
+
+				response, err := command.Run(destDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.SignedURLCallCount()).To(Equal(1))
+				bucketName, objectPath, generation, ttl := gcsClient.SignedURLArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(objectPath).To(Equal("folder/version"))
+				Expect(generation).To(Equal(int64(12345)))
+				Expect(ttl).To(Equal(time.Hour))
+
+				contents, err := ioutil.ReadFile(filepath.Join(destDir, "signed_url"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).To(Equal("https://storage.googleapis.com/bucket-name/folder/version?generation=12345&X-Goog-Signature=abc"))
+
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{
+					Name:  "signed_url",
+					Value: "https://storage.googleapis.com/bucket-name/folder/version?generation=12345&X-Goog-Signature=abc",
+				}))
+			})
+
+			It("does not sign a url without signed_url_ttl", func() {
+				_, err := command.Run(destDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.SignedURLCallCount()).To(Equal(0))
+				Expect(filepath.Join(destDir, "signed_url")).ToNot(BeAnExistingFile())
+			})
+
+			It("returns an error if the url can not be signed", func() {
+				request.Params.SignedURLTTL = "1h"
+				gcsClient.SignedURLReturns("", errors.New("failed to sign url: no private key"))
+
+				_, err := command.Run(destDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("failed to sign url: no private key"))
+			})
+
+			It("returns an error for an invalid signed_url_ttl", func() {
+				request.Params.SignedURLTTL = "8d"
+
+				_, err := command.Run(destDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid signed_url_ttl value specified: 8d"))
+			})
+
+			It("returns an error for a signed_url_ttl longer than 7 days", func() {
+				request.Params.SignedURLTTL = "169h"
+
+				_, err := command.Run(destDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid signed_url_ttl value specified: 169h"))
+			})
+
+			It("writes the object resource to an 'object.json' file", func() {
+				gcsClient.ObjectInfoReturns(&storage.Object{
+					Bucket:         "bucket-name",
+					Name:           "folder/version",
+					Generation:     12345,
+					Metageneration: 2,
+					Size:           1024,
+					Md5Hash:        "1B2M2Y8AsgTpgAmY7PhCfg==",
+					Crc32c:         "AAAAAA==",
+					ContentType:    "application/gzip",
+					Metadata:       map[string]string{"commit": "abc123"},
+					TimeCreated:    "2026-10-01T12:00:00.000Z",
+					Updated:        "2026-10-02T12:00:00.000Z",
+				}, nil)
+
+				_, err := command.Run(destDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				bucketName, objectPath, generation := gcsClient.ObjectInfoArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(objectPath).To(Equal("folder/version"))
+				Expect(generation).To(Equal(int64(12345)))
+
+				contents, err := ioutil.ReadFile(filepath.Join(destDir, "object.json"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(contents).To(MatchJSON(`{
+					"bucket": "bucket-name",
+					"name": "folder/version",
+					"generation": "12345",
+					"metageneration": "2",
+					"size": "1024",
+					"md5Hash": "1B2M2Y8AsgTpgAmY7PhCfg==",
+					"crc32c": "AAAAAA==",
+					"contentType": "application/gzip",
+					"metadata": {"commit": "abc123"},
+					"timeCreated": "2026-10-01T12:00:00.000Z",
+					"updated": "2026-10-02T12:00:00.000Z"
+				}`))
+			})
+
+			It("reports the object resource in the metadata", func() {
+				gcsClient.URLReturns("gs://bucket-name/folder/version#12345", nil)
+				gcsClient.ObjectInfoReturns(&storage.Object{
+					Bucket:       "bucket-name",
+					Name:         "folder/version",
+					MediaLink:    "https://storage.googleapis.com/download/storage/v1/b/bucket-name/o/folder%2Fversion?generation=12345&alt=media",
+					Size:         1572864,
+					ContentType:  "application/gzip",
+					Md5Hash:      "1B2M2Y8AsgTpgAmY7PhCfg==",
+					Crc32c:       "AAAAAA==",
+					StorageClass: "STANDARD",
+					Updated:      "2026-10-01T12:00:00.000Z",
+				}, nil)
+
+				response, err := command.Run(destDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(response.Metadata).To(Equal([]gcsresource.MetadataPair{
+					{Name: "filename", Value: "version"},
+					{Name: "url", Value: "gs://bucket-name/folder/version#12345"},
+					{Name: "media_link", Value: "https://storage.googleapis.com/download/storage/v1/b/bucket-name/o/folder%2Fversion?generation=12345&alt=media"},
+					{Name: "console_url", Value: "https://console.cloud.google.com/storage/browser/_details/bucket-name/folder/version"},
+					{Name: "size", Value: "1.5 MiB"},
+					{Name: "content_type", Value: "application/gzip"},
+					{Name: "md5_hash", Value: "d41d8cd98f00b204e9800998ecf8427e"},
+					{Name: "crc32c", Value: "00000000"},
+					{Name: "storage_class", Value: "STANDARD"},
+					{Name: "updated", Value: "2026-10-01T12:00:00.000Z"},
+				}))
+			})
+
+			It("reports the holds and retention of the generation", func() {
+				gcsClient.ObjectInfoReturns(&storage.Object{
+					TemporaryHold:           true,
+					EventBasedHold:          true,
+					RetentionExpirationTime: "2027-01-01T00:00:00Z",
+				}, nil)
+
+				response, err := command.Run(destDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				bucketName, objectPath, generation := gcsClient.ObjectInfoArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(objectPath).To(Equal("folder/version"))
+				Expect(generation).To(Equal(int64(12345)))
+
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "temporary_hold", Value: "true"}))
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "event_based_hold", Value: "true"}))
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "retention_expiration_time", Value: "2027-01-01T00:00:00Z"}))
+			})
+
+			It("does not report holds that are not placed", func() {
+				response, err := command.Run(destDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				for _, pair := range response.Metadata {
+					Expect([]string{"temporary_hold", "event_based_hold", "retention_expiration_time"}).ToNot(ContainElement(pair.Name))
+				}
+			})
+
+			It("returns an error if the object info can not be fetched", func() {
+				gcsClient.ObjectInfoReturns(nil, errors.New("error getting object"))
+
+				_, err := command.Run(destDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("error getting object"))
+			})
+
+			Describe("when 'companions' are specified", func() {
+				BeforeEach(func() {
+					request.Params.Companions = []string{".sig"}
+					gcsClient.ObjectInfoReturns(&storage.Object{Name: "folder/version", Generation: 12345, TimeCreated: "2026-10-01T12:00:00.000Z"}, nil)
+				})
+
+				It("pins companions to the live generations created before the version", func() {
+					gcsClient.GetBucketObjectInfoReturns(&storage.Object{Name: "folder/version.sig", Generation: 67890, TimeCreated: "2026-10-01T11:59:59.500Z"}, nil)
+
+					_, err := command.Run(destDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.ObjectGenerationsInfoCallCount()).To(Equal(0))
+					Expect(gcsClient.DownloadFileCallCount()).To(Equal(2))
+					_, objectPath, generation, localPath := gcsClient.DownloadFileArgsForCall(0)
+					Expect(objectPath).To(Equal("folder/version.sig"))
+					Expect(generation).To(Equal(int64(67890)))
+					Expect(localPath).To(Equal(filepath.Join(destDir, "version.sig")))
+				})
+
+				It("pins companions replaced after the version to the generations matching it", func() {
+					gcsClient.GetBucketObjectInfoReturns(&storage.Object{Name: "folder/version.sig", Generation: 99999, TimeCreated: "2026-10-02T12:00:00.000Z"}, nil)
+					gcsClient.ObjectGenerationsInfoReturns([]*storage.Object{
+						{Name: "folder/version.sig", Generation: 11111, TimeCreated: "2026-09-30T12:00:00.000Z"},
+						{Name: "folder/version.sig", Generation: 67890, TimeCreated: "2026-10-01T11:59:59.500Z"},
+						{Name: "folder/version.sig", Generation: 99999, TimeCreated: "2026-10-02T12:00:00.000Z"},
+					}, nil)
+
+					_, err := command.Run(destDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					bucketName, objectPath := gcsClient.ObjectGenerationsInfoArgsForCall(0)
+					Expect(bucketName).To(Equal("bucket-name"))
+					Expect(objectPath).To(Equal("folder/version.sig"))
+
+					_, objectPath, generation, _ := gcsClient.DownloadFileArgsForCall(0)
+					Expect(objectPath).To(Equal("folder/version.sig"))
+					Expect(generation).To(Equal(int64(67890)))
+				})
+
+				It("fails before downloading anything if a companion did not exist when the version was created", func() {
+					gcsClient.GetBucketObjectInfoReturns(&storage.Object{Name: "folder/version.sig", Generation: 99999, TimeCreated: "2026-10-02T12:00:00.000Z"}, nil)
+					gcsClient.ObjectGenerationsInfoReturns([]*storage.Object{
+						{Name: "folder/version.sig", Generation: 99999, TimeCreated: "2026-10-02T12:00:00.000Z"},
+					}, nil)
+
+					_, err := command.Run(destDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("required companion 'folder/version.sig' did not exist when the version was created"))
+					Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+				})
+
+				It("fails before downloading anything if the generations of a companion can not be listed", func() {
+					gcsClient.GetBucketObjectInfoReturns(&storage.Object{Name: "folder/version.sig", Generation: 99999, TimeCreated: "2026-10-02T12:00:00.000Z"}, nil)
+					gcsClient.ObjectGenerationsInfoReturns(nil, errors.New("bucket is not versioned"))
+
+					_, err := command.Run(destDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("failed to list the generations of companion 'folder/version.sig': bucket is not versioned"))
+					Expect(gcsClient.DownloadFileCallCount()).To(Equal(0))
+				})
+			})
+
 			Describe("when 'skip_download' is specified globally", func() {
 				BeforeEach(func() {
 					request.Source.SkipDownload = true
@@ -1,6 +1,10 @@
 package in_test
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -8,8 +12,10 @@ import (
 
 	"github.com/onsi/gomega/gexec"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 var inPath string
@@ -53,3 +59,161 @@ func gcsDownloadTaskStub(name string) gcsDownloadTask {
 		return nil
 	}
 }
+
+type gcsDownloadStreamTask func(bucketName string, objectPath string, generation int64) (io.ReadCloser, error)
+
+// gcsDownloadStreamStub streams a fixture, failing with streamErr once the
+// end of the fixture is read, the way a checksum mismatch does.
+func gcsDownloadStreamStub(name string, streamErr error) (gcsDownloadStreamTask, *streamReader) {
+	stream := &streamReader{err: streamErr}
+
+	return func(bucketName string, objectPath string, generation int64) (io.ReadCloser, error) {
+		sourcePath := filepath.Join("fixtures", name)
+		Expect(sourcePath).To(BeAnExistingFile())
+
+		content, err := ioutil.ReadFile(sourcePath)
+		Expect(err).NotTo(HaveOccurred())
+
+		stream.reader = bytes.NewReader(content)
+		return stream, nil
+	}, stream
+}
+
+type streamReader struct {
+	reader *bytes.Reader
+	err    error
+	done   bool
+	closed bool
+}
+
+func (stream *streamReader) Read(p []byte) (int, error) {
+	n, err := stream.reader.Read(p)
+	if err == io.EOF {
+		stream.done = true
+		if stream.err != nil {
+			return n, stream.err
+		}
+	}
+
+	return n, err
+}
+
+func (stream *streamReader) Close() error {
+	stream.closed = true
+	return nil
+}
+
+type archiveEntry struct {
+	Name     string
+	Linkname string
+	Typeflag byte
+	Body     string
+	Mode     os.FileMode
+	ModTime  time.Time
+	Uid      int
+	Gid      int
+}
+
+func (entry archiveEntry) mode() os.FileMode {
+	if entry.Mode != 0 {
+		return entry.Mode
+	}
+
+	if entry.Typeflag == tar.TypeDir {
+		return 0755
+	}
+
+	return 0644
+}
+
+// gcsDownloadContentStub downloads an object with the given content.
+func gcsDownloadContentStub(name string, content []byte) gcsDownloadTask {
+	return func(bucketName string, objectPath string, generation int64, localPath string) error {
+		destinationDir := filepath.Dir(localPath)
+
+		err := ioutil.WriteFile(filepath.Join(destinationDir, name), content, 0600)
+		Expect(err).NotTo(HaveOccurred())
+
+		return nil
+	}
+}
+
+// gcsDownloadObjectsStub downloads the content of each object to the
+// requested local path.
+func gcsDownloadObjectsStub(objects map[string][]byte) gcsDownloadTask {
+	return func(bucketName string, objectPath string, generation int64, localPath string) error {
+		content, ok := objects[objectPath]
+		Expect(ok).To(BeTrue(), objectPath)
+
+		err := ioutil.WriteFile(localPath, content, 0600)
+		Expect(err).NotTo(HaveOccurred())
+
+		return nil
+	}
+}
+
+func tarArchive(entries ...archiveEntry) []byte {
+	var buffer bytes.Buffer
+
+	archive := tar.NewWriter(&buffer)
+	for _, entry := range entries {
+		typeflag := entry.Typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+
+		err := archive.WriteHeader(&tar.Header{
+			Name:     entry.Name,
+			Linkname: entry.Linkname,
+			Typeflag: typeflag,
+			Mode:     int64(entry.mode()),
+			ModTime:  entry.ModTime,
+			Uid:      entry.Uid,
+			Gid:      entry.Gid,
+			Size:     int64(len(entry.Body)),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = archive.Write([]byte(entry.Body))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(archive.Close()).To(Succeed())
+
+	return buffer.Bytes()
+}
+
+func zipArchive(entries ...archiveEntry) []byte {
+	var buffer bytes.Buffer
+
+	archive := zip.NewWriter(&buffer)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.Name, Method: zip.Deflate, Modified: entry.ModTime}
+		header.SetMode(entry.mode())
+		if entry.Typeflag == tar.TypeSymlink {
+			header.SetMode(os.ModeSymlink | 0777)
+			entry.Body = entry.Linkname
+		}
+
+		writer, err := archive.CreateHeader(header)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = writer.Write([]byte(entry.Body))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(archive.Close()).To(Succeed())
+
+	return buffer.Bytes()
+}
+
+func gzipArchive(name string, content []byte) []byte {
+	var buffer bytes.Buffer
+
+	archive := gzip.NewWriter(&buffer)
+	archive.Name = name
+
+	_, err := archive.Write(content)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(archive.Close()).To(Succeed())
+
+	return buffer.Bytes()
+}
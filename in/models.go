@@ -1,6 +1,12 @@
 package in
 
 import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
 	gcsresource "github.com/syslxg/gcs-resource"
 )
 
@@ -11,8 +17,103 @@ type InRequest struct {
 }
 
 type Params struct {
-	SkipDownload string `json:"skip_download"`
-	Unpack       bool   `json:"unpack"`
+	SkipDownload      string   `json:"skip_download"`
+	Unpack            bool     `json:"unpack"`
+	StripComponents   int      `json:"strip_components"`
+	Include           []string `json:"include"`
+	Exclude           []string `json:"exclude"`
+	UnpackDir         string   `json:"unpack_dir"`
+	KeepArchive       string   `json:"keep_archive"`
+	PreserveOwnership bool     `json:"preserve_ownership"`
+	Stream            bool     `json:"stream"`
+	Companions        []string `json:"companions"`
+	Files             []string `json:"files"`
+	Verify            bool     `json:"verify"`
+	ChecksumFiles     []string `json:"checksum_files"`
+	SignedURLTTL      string   `json:"signed_url_ttl"`
+}
+
+func (params Params) IsValid() (bool, string) {
+	usesUnpackOptions := params.StripComponents != 0 ||
+		len(params.Include) > 0 ||
+		len(params.Exclude) > 0 ||
+		params.UnpackDir != "" ||
+		params.KeepArchive != "" ||
+		params.PreserveOwnership
+
+	if usesUnpackOptions && !params.Unpack {
+		return false, "please specify unpack when using strip_components, include, exclude, unpack_dir, keep_archive or preserve_ownership"
+	}
+
+	if params.Stream && !params.Unpack {
+		return false, "please specify unpack when using stream"
+	}
+
+	if params.Stream && params.KeepArchive != "" {
+		return false, "keep_archive is not supported with stream"
+	}
+
+	if params.Stream && params.Verify {
+		return false, "verify is not supported with stream"
+	}
+
+	if params.Stream && len(params.ChecksumFiles) > 0 {
+		return false, "checksum_files is not supported with stream"
+	}
+
+	for _, algorithm := range params.ChecksumFiles {
+		if !gcsresource.IsChecksumAlgorithm(algorithm) {
+			return false, fmt.Sprintf("invalid checksum_files value specified: %s", algorithm)
+		}
+	}
+
+	if params.StripComponents < 0 {
+		return false, "please specify a non-negative strip_components"
+	}
+
+	for _, pattern := range append(params.Include, params.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return false, fmt.Sprintf("invalid glob pattern specified: %s", pattern)
+		}
+	}
+
+	if params.UnpackDir != "" {
+		unpackDir := filepath.Clean(params.UnpackDir)
+		if filepath.IsAbs(unpackDir) || unpackDir == ".." || strings.HasPrefix(unpackDir, ".."+string(filepath.Separator)) {
+			return false, "please specify an unpack_dir inside the destination dir"
+		}
+	}
+
+	if params.KeepArchive != "" {
+		if _, err := strconv.ParseBool(params.KeepArchive); err != nil {
+			return false, fmt.Sprintf("invalid keep_archive value specified: %s", params.KeepArchive)
+		}
+	}
+
+	if params.SignedURLTTL != "" {
+		if _, err := gcsresource.ParseSignedURLTTL(params.SignedURLTTL); err != nil {
+			return false, fmt.Sprintf("invalid signed_url_ttl value specified: %s", params.SignedURLTTL)
+		}
+	}
+
+	for _, text := range append(params.Companions, params.Files...) {
+		if _, err := parseObjectTemplate(text); err != nil {
+			return false, fmt.Sprintf("invalid template specified: %s", text)
+		}
+	}
+
+	return true, ""
+}
+
+// KeepArchiveValue reports whether the downloaded archive is kept after
+// unpacking, which is the default.
+func (params Params) KeepArchiveValue() bool {
+	keepArchive, err := strconv.ParseBool(params.KeepArchive)
+	if err != nil {
+		return true
+	}
+
+	return keepArchive
 }
 
 type InResponse struct {
@@ -0,0 +1,1113 @@
+package in
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+	"unicode/utf16"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// sevenZipArchive reads 7z archives. Only unencrypted folders with a single
+// coder are supported: copy, LZMA, LZMA2, deflate and bzip2, which covers
+// the archives 7-Zip and libarchive write by default for non-executables.
+type sevenZipArchive struct {
+	file    *os.File
+	folders []sevenZipFolder
+	entries []sevenZipEntry
+}
+
+// sevenZipFolder is a compressed block of an archive, holding the contents
+// of one or more entries back to back.
+type sevenZipFolder struct {
+	coder      string
+	properties []byte
+
+	packOffset int64
+	packSize   int64
+	unpackSize int64
+
+	streams []sevenZipStream
+}
+
+type sevenZipStream struct {
+	size   int64
+	crc    uint32
+	hasCRC bool
+}
+
+type sevenZipEntry struct {
+	name    string
+	mode    os.FileMode
+	modTime time.Time
+
+	// entries with a stream are read from the given stream of a folder
+	hasStream bool
+	folder    int
+	stream    int
+}
+
+const (
+	sevenZipEnd = iota
+	sevenZipHeader
+	sevenZipArchiveProperties
+	sevenZipAdditionalStreamsInfo
+	sevenZipMainStreamsInfo
+	sevenZipFilesInfo
+	sevenZipPackInfo
+	sevenZipUnpackInfo
+	sevenZipSubStreamsInfo
+	sevenZipSize
+	sevenZipCRC
+	sevenZipFolderID
+	sevenZipCodersUnpackSize
+	sevenZipNumUnpackStream
+	sevenZipEmptyStream
+	sevenZipEmptyFile
+	sevenZipAnti
+	sevenZipName
+	sevenZipCTime
+	sevenZipATime
+	sevenZipMTime
+	sevenZipWinAttributes
+	sevenZipComment
+	sevenZipEncodedHeader
+)
+
+const (
+	sevenZipCopy    = "\x00"
+	sevenZipLZMA    = "\x03\x01\x01"
+	sevenZipLZMA2   = "\x21"
+	sevenZipDeflate = "\x04\x01\x08"
+	sevenZipBzip2   = "\x04\x02\x02"
+
+	sevenZipSignatureHeaderSize = 32
+
+	// encoded headers are decoded in memory
+	sevenZipMaxHeaderSize = 64 << 20
+)
+
+var (
+	sevenZipSignature = []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}
+
+	errSevenZipCorrupt = errors.New("corrupt 7z header")
+)
+
+func openSevenZip(path string) (*sevenZipArchive, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &sevenZipArchive{file: file}
+	if err := archive.readHeaders(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+func (archive *sevenZipArchive) Close() error {
+	return archive.file.Close()
+}
+
+// walk calls extract with every entry of the archive, in order, and a reader
+// of its contents. The checksum of the contents is verified once extract
+// returned, after reading what it left.
+func (archive *sevenZipArchive) walk(extract func(entry sevenZipEntry, reader io.Reader) error) error {
+	var (
+		folder  = -1
+		decoder io.Reader
+		closer  io.Closer
+	)
+	defer func() {
+		if closer != nil {
+			closer.Close()
+		}
+	}()
+
+	for _, entry := range archive.entries {
+		if !entry.hasStream {
+			if err := extract(entry, bytes.NewReader(nil)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if entry.folder != folder {
+			if closer != nil {
+				closer.Close()
+			}
+
+			var err error
+			decoder, closer, err = archive.folderReader(archive.folders[entry.folder])
+			if err != nil {
+				return entryError(entry.name, err)
+			}
+			folder = entry.folder
+		}
+
+		stream := archive.folders[entry.folder].streams[entry.stream]
+		limited := &io.LimitedReader{R: decoder, N: stream.size}
+		checksum := crc32.NewIEEE()
+
+		if err := extract(entry, io.TeeReader(limited, checksum)); err != nil {
+			return err
+		}
+
+		if err := verifySevenZipStream(limited, checksum, stream); err != nil {
+			return entryError(entry.name, err)
+		}
+	}
+
+	return nil
+}
+
+// verifySevenZipStream reads the rest of a stream and checks its size and
+// checksum.
+func verifySevenZipStream(reader *io.LimitedReader, checksum hash.Hash32, stream sevenZipStream) error {
+	if _, err := io.Copy(checksum, reader); err != nil {
+		return err
+	}
+
+	if reader.N != 0 {
+		return io.ErrUnexpectedEOF
+	}
+
+	if stream.hasCRC && checksum.Sum32() != stream.crc {
+		return errors.New("7z checksum mismatch")
+	}
+
+	return nil
+}
+
+// folderReader returns a reader of the decoded contents of a folder.
+func (archive *sevenZipArchive) folderReader(folder sevenZipFolder) (io.Reader, io.Closer, error) {
+	packed := io.NewSectionReader(archive.file, folder.packOffset, folder.packSize)
+
+	// the dictionary is allocated up front, but never needs to be larger
+	// than the contents
+	dictCap := func(dictCap int64) int {
+		if dictCap > folder.unpackSize {
+			dictCap = folder.unpackSize
+		}
+		if dictCap < lzma.MinDictCap {
+			dictCap = lzma.MinDictCap
+		}
+		return int(dictCap)
+	}
+
+	var (
+		decoder io.Reader
+		closer  io.Closer
+		err     error
+	)
+	switch folder.coder {
+	case sevenZipCopy:
+		decoder = packed
+	case sevenZipLZMA:
+		if len(folder.properties) != 5 {
+			return nil, nil, errSevenZipCorrupt
+		}
+
+		// 7z stores the properties of the .lzma header, without the size
+		header := make([]byte, lzma.HeaderLen)
+		header[0] = folder.properties[0]
+		binary.LittleEndian.PutUint32(header[1:], uint32(dictCap(int64(binary.LittleEndian.Uint32(folder.properties[1:])))))
+		binary.LittleEndian.PutUint64(header[5:], uint64(folder.unpackSize))
+
+		decoder, err = lzma.NewReader(io.MultiReader(bytes.NewReader(header), packed))
+	case sevenZipLZMA2:
+		if len(folder.properties) != 1 || folder.properties[0] > 40 {
+			return nil, nil, errSevenZipCorrupt
+		}
+
+		size := int64(0xffffffff)
+		if bits := folder.properties[0]; bits < 40 {
+			size = int64(2|bits&1) << (bits/2 + 11)
+		}
+
+		decoder, err = lzma.Reader2Config{DictCap: dictCap(size)}.NewReader2(packed)
+	case sevenZipDeflate:
+		readCloser := flate.NewReader(packed)
+		decoder, closer = readCloser, readCloser
+	case sevenZipBzip2:
+		var reader *bzip2.Reader
+		reader, err = bzip2.NewReader(packed, nil)
+		decoder, closer = reader, reader
+	default:
+		return nil, nil, fmt.Errorf("unsupported 7z coder %x", folder.coder)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return io.LimitReader(decoder, folder.unpackSize), closer, nil
+}
+
+// readHeaders reads the signature header and the header it points to,
+// decoding encoded headers.
+func (archive *sevenZipArchive) readHeaders() error {
+	info, err := archive.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	signatureHeader := make([]byte, sevenZipSignatureHeaderSize)
+	if _, err := io.ReadFull(archive.file, signatureHeader); err != nil {
+		return errSevenZipCorrupt
+	}
+
+	if !bytes.Equal(signatureHeader[:6], sevenZipSignature) {
+		return errors.New("not a 7z archive")
+	}
+
+	if crc32.ChecksumIEEE(signatureHeader[12:]) != binary.LittleEndian.Uint32(signatureHeader[8:]) {
+		return errSevenZipCorrupt
+	}
+
+	offset := binary.LittleEndian.Uint64(signatureHeader[12:])
+	size := binary.LittleEndian.Uint64(signatureHeader[20:])
+	available := uint64(info.Size() - sevenZipSignatureHeaderSize)
+	if offset > available || size > available-offset || size > sevenZipMaxHeaderSize {
+		return errSevenZipCorrupt
+	}
+
+	data := make([]byte, size)
+	if _, err := archive.file.ReadAt(data, int64(offset)+sevenZipSignatureHeaderSize); err != nil {
+		return err
+	}
+
+	if crc32.ChecksumIEEE(data) != binary.LittleEndian.Uint32(signatureHeader[28:]) {
+		return errSevenZipCorrupt
+	}
+
+	for {
+		header := &sevenZipReader{data: data}
+
+		id, err := header.byte()
+		if err != nil {
+			return err
+		}
+
+		switch id {
+		case sevenZipHeader:
+			return archive.readHeader(header, info.Size())
+		case sevenZipEncodedHeader:
+			data, err = archive.decodeHeader(header, info.Size())
+			if err != nil {
+				return err
+			}
+		default:
+			return errSevenZipCorrupt
+		}
+	}
+}
+
+// decodeHeader decodes an encoded header, stored like the contents of a
+// folder.
+func (archive *sevenZipArchive) decodeHeader(header *sevenZipReader, fileSize int64) ([]byte, error) {
+	folders, err := header.streamsInfo(fileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(folders) == 0 || len(folders[0].streams) != 1 || folders[0].unpackSize > sevenZipMaxHeaderSize {
+		return nil, errSevenZipCorrupt
+	}
+
+	decoder, closer, err := archive.folderReader(folders[0])
+	if err != nil {
+		return nil, err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+
+	data := make([]byte, folders[0].unpackSize)
+	if _, err := io.ReadFull(decoder, data); err != nil {
+		return nil, err
+	}
+
+	if stream := folders[0].streams[0]; stream.hasCRC && crc32.ChecksumIEEE(data) != stream.crc {
+		return nil, errSevenZipCorrupt
+	}
+
+	return data, nil
+}
+
+func (archive *sevenZipArchive) readHeader(header *sevenZipReader, fileSize int64) error {
+	for {
+		id, err := header.byte()
+		if err != nil {
+			return err
+		}
+
+		switch id {
+		case sevenZipEnd:
+			return nil
+		case sevenZipArchiveProperties:
+			if err := header.skipProperties(); err != nil {
+				return err
+			}
+		case sevenZipAdditionalStreamsInfo:
+			if _, err := header.streamsInfo(fileSize); err != nil {
+				return err
+			}
+		case sevenZipMainStreamsInfo:
+			if archive.folders, err = header.streamsInfo(fileSize); err != nil {
+				return err
+			}
+		case sevenZipFilesInfo:
+			if archive.entries, err = header.filesInfo(archive.folders); err != nil {
+				return err
+			}
+		default:
+			return errSevenZipCorrupt
+		}
+	}
+}
+
+// sevenZipReader reads the structures of a 7z header.
+type sevenZipReader struct {
+	data []byte
+}
+
+func (reader *sevenZipReader) byte() (byte, error) {
+	if len(reader.data) == 0 {
+		return 0, errSevenZipCorrupt
+	}
+
+	b := reader.data[0]
+	reader.data = reader.data[1:]
+	return b, nil
+}
+
+func (reader *sevenZipReader) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(reader.data)) {
+		return nil, errSevenZipCorrupt
+	}
+
+	b := reader.data[:n]
+	reader.data = reader.data[n:]
+	return b, nil
+}
+
+func (reader *sevenZipReader) uint32() (uint32, error) {
+	b, err := reader.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (reader *sevenZipReader) uint64() (uint64, error) {
+	b, err := reader.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// number reads a variable length number, whose first byte tells how many
+// bytes follow.
+func (reader *sevenZipReader) number() (uint64, error) {
+	first, err := reader.byte()
+	if err != nil {
+		return 0, err
+	}
+
+	var value uint64
+	mask := byte(0x80)
+	for i := uint(0); i < 8; i++ {
+		if first&mask == 0 {
+			return value | uint64(first&(mask-1))<<(8*i), nil
+		}
+
+		b, err := reader.byte()
+		if err != nil {
+			return 0, err
+		}
+		value |= uint64(b) << (8 * i)
+		mask >>= 1
+	}
+
+	return value, nil
+}
+
+// count reads a number of items, bounded by the header size so corrupt
+// headers can not cause large allocations.
+func (reader *sevenZipReader) count() (int, error) {
+	n, err := reader.number()
+	if err != nil {
+		return 0, err
+	}
+
+	if n > uint64(len(reader.data))*8+8 {
+		return 0, errSevenZipCorrupt
+	}
+
+	return int(n), nil
+}
+
+// size reads a size, bounded so sums of sizes can not overflow.
+func (reader *sevenZipReader) size() (int64, error) {
+	n, err := reader.number()
+	if err != nil {
+		return 0, err
+	}
+
+	if n > 1<<62 {
+		return 0, errSevenZipCorrupt
+	}
+
+	return int64(n), nil
+}
+
+func (reader *sevenZipReader) expect(id byte) error {
+	b, err := reader.byte()
+	if err != nil {
+		return err
+	}
+
+	if b != id {
+		return errSevenZipCorrupt
+	}
+
+	return nil
+}
+
+// bits reads a bit vector, most significant bit first.
+func (reader *sevenZipReader) bits(n int) ([]bool, error) {
+	data, err := reader.bytes(uint64(n+7) / 8)
+	if err != nil {
+		return nil, err
+	}
+
+	bits := make([]bool, n)
+	for i := range bits {
+		bits[i] = data[i/8]&(0x80>>uint(i%8)) != 0
+	}
+
+	return bits, nil
+}
+
+// defined reads which of n items are defined, all unless a bit vector
+// follows.
+func (reader *sevenZipReader) defined(n int) ([]bool, error) {
+	all, err := reader.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	if all == 0 {
+		return reader.bits(n)
+	}
+
+	defined := make([]bool, n)
+	for i := range defined {
+		defined[i] = true
+	}
+
+	return defined, nil
+}
+
+// digests reads the CRCs of n items, not all of which need to have one.
+func (reader *sevenZipReader) digests(n int) ([]bool, []uint32, error) {
+	defined, err := reader.defined(n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	crcs := make([]uint32, n)
+	for i := range crcs {
+		if !defined[i] {
+			continue
+		}
+
+		if crcs[i], err = reader.uint32(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return defined, crcs, nil
+}
+
+func (reader *sevenZipReader) skipProperties() error {
+	for {
+		id, err := reader.byte()
+		if err != nil || id == sevenZipEnd {
+			return err
+		}
+
+		n, err := reader.number()
+		if err != nil {
+			return err
+		}
+
+		if _, err := reader.bytes(n); err != nil {
+			return err
+		}
+	}
+}
+
+// streamsInfo reads where the folders are packed, how they are coded and
+// which streams they hold.
+func (reader *sevenZipReader) streamsInfo(fileSize int64) ([]sevenZipFolder, error) {
+	var (
+		packPos   int64
+		packSizes []int64
+		folders   []sevenZipFolder
+	)
+
+	id, err := reader.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	if id == sevenZipPackInfo {
+		if packPos, packSizes, err = reader.packInfo(); err != nil {
+			return nil, err
+		}
+
+		if id, err = reader.byte(); err != nil {
+			return nil, err
+		}
+	}
+
+	if id == sevenZipUnpackInfo {
+		if folders, err = reader.unpackInfo(); err != nil {
+			return nil, err
+		}
+
+		if id, err = reader.byte(); err != nil {
+			return nil, err
+		}
+	}
+
+	if id == sevenZipSubStreamsInfo {
+		if err := reader.subStreamsInfo(folders); err != nil {
+			return nil, err
+		}
+
+		if id, err = reader.byte(); err != nil {
+			return nil, err
+		}
+	} else {
+		for i := range folders {
+			if folders[i].streams == nil {
+				folders[i].streams = []sevenZipStream{{size: folders[i].unpackSize}}
+			}
+		}
+	}
+
+	if id != sevenZipEnd || len(folders) > len(packSizes) {
+		return nil, errSevenZipCorrupt
+	}
+
+	// single coder folders are packed in one stream each, in order
+	offset := sevenZipSignatureHeaderSize + packPos
+	for i := range folders {
+		if offset > fileSize || packSizes[i] > fileSize-offset {
+			return nil, errSevenZipCorrupt
+		}
+
+		folders[i].packOffset = offset
+		folders[i].packSize = packSizes[i]
+		offset += packSizes[i]
+	}
+
+	return folders, nil
+}
+
+func (reader *sevenZipReader) packInfo() (int64, []int64, error) {
+	packPos, err := reader.size()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	n, err := reader.count()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	id, err := reader.byte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	packSizes := make([]int64, n)
+	if id == sevenZipSize {
+		for i := range packSizes {
+			if packSizes[i], err = reader.size(); err != nil {
+				return 0, nil, err
+			}
+		}
+
+		if id, err = reader.byte(); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if id == sevenZipCRC {
+		if _, _, err := reader.digests(n); err != nil {
+			return 0, nil, err
+		}
+
+		if id, err = reader.byte(); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if id != sevenZipEnd {
+		return 0, nil, errSevenZipCorrupt
+	}
+
+	return packPos, packSizes, nil
+}
+
+func (reader *sevenZipReader) unpackInfo() ([]sevenZipFolder, error) {
+	if err := reader.expect(sevenZipFolderID); err != nil {
+		return nil, err
+	}
+
+	n, err := reader.count()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := reader.expect(0); err != nil {
+		return nil, errors.New("external 7z headers are not supported")
+	}
+
+	folders := make([]sevenZipFolder, n)
+	outStreams := make([]int, n)
+	for i := range folders {
+		if outStreams[i], err = reader.folder(&folders[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := reader.expect(sevenZipCodersUnpackSize); err != nil {
+		return nil, err
+	}
+
+	// a single coder has a single output, the size of the folder
+	for i := range folders {
+		for j := 0; j < outStreams[i]; j++ {
+			if folders[i].unpackSize, err = reader.size(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	id, err := reader.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	if id == sevenZipCRC {
+		defined, crcs, err := reader.digests(n)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range folders {
+			folders[i].streams = []sevenZipStream{{size: folders[i].unpackSize, crc: crcs[i], hasCRC: defined[i]}}
+		}
+
+		if id, err = reader.byte(); err != nil {
+			return nil, err
+		}
+	}
+
+	if id != sevenZipEnd {
+		return nil, errSevenZipCorrupt
+	}
+
+	return folders, nil
+}
+
+// folder reads the coders of a folder and returns their number of outputs.
+// Folders chaining coders, like those with BCJ filters, are rejected.
+func (reader *sevenZipReader) folder(folder *sevenZipFolder) (int, error) {
+	n, err := reader.count()
+	if err != nil {
+		return 0, err
+	}
+
+	if n != 1 {
+		return 0, fmt.Errorf("7z folders with %d coders are not supported", n)
+	}
+
+	flags, err := reader.byte()
+	if err != nil {
+		return 0, err
+	}
+
+	if flags&0x80 != 0 {
+		return 0, errSevenZipCorrupt
+	}
+
+	coder, err := reader.bytes(uint64(flags & 0x0f))
+	if err != nil {
+		return 0, err
+	}
+	folder.coder = string(coder)
+
+	if flags&0x10 != 0 {
+		return 0, fmt.Errorf("unsupported 7z coder %x", coder)
+	}
+
+	if flags&0x20 != 0 {
+		size, err := reader.number()
+		if err != nil {
+			return 0, err
+		}
+
+		if folder.properties, err = reader.bytes(size); err != nil {
+			return 0, err
+		}
+	}
+
+	return 1, nil
+}
+
+func (reader *sevenZipReader) subStreamsInfo(folders []sevenZipFolder) error {
+	counts := make([]int, len(folders))
+	for i := range counts {
+		counts[i] = 1
+	}
+
+	id, err := reader.byte()
+	if err != nil {
+		return err
+	}
+
+	if id == sevenZipNumUnpackStream {
+		for i := range counts {
+			if counts[i], err = reader.count(); err != nil {
+				return err
+			}
+		}
+
+		if id, err = reader.byte(); err != nil {
+			return err
+		}
+	}
+
+	// the size of the last stream of a folder is what the others leave
+	for i := range folders {
+		if counts[i] == 0 {
+			folders[i].streams = nil
+			continue
+		}
+
+		if counts[i] > 1 && id != sevenZipSize {
+			return errSevenZipCorrupt
+		}
+
+		var crc sevenZipStream
+		if len(folders[i].streams) == 1 {
+			crc = folders[i].streams[0]
+		}
+
+		streams := make([]sevenZipStream, counts[i])
+		remaining := folders[i].unpackSize
+		for j := 0; j < counts[i]-1; j++ {
+			size, err := reader.size()
+			if err != nil {
+				return err
+			}
+
+			if size > remaining {
+				return errSevenZipCorrupt
+			}
+
+			streams[j].size = size
+			remaining -= size
+		}
+		streams[counts[i]-1].size = remaining
+
+		// a folder holding a single stream shares its checksum
+		if counts[i] == 1 {
+			streams[0].crc, streams[0].hasCRC = crc.crc, crc.hasCRC
+		}
+
+		folders[i].streams = streams
+	}
+
+	if id == sevenZipSize {
+		if id, err = reader.byte(); err != nil {
+			return err
+		}
+	}
+
+	for id != sevenZipEnd {
+		if id != sevenZipCRC {
+			return errSevenZipCorrupt
+		}
+
+		unknown := 0
+		for i := range folders {
+			if len(folders[i].streams) != 1 || !folders[i].streams[0].hasCRC {
+				unknown += len(folders[i].streams)
+			}
+		}
+
+		defined, crcs, err := reader.digests(unknown)
+		if err != nil {
+			return err
+		}
+
+		k := 0
+		for i := range folders {
+			if len(folders[i].streams) == 1 && folders[i].streams[0].hasCRC {
+				continue
+			}
+
+			for j := range folders[i].streams {
+				folders[i].streams[j].crc, folders[i].streams[j].hasCRC = crcs[k], defined[k]
+				k++
+			}
+		}
+
+		if id, err = reader.byte(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// filesInfo reads the entries and assigns the streams of the folders to the
+// entries which are not empty, in order.
+func (reader *sevenZipReader) filesInfo(folders []sevenZipFolder) ([]sevenZipEntry, error) {
+	n, err := reader.count()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries     = make([]sevenZipEntry, n)
+		emptyStream = make([]bool, n)
+		emptyFile   []bool
+		anti        []bool
+		attributes  = make([]uint32, n)
+		hasAttrs    = make([]bool, n)
+	)
+
+	for {
+		id, err := reader.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		if id == sevenZipEnd {
+			break
+		}
+
+		size, err := reader.number()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := reader.bytes(size)
+		if err != nil {
+			return nil, err
+		}
+		property := &sevenZipReader{data: data}
+
+		switch id {
+		case sevenZipEmptyStream:
+			if emptyStream, err = property.bits(n); err != nil {
+				return nil, err
+			}
+		case sevenZipEmptyFile, sevenZipAnti:
+			empty := 0
+			for _, isEmpty := range emptyStream {
+				if isEmpty {
+					empty++
+				}
+			}
+
+			bits, err := property.bits(empty)
+			if err != nil {
+				return nil, err
+			}
+
+			if id == sevenZipEmptyFile {
+				emptyFile = bits
+			} else {
+				anti = bits
+			}
+		case sevenZipName:
+			if err := property.names(entries); err != nil {
+				return nil, err
+			}
+		case sevenZipMTime:
+			if err := property.times(entries); err != nil {
+				return nil, err
+			}
+		case sevenZipWinAttributes:
+			if hasAttrs, attributes, err = property.attributes(n); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var (
+		result = make([]sevenZipEntry, 0, n)
+		empty  = 0
+		folder = 0
+		stream = 0
+	)
+	for i, entry := range entries {
+		isDir := false
+		if emptyStream[i] {
+			isDir = empty >= len(emptyFile) || !emptyFile[empty]
+			isAnti := empty < len(anti) && anti[empty]
+			empty++
+
+			if isAnti {
+				continue
+			}
+		} else {
+			for folder < len(folders) && stream >= len(folders[folder].streams) {
+				folder, stream = folder+1, 0
+			}
+
+			if folder == len(folders) {
+				return nil, errSevenZipCorrupt
+			}
+
+			entry.hasStream, entry.folder, entry.stream = true, folder, stream
+			stream++
+		}
+
+		entry.mode = sevenZipMode(attributes[i], hasAttrs[i], isDir)
+		result = append(result, entry)
+	}
+
+	return result, nil
+}
+
+func (reader *sevenZipReader) names(entries []sevenZipEntry) error {
+	if err := reader.expect(0); err != nil {
+		return errors.New("external 7z headers are not supported")
+	}
+
+	for i := range entries {
+		var name []uint16
+		for {
+			char, err := reader.bytes(2)
+			if err != nil {
+				return err
+			}
+
+			if char[0] == 0 && char[1] == 0 {
+				break
+			}
+			name = append(name, binary.LittleEndian.Uint16(char))
+		}
+
+		entries[i].name = string(utf16.Decode(name))
+	}
+
+	return nil
+}
+
+// times reads the modification times of the entries, as 100ns intervals
+// since 1601.
+func (reader *sevenZipReader) times(entries []sevenZipEntry) error {
+	defined, err := reader.defined(len(entries))
+	if err != nil {
+		return err
+	}
+
+	if err := reader.expect(0); err != nil {
+		return errors.New("external 7z headers are not supported")
+	}
+
+	const epochDelta = 116444736000000000
+
+	for i := range entries {
+		if !defined[i] {
+			continue
+		}
+
+		filetime, err := reader.uint64()
+		if err != nil {
+			return err
+		}
+
+		intervals := int64(filetime - epochDelta)
+		entries[i].modTime = time.Unix(intervals/1e7, intervals%1e7*100)
+	}
+
+	return nil
+}
+
+func (reader *sevenZipReader) attributes(n int) ([]bool, []uint32, error) {
+	defined, err := reader.defined(n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := reader.expect(0); err != nil {
+		return nil, nil, errors.New("external 7z headers are not supported")
+	}
+
+	attributes := make([]uint32, n)
+	for i := range attributes {
+		if !defined[i] {
+			continue
+		}
+
+		if attributes[i], err = reader.uint32(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return defined, attributes, nil
+}
+
+// sevenZipMode returns the mode of an entry. Archives written on unix keep
+// the unix mode in the high 16 bits of the windows attributes.
+func sevenZipMode(attributes uint32, hasAttributes bool, isDir bool) os.FileMode {
+	const (
+		windowsDirectory = 0x10
+		unixExtension    = 0x8000
+	)
+
+	var mode os.FileMode
+	if hasAttributes && attributes&unixExtension != 0 {
+		unixMode := attributes >> 16
+		mode = os.FileMode(unixMode & 0777)
+
+		switch unixMode & 0xf000 {
+		case 0x4000:
+			isDir = true
+		case 0xa000:
+			mode |= os.ModeSymlink
+		}
+	}
+
+	if isDir || hasAttributes && attributes&windowsDirectory != 0 {
+		mode = mode&^os.ModeSymlink | os.ModeDir
+	}
+
+	return mode
+}
@@ -0,0 +1,162 @@
+package in
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	gcsresource "github.com/syslxg/gcs-resource"
+	"golang.org/x/crypto/openpgp"
+)
+
+const pgpSignatureHeader = "-----BEGIN PGP SIGNATURE-----"
+
+// signatureSuffixes are the objects next to an artifact holding its detached
+// signature, in the order they are looked up.
+var signatureSuffixes = []string{".sig", ".asc"}
+
+// trustedKeys are the keys from source.trusted_keys: OpenPGP keys for detached
+// OpenPGP signatures and ECDSA or ed25519 keys for blob signatures.
+type trustedKeys struct {
+	keyring    openpgp.EntityList
+	publicKeys []crypto.PublicKey
+}
+
+// parseTrustedKeys parses armored OpenPGP public keys and PEM encoded public
+// keys.
+func parseTrustedKeys(keys []string) (trustedKeys, error) {
+	var trusted trustedKeys
+
+	for i, key := range keys {
+		if strings.Contains(key, "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+			entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+			if err != nil {
+				return trustedKeys{}, fmt.Errorf("invalid trusted key %d: %s", i, err)
+			}
+
+			trusted.keyring = append(trusted.keyring, entities...)
+			continue
+		}
+
+		block, _ := pem.Decode([]byte(key))
+		if block == nil {
+			return trustedKeys{}, fmt.Errorf("invalid trusted key %d: not an armored OpenPGP or PEM public key", i)
+		}
+
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return trustedKeys{}, fmt.Errorf("invalid trusted key %d: %s", i, err)
+		}
+
+		switch publicKey.(type) {
+		case *ecdsa.PublicKey, ed25519.PublicKey:
+		default:
+			return trustedKeys{}, fmt.Errorf("invalid trusted key %d: unsupported key type %T", i, publicKey)
+		}
+
+		trusted.publicKeys = append(trusted.publicKeys, publicKey)
+	}
+
+	return trusted, nil
+}
+
+// verify checks the detached signature of an artifact. Armored or binary
+// OpenPGP signatures are checked against the OpenPGP keys, base64 or raw blob
+// signatures against the ECDSA and ed25519 keys.
+func (trusted trustedKeys) verify(artifactPath string, signaturePath string) error {
+	signature, err := ioutil.ReadFile(signaturePath)
+	if err != nil {
+		return err
+	}
+
+	artifact, err := os.Open(artifactPath)
+	if err != nil {
+		return err
+	}
+	defer artifact.Close()
+
+	trimmed := bytes.TrimSpace(signature)
+
+	if bytes.HasPrefix(trimmed, []byte(pgpSignatureHeader)) {
+		_, err := openpgp.CheckArmoredDetachedSignature(trusted.keyring, artifact, bytes.NewReader(trimmed))
+		return err
+	}
+
+	if decoded, err := base64.StdEncoding.DecodeString(string(trimmed)); err == nil {
+		return trusted.verifyBlob(artifact, decoded)
+	}
+
+	if len(trusted.keyring) > 0 && len(signature) > 0 && signature[0]&0x80 != 0 {
+		_, err := openpgp.CheckDetachedSignature(trusted.keyring, artifact, bytes.NewReader(signature))
+		return err
+	}
+
+	return trusted.verifyBlob(artifact, signature)
+}
+
+// verifyBlob checks an ECDSA signature of the sha256 digest of the artifact
+// or an ed25519 signature of the artifact itself.
+func (trusted trustedKeys) verifyBlob(artifact io.Reader, signature []byte) error {
+	if len(trusted.publicKeys) == 0 {
+		return errors.New("no ECDSA or ed25519 key is trusted")
+	}
+
+	digest := sha256.New()
+	reader := io.TeeReader(artifact, digest)
+
+	// ed25519 signs the whole message rather than a digest
+	var content bytes.Buffer
+	for _, publicKey := range trusted.publicKeys {
+		if _, ok := publicKey.(ed25519.PublicKey); ok {
+			reader = io.TeeReader(reader, &content)
+			break
+		}
+	}
+
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		return err
+	}
+
+	for _, publicKey := range trusted.publicKeys {
+		switch key := publicKey.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(key, digest.Sum(nil), signature) {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, content.Bytes(), signature) {
+				return nil
+			}
+		}
+	}
+
+	return errors.New("signature does not match any trusted key")
+}
+
+// signatureObject finds the detached signature next to an artifact. Missing
+// signature objects are skipped, any other lookup error is returned.
+func (command *InCommand) signatureObject(bucketName string, objectPath string) (relatedObject, error) {
+	for _, suffix := range signatureSuffixes {
+		info, err := command.gcsClient.GetBucketObjectInfo(bucketName, objectPath+suffix)
+		if err == gcsresource.ErrObjectNotExist {
+			continue
+		} else if err != nil {
+			return relatedObject{}, fmt.Errorf("failed to look up signature '%s': %s", objectPath+suffix, err)
+		}
+
+		return relatedObject{kind: "signature", path: objectPath + suffix, live: info}, nil
+	}
+
+	return relatedObject{}, fmt.Errorf("no signature found for '%s', expected '%s.sig' or '%s.asc'", objectPath, objectPath, objectPath)
+}
@@ -157,19 +157,19 @@ var _ = Describe("check", func() {
 				err = ioutil.WriteFile(tempFile.Name(), []byte("file-to-check-1"), 0755)
 				Expect(err).ToNot(HaveOccurred())
 
-				_, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-check-1"), "", tempFile.Name(), "", "", -1)
+				_, _, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-check-1"), "", tempFile.Name(), "", "", -1)
 				Expect(err).ToNot(HaveOccurred())
 
 				err = ioutil.WriteFile(tempFile.Name(), []byte("file-to-check-3"), 0755)
 				Expect(err).ToNot(HaveOccurred())
 
-				_, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-check-3"), "", tempFile.Name(), "", "", -1)
+				_, _, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-check-3"), "", tempFile.Name(), "", "", -1)
 				Expect(err).ToNot(HaveOccurred())
 
 				err = ioutil.WriteFile(tempFile.Name(), []byte("file-to-check-5"), 0755)
 				Expect(err).ToNot(HaveOccurred())
 
-				_, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-check-5"), "", tempFile.Name(), "", "", -1)
+				_, _, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-check-5"), "", tempFile.Name(), "", "", -1)
 				Expect(err).ToNot(HaveOccurred())
 
 				err = os.Remove(tempFile.Name())
@@ -402,19 +402,19 @@ var _ = Describe("check", func() {
 				err = ioutil.WriteFile(tempFile.Name(), []byte("generation-1"), 0755)
 				Expect(err).ToNot(HaveOccurred())
 
-				generation1, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
+				generation1, _, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
 				Expect(err).ToNot(HaveOccurred())
 
 				err = ioutil.WriteFile(tempFile.Name(), []byte("generation-2"), 0755)
 				Expect(err).ToNot(HaveOccurred())
 
-				generation2, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
+				generation2, _, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
 				Expect(err).ToNot(HaveOccurred())
 
 				err = ioutil.WriteFile(tempFile.Name(), []byte("generation-3"), 0755)
 				Expect(err).ToNot(HaveOccurred())
 
-				generation3, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
+				generation3, _, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
 				Expect(err).ToNot(HaveOccurred())
 
 				err = os.Remove(tempFile.Name())
@@ -3,12 +3,15 @@ package integration_test
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+
+	"github.com/syslxg/gcs-resource"
 )
 
 var _ = Describe("GCSclient", func() {
@@ -53,22 +56,37 @@ var _ = Describe("GCSclient", func() {
 		})
 
 		It("can interact with buckets", func() {
-			_, err := gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-upload-1"), "", tempFile.Name(), "", "", -1)
+			_, _, err := gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-upload-1"), "", tempFile.Name(), "", "", -1)
 			Expect(err).ToNot(HaveOccurred())
 
-			_, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-upload-2"), "", tempFile.Name(), "", "", -1)
+			_, _, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-upload-2"), "", tempFile.Name(), "", "", -1)
 			Expect(err).ToNot(HaveOccurred())
 
-			_, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-upload-2"), "", tempFile.Name(), "", "", -1)
+			_, _, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-upload-2"), "", tempFile.Name(), "", "", -1)
 			Expect(err).ToNot(HaveOccurred())
 
-			_, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "zip-to-upload.zip"), "application/zip", tempFile.Name(), "", "", -1)
+			_, _, err = gcsClient.UploadFileIfGenerationMatch(bucketName, filepath.Join(directoryPrefix, "file-to-upload-2"), "", tempFile.Name(), "", "", -1, 0)
+			Expect(err).To(Equal(gcsresource.ErrPreconditionFailed))
+
+			_, uploadedZipFileObject, err := gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "zip-to-upload.zip"), "application/zip", tempFile.Name(), "", "", -1)
 			Expect(err).ToNot(HaveOccurred())
+			Expect(uploadedZipFileObject.ContentType).To(Equal("application/zip"))
 
 			fakeZipFileObject, err := gcsClient.GetBucketObjectInfo(bucketName, filepath.Join(directoryPrefix, "zip-to-upload.zip"))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(fakeZipFileObject.ContentType).To(Equal("application/zip"))
 
+			_, copiedZipFileResource, err := gcsClient.CopyObject(bucketName, filepath.Join(directoryPrefix, "zip-to-upload.zip"), 0, bucketName, filepath.Join(directoryPrefix, "zip-copied.zip"), "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(copiedZipFileResource.Name).To(Equal(filepath.Join(directoryPrefix, "zip-copied.zip")))
+
+			copiedZipFileObject, err := gcsClient.GetBucketObjectInfo(bucketName, filepath.Join(directoryPrefix, "zip-copied.zip"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(copiedZipFileObject.ContentType).To(Equal("application/zip"))
+
+			err = gcsClient.DeleteObject(bucketName, filepath.Join(directoryPrefix, "zip-copied.zip"), 0)
+			Expect(err).ToNot(HaveOccurred())
+
 			files, err := gcsClient.BucketObjects(bucketName, directoryPrefix)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(files).To(ConsistOf([]string{filepath.Join(directoryPrefix, "file-to-upload-1"), filepath.Join(directoryPrefix, "file-to-upload-2"), filepath.Join(directoryPrefix, "zip-to-upload.zip")}))
@@ -95,6 +113,36 @@ var _ = Describe("GCSclient", func() {
 			read, err := ioutil.ReadFile(filepath.Join(tempDir, "downloaded-file"))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(read).To(Equal([]byte("hello-" + runtime)))
+
+			stream, err := gcsClient.DownloadStream(bucketName, filepath.Join(directoryPrefix, "file-to-upload-1"), 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			streamed, err := ioutil.ReadAll(stream)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stream.Close()).To(Succeed())
+			Expect(streamed).To(Equal([]byte("hello-" + runtime)))
+
+			fileOneObject, err := gcsClient.GetBucketObjectInfo(bucketName, filepath.Join(directoryPrefix, "file-to-upload-1"))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = gcsClient.DownloadFile(bucketName, filepath.Join(directoryPrefix, "file-to-upload-1"), fileOneObject.Generation, filepath.Join(tempDir, "downloaded-generation"))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = gcsClient.DownloadFile(bucketName, filepath.Join(directoryPrefix, "file-to-upload-1"), fileOneObject.Generation-1, filepath.Join(tempDir, "downloaded-generation"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("bucket is not versioned"))
+
+			signedURL, err := gcsClient.SignedURL(bucketName, filepath.Join(directoryPrefix, "file-to-upload-1"), 0, time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+
+			response, err := http.Get(signedURL)
+			Expect(err).ToNot(HaveOccurred())
+			defer response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+			signed, err := ioutil.ReadAll(response.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(signed).To(Equal([]byte("hello-" + runtime)))
 		})
 	})
 
@@ -139,16 +187,16 @@ var _ = Describe("GCSclient", func() {
 		})
 
 		It("can interact with buckets", func() {
-			fileOneGeneration, err := gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "file-to-upload-1"), "", tempVerFile.Name(), "", "", -1)
+			fileOneGeneration, _, err := gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "file-to-upload-1"), "", tempVerFile.Name(), "", "", -1)
 			Expect(err).ToNot(HaveOccurred())
 
-			fileTwoGeneration1, err := gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "file-to-upload-2"), "", tempVerFile.Name(), "", "", -1)
+			fileTwoGeneration1, _, err := gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "file-to-upload-2"), "", tempVerFile.Name(), "", "", -1)
 			Expect(err).ToNot(HaveOccurred())
 
-			fileTwoGeneration2, err := gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "file-to-upload-2"), "", tempVerFile.Name(), "", "", -1)
+			fileTwoGeneration2, _, err := gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "file-to-upload-2"), "", tempVerFile.Name(), "", "", -1)
 			Expect(err).ToNot(HaveOccurred())
 
-			fakeZipFileGeneration, err := gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "zip-to-upload.zip"), "application/zip", tempVerFile.Name(), "", "", -1)
+			fakeZipFileGeneration, _, err := gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "zip-to-upload.zip"), "application/zip", tempVerFile.Name(), "", "", -1)
 			Expect(err).ToNot(HaveOccurred())
 
 			fakeZipFileObject, err := gcsClient.GetBucketObjectInfo(versionedBucketName, filepath.Join(directoryPrefix, "zip-to-upload.zip"))
@@ -200,4 +248,51 @@ var _ = Describe("GCSclient", func() {
 			Expect(read).To(Equal([]byte("hello-" + runtime)))
 		})
 	})
+
+	Describe("with a bucket with object retention enabled", func() {
+		// retained objects can not be deleted, so they are left to a
+		// lifecycle rule of the bucket
+		var retentionBucketName = os.Getenv("GCS_RESOURCE_RETENTION_BUCKET_NAME")
+
+		BeforeEach(func() {
+			if retentionBucketName == "" {
+				Skip("$GCS_RESOURCE_RETENTION_BUCKET_NAME is not set")
+			}
+
+			tempDir, err = ioutil.TempDir("", "gcs_client_integration_test")
+			Expect(err).ToNot(HaveOccurred())
+
+			tempFile, err = ioutil.TempFile(tempDir, "file-to-upload")
+			Expect(err).ToNot(HaveOccurred())
+
+			tempFile.Write([]byte("hello-" + runtime))
+		})
+
+		AfterEach(func() {
+			err := os.RemoveAll(tempDir)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("sets the retention of the uploaded object", func() {
+			objectPath := filepath.Join(directoryPrefix, "retained-"+runtime)
+			retainUntilTime := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+			generation, _, err := gcsClient.UploadFile(retentionBucketName, objectPath, "", tempFile.Name(), "", "", -1)
+			Expect(err).ToNot(HaveOccurred())
+
+			retention, err := gcsClient.SetObjectRetention(retentionBucketName, objectPath, generation, gcsresource.ObjectRetention{
+				Mode:            "Unlocked",
+				RetainUntilTime: retainUntilTime,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retention.Mode).To(Equal("Unlocked"))
+
+			retainUntil, err := time.Parse(time.RFC3339, retention.RetainUntilTime)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retainUntil.UTC().Format(time.RFC3339)).To(Equal(retainUntilTime))
+
+			err = gcsClient.DeleteObject(retentionBucketName, objectPath, generation)
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
@@ -119,19 +119,19 @@ var _ = Describe("in", func() {
 			err = ioutil.WriteFile(tempFile.Name(), []byte("file-to-download-1"), 0755)
 			Expect(err).ToNot(HaveOccurred())
 
-			_, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-download-1"), "", tempFile.Name(), "", "", -1)
+			_, _, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-download-1"), "", tempFile.Name(), "", "", -1)
 			Expect(err).ToNot(HaveOccurred())
 
 			err = ioutil.WriteFile(tempFile.Name(), []byte("file-to-download-2"), 0755)
 			Expect(err).ToNot(HaveOccurred())
 
-			_, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-download-2"), "", tempFile.Name(), "", "", -1)
+			_, _, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-download-2"), "", tempFile.Name(), "", "", -1)
 			Expect(err).ToNot(HaveOccurred())
 
 			err = ioutil.WriteFile(tempFile.Name(), []byte("file-to-download-3"), 0755)
 			Expect(err).ToNot(HaveOccurred())
 
-			_, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-download-3"), "", tempFile.Name(), "", "", -1)
+			_, _, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-download-3"), "", tempFile.Name(), "", "", -1)
 			Expect(err).ToNot(HaveOccurred())
 
 			err = os.Remove(tempFile.Name())
@@ -175,11 +175,14 @@ var _ = Describe("in", func() {
 					url, err := gcsClient.URL(bucketName, filepath.Join(directoryPrefix, "file-to-download-1"), int64(0))
 					Expect(err).ToNot(HaveOccurred())
 
+					objectInfo, err := gcsClient.ObjectInfo(bucketName, filepath.Join(directoryPrefix, "file-to-download-1"), int64(0))
+					Expect(err).ToNot(HaveOccurred())
+
 					Expect(inResponse).To(Equal(in.InResponse{
 						Version: gcsresource.Version{
 							Path: filepath.Join(directoryPrefix, "file-to-download-1"),
 						},
-						Metadata: []gcsresource.MetadataPair{
+						Metadata: append([]gcsresource.MetadataPair{
 							{
 								Name:  "filename",
 								Value: "file-to-download-1",
@@ -188,7 +191,7 @@ var _ = Describe("in", func() {
 								Name:  "url",
 								Value: url,
 							},
-						},
+						}, gcsresource.ObjectMetadata(objectInfo)...),
 					}))
 
 					Expect(filepath.Join(destDir, "file-to-download-1")).To(BeARegularFile())
@@ -224,7 +227,7 @@ var _ = Describe("in", func() {
 					Expect(err).NotTo(HaveOccurred())
 					Eventually(session).Should(gexec.Exit(0))
 
-					_, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-download.tgz"), "", tempTarballPath, "", "", -1)
+					_, _, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-download.tgz"), "", tempTarballPath, "", "", -1)
 					Expect(err).ToNot(HaveOccurred())
 
 					err = os.RemoveAll(tempDir)
@@ -259,11 +262,14 @@ var _ = Describe("in", func() {
 					url, err := gcsClient.URL(bucketName, filepath.Join(directoryPrefix, "file-to-download.tgz"), int64(0))
 					Expect(err).ToNot(HaveOccurred())
 
+					objectInfo, err := gcsClient.ObjectInfo(bucketName, filepath.Join(directoryPrefix, "file-to-download.tgz"), int64(0))
+					Expect(err).ToNot(HaveOccurred())
+
 					Expect(inResponse).To(Equal(in.InResponse{
 						Version: gcsresource.Version{
 							Path: filepath.Join(directoryPrefix, "file-to-download.tgz"),
 						},
-						Metadata: []gcsresource.MetadataPair{
+						Metadata: append([]gcsresource.MetadataPair{
 							{
 								Name:  "filename",
 								Value: "file-to-download.tgz",
@@ -272,7 +278,7 @@ var _ = Describe("in", func() {
 								Name:  "url",
 								Value: url,
 							},
-						},
+						}, gcsresource.ObjectMetadata(objectInfo)...),
 					}))
 
 					Expect(filepath.Join(destDir, "file-to-download.tgz")).NotTo(BeARegularFile())
@@ -300,7 +306,7 @@ var _ = Describe("in", func() {
 					Expect(err).NotTo(HaveOccurred())
 					Eventually(session).Should(gexec.Exit(0))
 
-					_, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-download.tgz"), "", tempTarballPath, "", "", -1)
+					_, _, err = gcsClient.UploadFile(bucketName, filepath.Join(directoryPrefix, "file-to-download.tgz"), "", tempTarballPath, "", "", -1)
 					Expect(err).ToNot(HaveOccurred())
 
 					err = os.RemoveAll(tempDir)
@@ -337,11 +343,14 @@ var _ = Describe("in", func() {
 					url, err := gcsClient.URL(bucketName, filepath.Join(directoryPrefix, "file-to-download.tgz"), int64(0))
 					Expect(err).ToNot(HaveOccurred())
 
+					objectInfo, err := gcsClient.ObjectInfo(bucketName, filepath.Join(directoryPrefix, "file-to-download.tgz"), int64(0))
+					Expect(err).ToNot(HaveOccurred())
+
 					Expect(inResponse).To(Equal(in.InResponse{
 						Version: gcsresource.Version{
 							Path: filepath.Join(directoryPrefix, "file-to-download.tgz"),
 						},
-						Metadata: []gcsresource.MetadataPair{
+						Metadata: append([]gcsresource.MetadataPair{
 							{
 								Name:  "filename",
 								Value: "file-to-download.tgz",
@@ -350,7 +359,7 @@ var _ = Describe("in", func() {
 								Name:  "url",
 								Value: url,
 							},
-						},
+						}, gcsresource.ObjectMetadata(objectInfo)...),
 					}))
 
 					Expect(filepath.Join(destDir, "file-to-download.tgz")).To(BeARegularFile())
@@ -415,11 +424,14 @@ var _ = Describe("in", func() {
 					url, err := gcsClient.URL(bucketName, filepath.Join(directoryPrefix, "file-to-download-3"), int64(0))
 					Expect(err).ToNot(HaveOccurred())
 
+					objectInfo, err := gcsClient.ObjectInfo(bucketName, filepath.Join(directoryPrefix, "file-to-download-3"), int64(0))
+					Expect(err).ToNot(HaveOccurred())
+
 					Expect(inResponse).To(Equal(in.InResponse{
 						Version: gcsresource.Version{
 							Path: filepath.Join(directoryPrefix, "file-to-download-3"),
 						},
-						Metadata: []gcsresource.MetadataPair{
+						Metadata: append([]gcsresource.MetadataPair{
 							{
 								Name:  "filename",
 								Value: "file-to-download-3",
@@ -428,7 +440,7 @@ var _ = Describe("in", func() {
 								Name:  "url",
 								Value: url,
 							},
-						},
+						}, gcsresource.ObjectMetadata(objectInfo)...),
 					}))
 
 					Expect(filepath.Join(destDir, "file-to-download-3")).To(BeARegularFile())
@@ -496,19 +508,19 @@ var _ = Describe("in", func() {
 					err = ioutil.WriteFile(tempFile.Name(), []byte("generation-1"), 0755)
 					Expect(err).ToNot(HaveOccurred())
 
-					_, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
+					_, _, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
 					Expect(err).ToNot(HaveOccurred())
 
 					err = ioutil.WriteFile(tempFile.Name(), []byte("generation-2"), 0755)
 					Expect(err).ToNot(HaveOccurred())
 
-					generation2, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
+					generation2, _, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
 					Expect(err).ToNot(HaveOccurred())
 
 					err = ioutil.WriteFile(tempFile.Name(), []byte("generation-3"), 0755)
 					Expect(err).ToNot(HaveOccurred())
 
-					_, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
+					_, _, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version"), "", tempFile.Name(), "", "", -1)
 					Expect(err).ToNot(HaveOccurred())
 
 					err = os.Remove(tempFile.Name())
@@ -546,11 +558,14 @@ var _ = Describe("in", func() {
 					url, err := gcsClient.URL(versionedBucketName, filepath.Join(directoryPrefix, "version"), generation2)
 					Expect(err).ToNot(HaveOccurred())
 
+					objectInfo, err := gcsClient.ObjectInfo(versionedBucketName, filepath.Join(directoryPrefix, "version"), generation2)
+					Expect(err).ToNot(HaveOccurred())
+
 					Expect(inResponse).To(Equal(in.InResponse{
 						Version: gcsresource.Version{
 							Generation: fmt.Sprintf("%d", generation2),
 						},
-						Metadata: []gcsresource.MetadataPair{
+						Metadata: append([]gcsresource.MetadataPair{
 							{
 								Name:  "filename",
 								Value: "version",
@@ -559,7 +574,7 @@ var _ = Describe("in", func() {
 								Name:  "url",
 								Value: url,
 							},
-						},
+						}, gcsresource.ObjectMetadata(objectInfo)...),
 					}))
 
 					Expect(filepath.Join(destDir, "version")).To(BeARegularFile())
@@ -599,7 +614,7 @@ var _ = Describe("in", func() {
 					Expect(err).NotTo(HaveOccurred())
 					Eventually(session).Should(gexec.Exit(0))
 
-					generation, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version.tgz"), "", tempTarballPath, "", "", -1)
+					generation, _, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version.tgz"), "", tempTarballPath, "", "", -1)
 					Expect(err).ToNot(HaveOccurred())
 
 					err = os.RemoveAll(tempDir)
@@ -636,11 +651,14 @@ var _ = Describe("in", func() {
 					url, err := gcsClient.URL(versionedBucketName, filepath.Join(directoryPrefix, "version.tgz"), generation)
 					Expect(err).ToNot(HaveOccurred())
 
+					objectInfo, err := gcsClient.ObjectInfo(versionedBucketName, filepath.Join(directoryPrefix, "version.tgz"), generation)
+					Expect(err).ToNot(HaveOccurred())
+
 					Expect(inResponse).To(Equal(in.InResponse{
 						Version: gcsresource.Version{
 							Generation: fmt.Sprintf("%d", generation),
 						},
-						Metadata: []gcsresource.MetadataPair{
+						Metadata: append([]gcsresource.MetadataPair{
 							{
 								Name:  "filename",
 								Value: "version.tgz",
@@ -649,7 +667,7 @@ var _ = Describe("in", func() {
 								Name:  "url",
 								Value: url,
 							},
-						},
+						}, gcsresource.ObjectMetadata(objectInfo)...),
 					}))
 
 					Expect(filepath.Join(destDir, "version.txt")).NotTo(BeARegularFile())
@@ -686,7 +704,7 @@ var _ = Describe("in", func() {
 					Expect(err).NotTo(HaveOccurred())
 					Eventually(session).Should(gexec.Exit(0))
 
-					generation, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version.tgz"), "", tempTarballPath, "", "", -1)
+					generation, _, err = gcsClient.UploadFile(versionedBucketName, filepath.Join(directoryPrefix, "version.tgz"), "", tempTarballPath, "", "", -1)
 					Expect(err).ToNot(HaveOccurred())
 
 					err = os.RemoveAll(tempDir)
@@ -723,11 +741,14 @@ var _ = Describe("in", func() {
 					url, err := gcsClient.URL(versionedBucketName, filepath.Join(directoryPrefix, "version.tgz"), generation)
 					Expect(err).ToNot(HaveOccurred())
 
+					objectInfo, err := gcsClient.ObjectInfo(versionedBucketName, filepath.Join(directoryPrefix, "version.tgz"), generation)
+					Expect(err).ToNot(HaveOccurred())
+
 					Expect(inResponse).To(Equal(in.InResponse{
 						Version: gcsresource.Version{
 							Generation: fmt.Sprintf("%d", generation),
 						},
-						Metadata: []gcsresource.MetadataPair{
+						Metadata: append([]gcsresource.MetadataPair{
 							{
 								Name:  "filename",
 								Value: "version.tgz",
@@ -736,7 +757,7 @@ var _ = Describe("in", func() {
 								Name:  "url",
 								Value: url,
 							},
-						},
+						}, gcsresource.ObjectMetadata(objectInfo)...),
 					}))
 
 					Expect(filepath.Join(destDir, "version.txt")).To(BeARegularFile())
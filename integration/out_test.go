@@ -234,11 +234,14 @@ var _ = Describe("out", func() {
 				url, err := gcsClient.URL(bucketName, filepath.Join(directoryPrefix, "file-to-upload"), int64(0))
 				Expect(err).ToNot(HaveOccurred())
 
+				objectInfo, err := gcsClient.ObjectInfo(bucketName, filepath.Join(directoryPrefix, "file-to-upload"), int64(0))
+				Expect(err).ToNot(HaveOccurred())
+
 				Expect(outResponse).To(Equal(out.OutResponse{
 					Version: gcsresource.Version{
 						Path: filepath.Join(directoryPrefix, "file-to-upload"),
 					},
-					Metadata: []gcsresource.MetadataPair{
+					Metadata: append([]gcsresource.MetadataPair{
 						{
 							Name:  "filename",
 							Value: "file-to-upload",
@@ -247,7 +250,7 @@ var _ = Describe("out", func() {
 							Name:  "url",
 							Value: url,
 						},
-					},
+					}, gcsresource.ObjectMetadata(objectInfo)...),
 				}))
 			})
 		})
@@ -290,11 +293,14 @@ var _ = Describe("out", func() {
 				url, err := gcsClient.URL(versionedBucketName, filepath.Join(directoryPrefix, "file-to-upload"), int64(0))
 				Expect(err).ToNot(HaveOccurred())
 
+				objectInfo, err := gcsClient.ObjectInfo(versionedBucketName, filepath.Join(directoryPrefix, "file-to-upload"), int64(0))
+				Expect(err).ToNot(HaveOccurred())
+
 				Expect(outResponse).To(Equal(out.OutResponse{
 					Version: gcsresource.Version{
 						Path: filepath.Join(directoryPrefix, "file-to-upload"),
 					},
-					Metadata: []gcsresource.MetadataPair{
+					Metadata: append([]gcsresource.MetadataPair{
 						{
 							Name:  "filename",
 							Value: "file-to-upload",
@@ -303,7 +309,7 @@ var _ = Describe("out", func() {
 							Name:  "url",
 							Value: url,
 						},
-					},
+					}, gcsresource.ObjectMetadata(objectInfo)...),
 				}))
 			})
 		})
@@ -383,11 +389,14 @@ var _ = Describe("out", func() {
 				url, err := gcsClient.URL(bucketName, filepath.Join(directoryPrefix, "version"), int64(0))
 				Expect(err).ToNot(HaveOccurred())
 
+				objectInfo, err := gcsClient.ObjectInfo(bucketName, filepath.Join(directoryPrefix, "version"), int64(0))
+				Expect(err).ToNot(HaveOccurred())
+
 				Expect(outResponse).To(Equal(out.OutResponse{
 					Version: gcsresource.Version{
 						Generation: "0",
 					},
-					Metadata: []gcsresource.MetadataPair{
+					Metadata: append([]gcsresource.MetadataPair{
 						{
 							Name:  "filename",
 							Value: "version",
@@ -396,7 +405,7 @@ var _ = Describe("out", func() {
 							Name:  "url",
 							Value: url,
 						},
-					},
+					}, gcsresource.ObjectMetadata(objectInfo)...),
 				}))
 			})
 		})
@@ -440,11 +449,14 @@ var _ = Describe("out", func() {
 				url, err := gcsClient.URL(versionedBucketName, filepath.Join(directoryPrefix, "version"), generations[0])
 				Expect(err).ToNot(HaveOccurred())
 
+				objectInfo, err := gcsClient.ObjectInfo(versionedBucketName, filepath.Join(directoryPrefix, "version"), generations[0])
+				Expect(err).ToNot(HaveOccurred())
+
 				Expect(outResponse).To(Equal(out.OutResponse{
 					Version: gcsresource.Version{
 						Generation: fmt.Sprintf("%d", generations[0]),
 					},
-					Metadata: []gcsresource.MetadataPair{
+					Metadata: append([]gcsresource.MetadataPair{
 						{
 							Name:  "filename",
 							Value: "version",
@@ -453,7 +465,7 @@ var _ = Describe("out", func() {
 							Name:  "url",
 							Value: url,
 						},
-					},
+					}, gcsresource.ObjectMetadata(objectInfo)...),
 				}))
 			})
 		})
@@ -0,0 +1,75 @@
+package gcsresource
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	storage "google.golang.org/api/storage/v1"
+)
+
+const consoleURL = "https://console.cloud.google.com/storage/browser/_details/%s/%s"
+
+// ObjectMetadata describes an object for build pages: its https and Cloud
+// Console links, size, content type, checksums, storage class and the time
+// it was last updated. Fields the object resource does not have are left
+// out.
+func ObjectMetadata(object *storage.Object) []MetadataPair {
+	var console string
+	if object.Bucket != "" && object.Name != "" {
+		segments := strings.Split(object.Name, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		console = fmt.Sprintf(consoleURL, object.Bucket, strings.Join(segments, "/"))
+	}
+
+	fields := []MetadataPair{
+		{Name: "media_link", Value: object.MediaLink},
+		{Name: "console_url", Value: console},
+		{Name: "size", Value: HumanSize(object.Size)},
+		{Name: "content_type", Value: object.ContentType},
+		{Name: "md5_hash", Value: HexDigest(object.Md5Hash)},
+		{Name: "crc32c", Value: HexDigest(object.Crc32c)},
+		{Name: "storage_class", Value: object.StorageClass},
+		{Name: "updated", Value: object.Updated},
+	}
+
+	var metadata []MetadataPair
+	for _, field := range fields {
+		if field.Value != "" {
+			metadata = append(metadata, field)
+		}
+	}
+
+	return metadata
+}
+
+// HexDigest converts a base64 encoded checksum of the object resource to
+// hex, as written by md5sum and friends.
+func HexDigest(encoded string) string {
+	digest, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(digest)
+}
+
+// HumanSize formats a size in bytes with binary units, e.g. 1.5 MiB.
+func HumanSize(size uint64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := size / unit; n >= unit && exp < 4; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTP"[exp])
+}
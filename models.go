@@ -1,13 +1,26 @@
 package gcsresource
 
-import "strconv"
+import (
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Source struct {
-	JSONKey       string `json:"json_key"`
-	Bucket        string `json:"bucket"`
-	Regexp        string `json:"regexp"`
-	VersionedFile string `json:"versioned_file"`
-	SkipDownload  bool   `json:"skip_download"`
+	JSONKey          string   `json:"json_key"`
+	Bucket           string   `json:"bucket"`
+	Regexp           string   `json:"regexp"`
+	VersionedFile    string   `json:"versioned_file"`
+	PrefixRegexp     string   `json:"prefix_regexp"`
+	TrackGenerations bool     `json:"track_generations"`
+	VersionFrom      string   `json:"version_from"`
+	MaxVersions      int      `json:"max_versions"`
+	VersionSince     string   `json:"version_since"`
+	SkipDownload     bool     `json:"skip_download"`
+	TrustedKeys      []string `json:"trusted_keys"`
+	SigningKey       string   `json:"signing_key"`
 }
 
 func (source Source) IsValid() (bool, string) {
@@ -19,12 +32,93 @@ func (source Source) IsValid() (bool, string) {
 		return false, "please specify either regexp or versioned_file"
 	}
 
+	if source.PrefixRegexp != "" && (source.Regexp != "" || source.VersionedFile != "") {
+		return false, "please specify either prefix_regexp, regexp or versioned_file"
+	}
+
+	if source.PrefixRegexp != "" && spansLevels(source.PrefixRegexp) {
+		return false, "please specify a prefix_regexp matching a single level below its literal prefix"
+	}
+
+	if source.TrackGenerations && source.Regexp == "" {
+		return false, "please specify regexp when using track_generations"
+	}
+
+	if source.VersionFrom != "" && source.Regexp == "" {
+		return false, "please specify regexp when using version_from"
+	}
+
+	if source.VersionFrom != "" && source.TrackGenerations {
+		return false, "please specify either version_from or track_generations"
+	}
+
+	if source.MaxVersions < 0 {
+		return false, "please specify a positive max_versions"
+	}
+
+	if source.VersionSince != "" {
+		if source.PrefixRegexp != "" {
+			return false, "version_since is not supported with prefix_regexp"
+		}
+
+		if _, err := source.VersionSinceTime(); err != nil {
+			return false, "invalid version_since value specified: " + source.VersionSince
+		}
+	}
+
 	return true, ""
 }
 
+var characterClass = regexp.MustCompile(`\[(\\.|[^\]])*\]`)
+
+// spansLevels reports whether a prefix regexp spans more than one path level
+// after its literal sections. Prefixes are listed one level below the
+// literal ones, so deeper levels would never be found.
+func spansLevels(prefixRegexp string) bool {
+	// a '/' inside a character class does not separate levels
+	pattern := characterClass.ReplaceAllString(strings.TrimSuffix(prefixRegexp, "/"), "x")
+
+	sections := strings.Split(pattern, "/")
+	for i, section := range sections {
+		parsed, err := syntax.Parse(section, syntax.Perl)
+		if err != nil || (parsed.Op != syntax.OpLiteral && parsed.Op != syntax.OpEmptyMatch) {
+			return i < len(sections)-1
+		}
+	}
+
+	return false
+}
+
+// VersionSinceTime parses version_since, which must be an RFC 3339 timestamp.
+func (source Source) VersionSinceTime() (time.Time, error) {
+	return time.Parse(time.RFC3339, source.VersionSince)
+}
+
+// ObjectQuery narrows down an object listing on the server side.
+type ObjectQuery struct {
+	Prefix      string
+	StartOffset string
+	MatchGlob   string
+}
+
+// ObjectHolds are the holds placed on or released from an object. Holds
+// left nil are not changed.
+type ObjectHolds struct {
+	TemporaryHold  *bool
+	EventBasedHold *bool
+}
+
+// ObjectRetention is the retention configuration of an object: its mode,
+// Unlocked or Locked, and the RFC 3339 time it is retained until.
+type ObjectRetention struct {
+	Mode            string `json:"mode"`
+	RetainUntilTime string `json:"retainUntilTime"`
+}
+
 type Version struct {
 	Path       string `json:"path,omitempty"`
 	Generation string `json:"generation,omitempty"`
+	Value      string `json:"value,omitempty"`
 }
 
 func (v Version) GenerationValue() (int64, error) {
@@ -0,0 +1,64 @@
+package out
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	gcsresource "github.com/syslxg/gcs-resource"
+)
+
+// uploadChecksums uploads a sidecar object per algorithm in
+// params.checksum_files next to the object, in the format of sha256sum, and
+// returns the digests as metadata.
+func (command *OutCommand) uploadChecksums(request OutRequest, objectPath string, localPath string) ([]gcsresource.MetadataPair, error) {
+	checksums, err := gcsresource.FileChecksums(localPath, request.Params.ChecksumFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithms := make([]string, 0, len(checksums))
+	for algorithm := range checksums {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+
+	var metadata []gcsresource.MetadataPair
+	for _, algorithm := range algorithms {
+		sidecar := fmt.Sprintf("%s  %s\n", checksums[algorithm], path.Base(objectPath))
+
+		if _, err := command.uploadContent(request, objectPath+"."+algorithm, []byte(sidecar), "text/plain"); err != nil {
+			return nil, err
+		}
+
+		metadata = append(metadata, gcsresource.MetadataPair{
+			Name:  algorithm,
+			Value: checksums[algorithm],
+		})
+	}
+
+	return metadata, nil
+}
+
+// uploadContent uploads generated content, like checksums, signatures or
+// the latest pointer, to objectPath.
+func (command *OutCommand) uploadContent(request OutRequest, objectPath string, content []byte, contentType string) (int64, error) {
+	file, err := ioutil.TempFile("", "gcs-resource-upload")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	generation, _, err := command.gcsClient.UploadFile(request.Source.Bucket, objectPath, contentType, file.Name(), request.Params.PredefinedACL, request.Params.CacheControl, command.ParallelUploadThreshold(request))
+	return generation, err
+}
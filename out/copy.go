@@ -0,0 +1,153 @@
+package out
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	gcsresource "github.com/syslxg/gcs-resource"
+)
+
+// copySource is the object copied by copy_from.
+type copySource struct {
+	bucket     string
+	path       string
+	generation int64
+}
+
+func (source copySource) url() string {
+	return objectURL(source.bucket, source.path, source.generation)
+}
+
+// copySourceOf resolves copy_from, reading the url file relative to the
+// source dir if given.
+func copySourceOf(copyFrom CopyFrom, sourceDir string) (copySource, error) {
+	if copyFrom.URLFile == "" {
+		source := copySource{bucket: copyFrom.Bucket, path: copyFrom.Path}
+		if copyFrom.Generation != "" {
+			source.generation, _ = strconv.ParseInt(copyFrom.Generation, 10, 64)
+		}
+
+		return source, nil
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(sourceDir, copyFrom.URLFile))
+	if err != nil {
+		return copySource{}, err
+	}
+
+	return parseObjectURL(strings.TrimSpace(string(contents)))
+}
+
+// parseObjectURL parses gs://bucket/path urls, with an optional #generation
+// suffix, as written to the url file by in.
+func parseObjectURL(url string) (copySource, error) {
+	invalid := fmt.Errorf("invalid object url: %s", url)
+
+	if !strings.HasPrefix(url, "gs://") {
+		return copySource{}, invalid
+	}
+
+	location := strings.TrimPrefix(url, "gs://")
+
+	var source copySource
+	if i := strings.LastIndex(location, "#"); i >= 0 {
+		generation, err := strconv.ParseInt(location[i+1:], 10, 64)
+		if err != nil {
+			return copySource{}, invalid
+		}
+
+		source.generation = generation
+		location = location[:i]
+	}
+
+	parts := strings.SplitN(location, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return copySource{}, invalid
+	}
+
+	source.bucket = parts[0]
+	source.path = parts[1]
+
+	return source, nil
+}
+
+// copyObject copies the object from copy_from server-side instead of
+// uploading a file, named like an uploaded file would be.
+func (command *OutCommand) copyObject(request OutRequest, sourceDir string) (OutResponse, error) {
+	if request.Source.PrefixRegexp != "" {
+		return OutResponse{}, errors.New("copy_from is not supported with prefix_regexp")
+	}
+
+	if request.Source.SigningKey != "" {
+		return OutResponse{}, errors.New("signing_key is not supported with copy_from")
+	}
+
+	source, err := copySourceOf(*request.Params.CopyFrom, sourceDir)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	objectPath := command.objectPath(request, source.path)
+	if request.Params.ObjectName != "" {
+		objectPath, err = command.renderObjectName(request, sourceDir, path.Base(source.path))
+		if err != nil {
+			return OutResponse{}, err
+		}
+	}
+
+	generation, object, err := command.gcsClient.CopyObject(source.bucket, source.path, source.generation, request.Source.Bucket, objectPath, request.Params.PredefinedACL)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	version, url, err := command.version(request, objectPath, generation, object)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	metadata := append(command.metadata(objectPath, url, object), gcsresource.MetadataPair{
+		Name:  "copied_from",
+		Value: source.url(),
+	})
+
+	holdsMetadata, err := command.setHolds(request, objectPath, generation)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	metadata = append(metadata, holdsMetadata...)
+
+	retentionMetadata, err := command.setRetention(request, objectPath, generation)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	metadata = append(metadata, retentionMetadata...)
+
+	signedURLMetadata, err := command.signedURL(request, objectPath, generation)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	metadata = append(metadata, signedURLMetadata...)
+
+	latestMetadata, err := command.updateLatest(request, objectPath, generation, nil)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	pruneMetadata, err := command.prune(request, objectPath)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	return OutResponse{
+		Version:  version,
+		Metadata: append(append(metadata, latestMetadata...), pruneMetadata...),
+	}, nil
+}
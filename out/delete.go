@@ -0,0 +1,193 @@
+package out
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	gcsresource "github.com/syslxg/gcs-resource"
+	"github.com/syslxg/gcs-resource/versions"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// deletion is an object generation deleted by delete or the retention
+// policy.
+type deletion struct {
+	path       string
+	generation int64
+}
+
+func objectURL(bucketName string, objectPath string, generation int64) string {
+	if generation != 0 {
+		return fmt.Sprintf("gs://%s/%s#%d", bucketName, objectPath, generation)
+	}
+
+	return fmt.Sprintf("gs://%s/%s", bucketName, objectPath)
+}
+
+// deleteObject deletes the object from params.delete instead of uploading a
+// file, emitting the version it deleted.
+func (command *OutCommand) deleteObject(request OutRequest) (OutResponse, error) {
+	bucketName := request.Source.Bucket
+
+	objectPath := request.Params.Delete.Path
+	if request.Source.Regexp != "" {
+		if objectPath == "" {
+			return OutResponse{}, errors.New("please specify delete.path when using regexp")
+		}
+
+		matches, err := versions.Match([]string{objectPath}, request.Source.Regexp)
+		if err != nil {
+			return OutResponse{}, err
+		}
+		if len(matches) == 0 {
+			return OutResponse{}, fmt.Errorf("object '%s' does not match regexp", objectPath)
+		}
+	} else if objectPath == "" {
+		objectPath = request.Source.VersionedFile
+	} else if objectPath != request.Source.VersionedFile {
+		return OutResponse{}, fmt.Errorf("object '%s' is not the versioned_file", objectPath)
+	}
+
+	var generation int64
+	var object *storage.Object
+	var err error
+	if request.Params.Delete.Generation != "" {
+		generation, _ = strconv.ParseInt(request.Params.Delete.Generation, 10, 64)
+		if request.Source.VersionFrom != "" || request.Source.TrackGenerations {
+			object, err = command.gcsClient.ObjectInfo(bucketName, objectPath, generation)
+			if err != nil {
+				return OutResponse{}, err
+			}
+		}
+	} else if request.Source.Regexp == "" || request.Source.VersionFrom != "" || request.Source.TrackGenerations {
+		object, err = command.gcsClient.GetBucketObjectInfo(bucketName, objectPath)
+		if err != nil {
+			return OutResponse{}, err
+		}
+		if request.Source.Regexp == "" {
+			generation = object.Generation
+		}
+	}
+
+	// the version is resolved while the object still exists
+	version, url, err := command.version(request, objectPath, generation, object)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	deleteMetadata, err := command.deleteObjects(request, []deletion{{path: objectPath, generation: generation}})
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	pruneMetadata, err := command.prune(request, "")
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	return OutResponse{
+		Version:  version,
+		Metadata: append(append(command.metadata(objectPath, url, nil), deleteMetadata...), pruneMetadata...),
+	}, nil
+}
+
+// prune deletes the objects matching regexp, or the noncurrent generations
+// of the versioned file, beyond keep_latest and older than older_than. The
+// object just uploaded and the live versioned file are always kept.
+func (command *OutCommand) prune(request OutRequest, uploadedPath string) ([]gcsresource.MetadataPair, error) {
+	if !request.Params.prunes() {
+		return nil, nil
+	}
+
+	// kept objects are marked with a negative generation, so they still
+	// count towards keep_latest
+	var (
+		candidates []deletion
+		created    []time.Time
+	)
+	if request.Source.Regexp != "" {
+		// sorted from the oldest to the newest version
+		for _, extraction := range versions.GetBucketObjectGenerations(command.gcsClient, request.Source) {
+			if extraction.Path == uploadedPath {
+				extraction.Generation = -1
+			}
+
+			candidates = append(candidates, deletion{path: extraction.Path, generation: extraction.Generation})
+			created = append(created, extraction.Created)
+		}
+	} else {
+		objects, err := command.gcsClient.ObjectGenerationsInfo(request.Source.Bucket, request.Source.VersionedFile)
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Slice(objects, func(i, j int) bool {
+			return objects[i].Generation < objects[j].Generation
+		})
+
+		for _, object := range objects {
+			timeCreated, _ := time.Parse(time.RFC3339Nano, object.TimeCreated)
+			if object.TimeDeleted == "" {
+				object.Generation = -1
+			}
+
+			candidates = append(candidates, deletion{path: object.Name, generation: object.Generation})
+			created = append(created, timeCreated)
+		}
+	}
+
+	prunable := len(candidates)
+	if request.Params.KeepLatest > 0 {
+		prunable -= request.Params.KeepLatest
+	}
+
+	var cutoff time.Time
+	if request.Params.OlderThan != "" {
+		olderThan, _ := request.Params.OlderThanDuration()
+		cutoff = time.Now().Add(-olderThan)
+	}
+
+	var deletions []deletion
+	for i, candidate := range candidates {
+		if i >= prunable {
+			break
+		}
+
+		if candidate.generation < 0 || (!cutoff.IsZero() && !created[i].Before(cutoff)) {
+			continue
+		}
+
+		deletions = append(deletions, candidate)
+	}
+
+	return command.deleteObjects(request, deletions)
+}
+
+// deleteObjects deletes the object generations, or only lists them with
+// dry_run.
+func (command *OutCommand) deleteObjects(request OutRequest, deletions []deletion) ([]gcsresource.MetadataPair, error) {
+	bucketName := request.Source.Bucket
+
+	var metadata []gcsresource.MetadataPair
+	for _, object := range deletions {
+		url := objectURL(bucketName, object.path, object.generation)
+
+		if request.Params.DryRun {
+			gcsresource.Sayf("would delete %s\n", url)
+			metadata = append(metadata, gcsresource.MetadataPair{Name: "would_delete", Value: url})
+			continue
+		}
+
+		gcsresource.Sayf("deleting %s\n", url)
+		if err := command.gcsClient.DeleteObject(bucketName, object.path, object.generation); err != nil {
+			return nil, fmt.Errorf("failed to delete '%s': %s", url, err)
+		}
+
+		metadata = append(metadata, gcsresource.MetadataPair{Name: "deleted", Value: url})
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,164 @@
+package out
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	gcsresource "github.com/syslxg/gcs-resource"
+)
+
+// uploadWorkers bounds the number of concurrent uploads of params.files and
+// params.directory.
+const uploadWorkers = 4
+
+// upload is a local file uploaded alongside the primary file.
+type upload struct {
+	localPath  string
+	objectPath string
+}
+
+// additionalUploads lists the files matched by params.files and the files
+// below params.directory, other than the primary file. They are uploaded
+// next to the primary object, files below the directory keeping their
+// relative path.
+func (command *OutCommand) additionalUploads(request OutRequest, sourceDir string, primaryPath string, primaryObject string) ([]upload, error) {
+	objectDir := path.Dir(primaryObject)
+	if objectDir == "." {
+		objectDir = ""
+	} else {
+		objectDir += "/"
+	}
+
+	objectPaths := map[string]string{primaryObject: primaryPath}
+	var uploads []upload
+
+	add := func(localPath string, relativePath string) error {
+		if localPath == primaryPath {
+			return nil
+		}
+
+		objectPath := objectDir + filepath.ToSlash(relativePath)
+		if other, ok := objectPaths[objectPath]; ok {
+			if other == localPath {
+				return nil
+			}
+
+			return fmt.Errorf("files '%s' and '%s' would both be uploaded to '%s'", other, localPath, objectPath)
+		}
+		objectPaths[objectPath] = localPath
+
+		uploads = append(uploads, upload{localPath: localPath, objectPath: objectPath})
+		return nil
+	}
+
+	for _, pattern := range request.Params.Files {
+		matches, err := filepath.Glob(filepath.Join(sourceDir, pattern))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no matches found for pattern: %s", pattern)
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+
+			if info.IsDir() {
+				continue
+			}
+
+			if err := add(match, filepath.Base(match)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if request.Params.Directory != "" {
+		directory := filepath.Join(sourceDir, request.Params.Directory)
+
+		err := filepath.Walk(directory, func(localPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			relativePath, err := filepath.Rel(directory, localPath)
+			if err != nil {
+				return err
+			}
+
+			return add(localPath, relativePath)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].objectPath < uploads[j].objectPath
+	})
+
+	return uploads, nil
+}
+
+// uploadFiles uploads files with a pool of uploadWorkers, returning the
+// uploaded object paths as metadata. With if_not_exists or skip_if_exists,
+// existing objects fail or are skipped.
+func (command *OutCommand) uploadFiles(request OutRequest, uploads []upload) ([]gcsresource.MetadataPair, error) {
+	jobs := make(chan int)
+	errs := make(chan error, len(uploads))
+	skipped := make([]bool, len(uploads))
+
+	var wg sync.WaitGroup
+	for i := 0; i < uploadWorkers && i < len(uploads); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				var err error
+				_, _, skipped[job], err = command.uploadFile(request, uploads[job].objectPath, uploads[job].localPath, command.existencePrecondition(request))
+				if err != nil {
+					errs <- fmt.Errorf("failed to upload '%s': %s", uploads[job].objectPath, err)
+				}
+			}
+		}()
+	}
+
+	for job := range uploads {
+		jobs <- job
+	}
+	close(jobs)
+
+	wg.Wait()
+	close(errs)
+
+	if err, failed := <-errs; failed {
+		return nil, err
+	}
+
+	metadata := make([]gcsresource.MetadataPair, 0, len(uploads))
+	for i, job := range uploads {
+		if skipped[i] {
+			continue
+		}
+
+		metadata = append(metadata, gcsresource.MetadataPair{
+			Name:  "uploaded",
+			Value: job.objectPath,
+		})
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,48 @@
+package out
+
+import (
+	"fmt"
+	"time"
+
+	gcsresource "github.com/syslxg/gcs-resource"
+)
+
+func (params Params) holds() (gcsresource.ObjectHolds, bool) {
+	holds := gcsresource.ObjectHolds{
+		TemporaryHold:  params.TemporaryHold,
+		EventBasedHold: params.EventBasedHold,
+	}
+
+	return holds, holds.TemporaryHold != nil || holds.EventBasedHold != nil
+}
+
+// setHolds places or releases the holds from params on the uploaded object
+// and returns the holds in place as metadata.
+func (command *OutCommand) setHolds(request OutRequest, objectPath string, generation int64) ([]gcsresource.MetadataPair, error) {
+	holds, ok := request.Params.holds()
+	if !ok {
+		return nil, nil
+	}
+
+	object, err := command.gcsClient.SetObjectHolds(request.Source.Bucket, objectPath, generation, holds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set holds on '%s': %s", objectPath, err)
+	}
+
+	return gcsresource.HoldsMetadata(object), nil
+}
+
+// setRetention sets the retention configuration from params on the uploaded
+// object and returns it as metadata.
+func (command *OutCommand) setRetention(request OutRequest, objectPath string, generation int64) ([]gcsresource.MetadataPair, error) {
+	if request.Params.Retention == nil {
+		return nil, nil
+	}
+
+	retention, err := command.gcsClient.SetObjectRetention(request.Source.Bucket, objectPath, generation, request.Params.Retention.objectRetention(time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set retention on '%s': %s", objectPath, err)
+	}
+
+	return gcsresource.RetentionMetadata(retention), nil
+}
@@ -0,0 +1,111 @@
+package out
+
+import (
+	"encoding/json"
+	"fmt"
+
+	gcsresource "github.com/syslxg/gcs-resource"
+	"github.com/syslxg/gcs-resource/versions"
+)
+
+// latestPointer is the content of params.latest_pointer, pointing at the
+// object last uploaded.
+type latestPointer struct {
+	Path       string            `json:"path"`
+	Generation string            `json:"generation,omitempty"`
+	URL        string            `json:"url"`
+	Checksums  map[string]string `json:"checksums"`
+}
+
+// checkLatest makes sure the alias and pointer are not picked up as
+// versions themselves.
+func checkLatest(request OutRequest) error {
+	for _, latest := range []struct{ param, objectPath string }{
+		{"latest_alias", request.Params.LatestAlias},
+		{"latest_pointer", request.Params.LatestPointer},
+	} {
+		if latest.objectPath == "" {
+			continue
+		}
+
+		if request.Source.Regexp == "" {
+			if latest.objectPath == request.Source.VersionedFile {
+				return fmt.Errorf("%s '%s' must not be the versioned_file", latest.param, latest.objectPath)
+			}
+			continue
+		}
+
+		matches, err := versions.Match([]string{latest.objectPath}, request.Source.Regexp)
+		if err != nil {
+			return err
+		}
+		if len(matches) > 0 {
+			return fmt.Errorf("%s '%s' must not match regexp", latest.param, latest.objectPath)
+		}
+	}
+
+	return nil
+}
+
+// updateLatest copies the uploaded object to latest_alias and writes
+// latest_pointer, returning their urls as metadata. checksums are the
+// digests of checksum_files, if any.
+func (command *OutCommand) updateLatest(request OutRequest, objectPath string, generation int64, checksums []gcsresource.MetadataPair) ([]gcsresource.MetadataPair, error) {
+	bucketName := request.Source.Bucket
+
+	var metadata []gcsresource.MetadataPair
+
+	if alias := request.Params.LatestAlias; alias != "" {
+		aliasGeneration, _, err := command.gcsClient.CopyObject(bucketName, objectPath, generation, bucketName, alias, request.Params.PredefinedACL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update latest_alias '%s': %s", alias, err)
+		}
+
+		metadata = append(metadata, gcsresource.MetadataPair{
+			Name:  "latest_alias",
+			Value: objectURL(bucketName, alias, aliasGeneration),
+		})
+	}
+
+	if pointerPath := request.Params.LatestPointer; pointerPath != "" {
+		object, err := command.gcsClient.GetBucketObjectInfo(bucketName, objectPath)
+		if err != nil {
+			return nil, err
+		}
+
+		pointer := latestPointer{
+			Path:      objectPath,
+			URL:       objectURL(bucketName, objectPath, generation),
+			Checksums: map[string]string{},
+		}
+		if generation != 0 {
+			pointer.Generation = fmt.Sprintf("%d", generation)
+		}
+
+		for algorithm, encoded := range map[string]string{"md5": object.Md5Hash, "crc32c": object.Crc32c} {
+			if digest := gcsresource.HexDigest(encoded); digest != "" {
+				pointer.Checksums[algorithm] = digest
+			}
+		}
+		for _, checksum := range checksums {
+			pointer.Checksums[checksum.Name] = checksum.Value
+		}
+
+		content, err := json.MarshalIndent(pointer, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		pointerGeneration, err := command.uploadContent(request, pointerPath, append(content, '\n'), "application/json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to update latest_pointer '%s': %s", pointerPath, err)
+		}
+
+		metadata = append(metadata, gcsresource.MetadataPair{
+			Name:  "latest_pointer",
+			Value: objectURL(bucketName, pointerPath, pointerGeneration),
+		})
+	}
+
+	return metadata, nil
+}
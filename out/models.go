@@ -1,6 +1,11 @@
 package out
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	gcsresource "github.com/syslxg/gcs-resource"
 )
 
@@ -10,18 +15,286 @@ type OutRequest struct {
 }
 
 type Params struct {
-	File                    string `json:"file"`
-	PredefinedACL           string `json:"predefined_acl"`
-	ContentType             string `json:"content_type"`
-	CacheControl            string `json:"cache_control"`
-	ParallelUploadThreshold int    `json:"parallel_upload_threshold"`
+	File                    string     `json:"file"`
+	PredefinedACL           string     `json:"predefined_acl"`
+	ContentType             string     `json:"content_type"`
+	CacheControl            string     `json:"cache_control"`
+	ParallelUploadThreshold int        `json:"parallel_upload_threshold"`
+	ChecksumFiles           []string   `json:"checksum_files"`
+	Files                   []string   `json:"files"`
+	Directory               string     `json:"directory"`
+	ObjectName              string     `json:"object_name"`
+	VersionFile             string     `json:"version_file"`
+	IfNotExists             bool       `json:"if_not_exists"`
+	IfGenerationMatch       string     `json:"if_generation_match"`
+	SkipIfExists            bool       `json:"skip_if_exists"`
+	CopyFrom                *CopyFrom  `json:"copy_from"`
+	Delete                  *Delete    `json:"delete"`
+	KeepLatest              int        `json:"keep_latest"`
+	OlderThan               string     `json:"older_than"`
+	DryRun                  bool       `json:"dry_run"`
+	LatestAlias             string     `json:"latest_alias"`
+	LatestPointer           string     `json:"latest_pointer"`
+	TemporaryHold           *bool      `json:"temporary_hold"`
+	EventBasedHold          *bool      `json:"event_based_hold"`
+	SignedURLTTL            string     `json:"signed_url_ttl"`
+	Retention               *Retention `json:"retention"`
+}
+
+// Retention is the retention configuration set on the uploaded or copied
+// object, retained until retain_until or for retain_for. The mode defaults
+// to Unlocked.
+type Retention struct {
+	Mode        string `json:"mode"`
+	RetainUntil string `json:"retain_until"`
+	RetainFor   string `json:"retain_for"`
+}
+
+// CopyFrom is the object copied server-side instead of uploading a file,
+// given by its bucket, path and optional generation, or by the url file
+// written by a get.
+type CopyFrom struct {
+	Bucket     string `json:"bucket"`
+	Path       string `json:"path"`
+	Generation string `json:"generation"`
+	URLFile    string `json:"url_file"`
+}
+
+// Delete is the object deleted instead of uploading a file. The path
+// defaults to the versioned file, the generation to the live object.
+type Delete struct {
+	Path       string `json:"path"`
+	Generation string `json:"generation"`
 }
 
 func (params Params) IsValid() (bool, string) {
-	if params.File == "" {
+	modes := 0
+	for _, set := range []bool{params.File != "", params.CopyFrom != nil, params.Delete != nil} {
+		if set {
+			modes++
+		}
+	}
+
+	if modes == 0 {
 		return false, "please specify the file"
 	}
 
+	if modes > 1 {
+		return false, "please specify only one of file, copy_from or delete"
+	}
+
+	if params.CopyFrom != nil {
+		if ok, message := params.CopyFrom.IsValid(); !ok {
+			return false, message
+		}
+
+		if len(params.Files) > 0 || params.Directory != "" || len(params.ChecksumFiles) > 0 {
+			return false, "files, directory and checksum_files are not supported with copy_from"
+		}
+
+		if params.IfNotExists || params.IfGenerationMatch != "" || params.SkipIfExists {
+			return false, "if_not_exists, if_generation_match and skip_if_exists are not supported with copy_from"
+		}
+	}
+
+	if params.Delete != nil {
+		if ok, message := params.Delete.IsValid(); !ok {
+			return false, message
+		}
+
+		if len(params.Files) > 0 || params.Directory != "" || len(params.ChecksumFiles) > 0 {
+			return false, "files, directory and checksum_files are not supported with delete"
+		}
+
+		if params.IfNotExists || params.IfGenerationMatch != "" || params.SkipIfExists {
+			return false, "if_not_exists, if_generation_match and skip_if_exists are not supported with delete"
+		}
+
+		if params.ObjectName != "" {
+			return false, "object_name is not supported with delete"
+		}
+
+		if params.LatestAlias != "" || params.LatestPointer != "" {
+			return false, "latest_alias and latest_pointer are not supported with delete"
+		}
+
+		if _, ok := params.holds(); ok {
+			return false, "temporary_hold and event_based_hold are not supported with delete"
+		}
+
+		if params.SignedURLTTL != "" {
+			return false, "signed_url_ttl is not supported with delete"
+		}
+
+		if params.Retention != nil {
+			return false, "retention is not supported with delete"
+		}
+	}
+
+	if params.Retention != nil {
+		if ok, message := params.Retention.IsValid(); !ok {
+			return false, message
+		}
+	}
+
+	if params.KeepLatest < 0 {
+		return false, fmt.Sprintf("invalid keep_latest value specified: %d", params.KeepLatest)
+	}
+
+	if params.OlderThan != "" {
+		if _, err := params.OlderThanDuration(); err != nil {
+			return false, fmt.Sprintf("invalid older_than value specified: %s", params.OlderThan)
+		}
+	}
+
+	if params.SignedURLTTL != "" {
+		if _, err := gcsresource.ParseSignedURLTTL(params.SignedURLTTL); err != nil {
+			return false, fmt.Sprintf("invalid signed_url_ttl value specified: %s", params.SignedURLTTL)
+		}
+	}
+
+	if params.DryRun && params.Delete == nil && !params.prunes() {
+		return false, "please specify delete, keep_latest or older_than when using dry_run"
+	}
+
+	if params.IfNotExists && params.IfGenerationMatch != "" {
+		return false, "please specify either if_not_exists or if_generation_match"
+	}
+
+	if params.SkipIfExists && params.IfGenerationMatch != "" {
+		return false, "skip_if_exists is not supported with if_generation_match"
+	}
+
+	if params.ObjectName != "" {
+		if _, err := parseObjectName(params.ObjectName); err != nil {
+			return false, fmt.Sprintf("invalid object_name template specified: %s", params.ObjectName)
+		}
+	}
+
+	if params.VersionFile != "" && params.ObjectName == "" {
+		return false, "please specify object_name when using version_file"
+	}
+
+	for _, algorithm := range params.ChecksumFiles {
+		if !gcsresource.IsChecksumAlgorithm(algorithm) {
+			return false, fmt.Sprintf("invalid checksum_files value specified: %s", algorithm)
+		}
+	}
+
+	return true, ""
+}
+
+// OlderThanDuration parses older_than, a duration like "720h" or a number
+// of days like "30d".
+func (params Params) OlderThanDuration() (time.Duration, error) {
+	return parseDuration(params.OlderThan)
+}
+
+// parseDuration parses a positive duration like "720h" or a number of days
+// like "30d".
+func parseDuration(value string) (time.Duration, error) {
+	var (
+		duration time.Duration
+		err      error
+	)
+	if strings.HasSuffix(value, "d") {
+		var days int
+		days, err = strconv.Atoi(strings.TrimSuffix(value, "d"))
+		duration = time.Duration(days) * 24 * time.Hour
+	} else {
+		duration, err = time.ParseDuration(value)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if duration <= 0 {
+		return 0, fmt.Errorf("duration must be positive: %s", value)
+	}
+
+	return duration, nil
+}
+
+// prunes reports whether a retention policy is set.
+func (params Params) prunes() bool {
+	return params.KeepLatest > 0 || params.OlderThan != ""
+}
+
+func (copyFrom CopyFrom) IsValid() (bool, string) {
+	if copyFrom.URLFile != "" {
+		if copyFrom.Bucket != "" || copyFrom.Path != "" || copyFrom.Generation != "" {
+			return false, "please specify either copy_from.url_file or copy_from.bucket and copy_from.path"
+		}
+
+		return true, ""
+	}
+
+	if copyFrom.Bucket == "" || copyFrom.Path == "" {
+		return false, "please specify either copy_from.url_file or copy_from.bucket and copy_from.path"
+	}
+
+	if copyFrom.Generation != "" {
+		if _, err := strconv.ParseInt(copyFrom.Generation, 10, 64); err != nil {
+			return false, fmt.Sprintf("invalid copy_from.generation value specified: %s", copyFrom.Generation)
+		}
+	}
+
+	return true, ""
+}
+
+func (retention Retention) IsValid() (bool, string) {
+	switch retention.Mode {
+	case "", "Unlocked", "Locked":
+	default:
+		return false, fmt.Sprintf("invalid retention.mode value specified: %s", retention.Mode)
+	}
+
+	if (retention.RetainUntil == "") == (retention.RetainFor == "") {
+		return false, "please specify either retention.retain_until or retention.retain_for"
+	}
+
+	if retention.RetainUntil != "" {
+		if _, err := time.Parse(time.RFC3339, retention.RetainUntil); err != nil {
+			return false, fmt.Sprintf("invalid retention.retain_until value specified: %s", retention.RetainUntil)
+		}
+	}
+
+	if retention.RetainFor != "" {
+		if _, err := parseDuration(retention.RetainFor); err != nil {
+			return false, fmt.Sprintf("invalid retention.retain_for value specified: %s", retention.RetainFor)
+		}
+	}
+
+	return true, ""
+}
+
+// objectRetention returns the retention configuration of an object uploaded
+// at now.
+func (retention Retention) objectRetention(now time.Time) gcsresource.ObjectRetention {
+	mode := retention.Mode
+	if mode == "" {
+		mode = "Unlocked"
+	}
+
+	retainUntil, _ := time.Parse(time.RFC3339, retention.RetainUntil)
+	if retention.RetainFor != "" {
+		retainFor, _ := parseDuration(retention.RetainFor)
+		retainUntil = now.Add(retainFor)
+	}
+
+	return gcsresource.ObjectRetention{
+		Mode:            mode,
+		RetainUntilTime: retainUntil.UTC().Format(time.RFC3339),
+	}
+}
+
+func (deletion Delete) IsValid() (bool, string) {
+	if deletion.Generation != "" {
+		if _, err := strconv.ParseInt(deletion.Generation, 10, 64); err != nil {
+			return false, fmt.Sprintf("invalid delete.generation value specified: %s", deletion.Generation)
+		}
+	}
+
 	return true, ""
 }
 
@@ -0,0 +1,87 @@
+package out
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/syslxg/gcs-resource/versions"
+)
+
+// objectNameTemplate is the data params.object_name is rendered with.
+type objectNameTemplate struct {
+	// the base name of the local file
+	Name string
+	// the contents of params.version_file
+	Version string
+	// the environment, e.g. BUILD_ID or BUILD_PIPELINE_NAME
+	Env map[string]string
+	// the upload time in UTC, formatted as 20060102150405
+	Timestamp string
+	// the upload time in UTC
+	Time time.Time
+}
+
+func parseObjectName(text string) (*template.Template, error) {
+	return template.New("object_name").Option("missingkey=error").Parse(text)
+}
+
+// renderObjectName renders params.object_name for the local file. The
+// rendered name is the full object path and must match source.regexp.
+func (command *OutCommand) renderObjectName(request OutRequest, sourceDir string, localPath string) (string, error) {
+	tmpl, err := parseObjectName(request.Params.ObjectName)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	data := objectNameTemplate{
+		Name:      filepath.Base(localPath),
+		Env:       environment(),
+		Timestamp: now.Format("20060102150405"),
+		Time:      now,
+	}
+
+	if request.Params.VersionFile != "" {
+		contents, err := ioutil.ReadFile(filepath.Join(sourceDir, request.Params.VersionFile))
+		if err != nil {
+			return "", err
+		}
+
+		data.Version = strings.TrimSpace(string(contents))
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("failed to render object_name: %s", err)
+	}
+
+	objectName := rendered.String()
+
+	matches, err := versions.Match([]string{objectName}, request.Source.Regexp)
+	if err != nil {
+		return "", err
+	}
+
+	if len(matches) == 0 {
+		return "", fmt.Errorf("object name '%s' does not match regexp", objectName)
+	}
+
+	return objectName, nil
+}
+
+func environment() map[string]string {
+	env := map[string]string{}
+	for _, variable := range os.Environ() {
+		if i := strings.Index(variable, "="); i > 0 {
+			env[variable[:i]] = variable[i+1:]
+		}
+	}
+
+	return env
+}
@@ -3,10 +3,13 @@ package out
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
 	gcsresource "github.com/syslxg/gcs-resource"
+	"github.com/syslxg/gcs-resource/versions"
+	storage "google.golang.org/api/storage/v1"
 )
 
 type OutCommand struct {
@@ -28,25 +31,226 @@ func (command *OutCommand) Run(sourceDir string, request OutRequest) (OutRespons
 		return OutResponse{}, errors.New(message)
 	}
 
+	if request.Source.VersionFrom != "" {
+		if _, err := versions.ParseVersionFrom(request.Source.VersionFrom); err != nil {
+			return OutResponse{}, err
+		}
+	}
+
+	if request.Source.PrefixRegexp != "" && len(request.Params.ChecksumFiles) > 0 {
+		return OutResponse{}, errors.New("checksum_files is not supported with prefix_regexp")
+	}
+
+	if request.Source.PrefixRegexp != "" && (len(request.Params.Files) > 0 || request.Params.Directory != "") {
+		return OutResponse{}, errors.New("files and directory are not supported with prefix_regexp")
+	}
+
+	if request.Source.PrefixRegexp != "" && (request.Params.IfNotExists || request.Params.IfGenerationMatch != "" || request.Params.SkipIfExists) {
+		return OutResponse{}, errors.New("if_not_exists, if_generation_match and skip_if_exists are not supported with prefix_regexp")
+	}
+
+	if request.Params.ObjectName != "" && request.Source.Regexp == "" {
+		return OutResponse{}, errors.New("please specify regexp when using object_name")
+	}
+
+	if request.Source.PrefixRegexp != "" && (request.Params.LatestAlias != "" || request.Params.LatestPointer != "") {
+		return OutResponse{}, errors.New("latest_alias and latest_pointer are not supported with prefix_regexp")
+	}
+
+	if _, ok := request.Params.holds(); ok && request.Source.PrefixRegexp != "" {
+		return OutResponse{}, errors.New("temporary_hold and event_based_hold are not supported with prefix_regexp")
+	}
+
+	if request.Source.PrefixRegexp != "" && request.Params.Retention != nil {
+		return OutResponse{}, errors.New("retention is not supported with prefix_regexp")
+	}
+
+	if request.Source.PrefixRegexp != "" && request.Params.SignedURLTTL != "" {
+		return OutResponse{}, errors.New("signed_url_ttl is not supported with prefix_regexp")
+	}
+
+	if err := checkLatest(request); err != nil {
+		return OutResponse{}, err
+	}
+
+	var signingKey signer
+	if request.Source.SigningKey != "" {
+		if request.Source.PrefixRegexp != "" {
+			return OutResponse{}, errors.New("signing_key is not supported with prefix_regexp")
+		}
+
+		var err error
+		signingKey, err = parseSigningKey(request.Source.SigningKey)
+		if err != nil {
+			return OutResponse{}, err
+		}
+	}
+
+	if request.Source.PrefixRegexp != "" && (request.Params.Delete != nil || request.Params.prunes()) {
+		return OutResponse{}, errors.New("delete, keep_latest and older_than are not supported with prefix_regexp")
+	}
+
+	if request.Params.CopyFrom != nil {
+		return command.copyObject(request, sourceDir)
+	}
+
+	if request.Params.Delete != nil {
+		return command.deleteObject(request)
+	}
+
 	localPath, err := command.localPath(request, sourceDir)
 	if err != nil {
 		return OutResponse{}, err
 	}
 
+	if request.Source.PrefixRegexp != "" {
+		return command.uploadDirectory(request, localPath)
+	}
+
 	objectPath := command.objectPath(request, localPath)
+	if request.Params.ObjectName != "" {
+		objectPath, err = command.renderObjectName(request, sourceDir, localPath)
+		if err != nil {
+			return OutResponse{}, err
+		}
+	}
 
-	objectContentType := command.objectContentType(request)
+	ifGenerationMatch, err := command.precondition(request, sourceDir)
+	if err != nil {
+		return OutResponse{}, err
+	}
 
-	bucketName := request.Source.Bucket
-	parallelUploadThreshold := command.ParallelUploadThreshold(request)
-	generation, err := command.gcsClient.UploadFile(bucketName, objectPath, objectContentType, localPath, request.Params.PredefinedACL, request.Params.CacheControl, parallelUploadThreshold)
+	uploads, err := command.additionalUploads(request, sourceDir, localPath, objectPath)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	generation, object, skipped, err := command.checkPrecondition(request, objectPath, ifGenerationMatch)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	var uploadMetadata, checksumMetadata, signatureMetadata []gcsresource.MetadataPair
+	if !skipped {
+		// the primary file is uploaded last, so its version is only seen once
+		// every other file, its checksums and its signature are in place
+		uploadMetadata, err = command.uploadFiles(request, uploads)
+		if err != nil {
+			return OutResponse{}, err
+		}
+
+		if len(request.Params.ChecksumFiles) > 0 {
+			checksumMetadata, err = command.uploadChecksums(request, objectPath, localPath)
+			if err != nil {
+				return OutResponse{}, err
+			}
+		}
+
+		if request.Source.SigningKey != "" {
+			versioned := request.Source.Regexp == "" || request.Source.TrackGenerations
+
+			signatureMetadata, err = command.uploadSignature(request, signingKey, objectPath, localPath, versioned)
+			if err != nil {
+				return OutResponse{}, err
+			}
+		}
+
+		generation, object, skipped, err = command.uploadFile(request, objectPath, localPath, ifGenerationMatch)
+		if err != nil {
+			return OutResponse{}, err
+		}
+	}
+
+	version, url, err := command.version(request, objectPath, generation, object)
 	if err != nil {
 		return OutResponse{}, err
 	}
 
+	metadata := append(command.metadata(objectPath, url, object), uploadMetadata...)
+
+	if skipped {
+		return OutResponse{
+			Version:  version,
+			Metadata: append(metadata, gcsresource.MetadataPair{Name: "skipped", Value: "true"}),
+		}, nil
+	}
+
+	metadata = append(append(metadata, checksumMetadata...), signatureMetadata...)
+
+	holdsMetadata, err := command.setHolds(request, objectPath, generation)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	metadata = append(metadata, holdsMetadata...)
+
+	retentionMetadata, err := command.setRetention(request, objectPath, generation)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	metadata = append(metadata, retentionMetadata...)
+
+	signedURLMetadata, err := command.signedURL(request, objectPath, generation)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	metadata = append(metadata, signedURLMetadata...)
+
+	latestMetadata, err := command.updateLatest(request, objectPath, generation, checksumMetadata)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	pruneMetadata, err := command.prune(request, objectPath)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	return OutResponse{
+		Version:  version,
+		Metadata: append(append(metadata, latestMetadata...), pruneMetadata...),
+	}, nil
+}
+
+// signedURL returns a signed https url of the uploaded object, valid for
+// signed_url_ttl, as metadata.
+func (command *OutCommand) signedURL(request OutRequest, objectPath string, generation int64) ([]gcsresource.MetadataPair, error) {
+	if request.Params.SignedURLTTL == "" {
+		return nil, nil
+	}
+
+	ttl, _ := gcsresource.ParseSignedURLTTL(request.Params.SignedURLTTL)
+	signedURL, err := command.gcsClient.SignedURL(request.Source.Bucket, objectPath, generation, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return []gcsresource.MetadataPair{{Name: "signed_url", Value: signedURL}}, nil
+}
+
+// version returns the version of an uploaded or copied object and its url.
+func (command *OutCommand) version(request OutRequest, objectPath string, generation int64, object *storage.Object) (gcsresource.Version, string, error) {
+	bucketName := request.Source.Bucket
+
 	var url string
 	version := gcsresource.Version{}
-	if request.Source.Regexp != "" {
+	if request.Source.Regexp != "" && request.Source.VersionFrom != "" {
+		attribution, err := command.attribute(request, object)
+		if err != nil {
+			return gcsresource.Version{}, "", err
+		}
+		version.Path = objectPath
+		version.Value = attribution.Value
+		url, _ = command.gcsClient.URL(bucketName, objectPath, 0)
+	} else if request.Source.Regexp != "" && request.Source.TrackGenerations {
+		// the object reports its generation even when the bucket is not
+		// versioned, matching the version check emits
+		version.Path = objectPath
+		version.Generation = fmt.Sprintf("%d", object.Generation)
+		url, _ = command.gcsClient.URL(bucketName, objectPath, object.Generation)
+	} else if request.Source.Regexp != "" {
 		version.Path = objectPath
 		url, _ = command.gcsClient.URL(bucketName, objectPath, 0)
 	} else {
@@ -54,12 +258,82 @@ func (command *OutCommand) Run(sourceDir string, request OutRequest) (OutRespons
 		url, _ = command.gcsClient.URL(bucketName, objectPath, generation)
 	}
 
+	return version, url, nil
+}
+
+func (command *OutCommand) uploadDirectory(request OutRequest, localPath string) (OutResponse, error) {
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	if !stat.IsDir() {
+		return OutResponse{}, fmt.Errorf("please specify a directory when using prefix_regexp: %s", request.Params.File)
+	}
+
+	prefix := command.objectPrefix(request, localPath)
+
+	matches, err := versions.Match([]string{prefix}, versions.PrefixPattern(request.Source.PrefixRegexp))
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	if len(matches) == 0 {
+		return OutResponse{}, fmt.Errorf("object prefix '%s' does not match prefix_regexp", prefix)
+	}
+
+	bucketName := request.Source.Bucket
+	objectContentType := command.objectContentType(request)
+	parallelUploadThreshold := command.ParallelUploadThreshold(request)
+
+	err = filepath.Walk(localPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(localPath, path)
+		if err != nil {
+			return err
+		}
+
+		objectPath := prefix + filepath.ToSlash(relativePath)
+		_, _, err = command.gcsClient.UploadFile(bucketName, objectPath, objectContentType, path, request.Params.PredefinedACL, request.Params.CacheControl, parallelUploadThreshold)
+		return err
+	})
+	if err != nil {
+		return OutResponse{}, err
+	}
+
+	url := fmt.Sprintf("gs://%s/%s", bucketName, prefix)
+
 	return OutResponse{
-		Version:  version,
-		Metadata: command.metadata(objectPath, url),
+		Version: gcsresource.Version{
+			Path: prefix,
+		},
+		Metadata: command.metadata(prefix, url, nil),
 	}, nil
 }
 
+// attribute reads the version_from attribute of the object returned by the
+// upload or copy, so a concurrent writer can not change the version.
+func (command *OutCommand) attribute(request OutRequest, object *storage.Object) (versions.Attribution, error) {
+	versionFrom, err := versions.ParseVersionFrom(request.Source.VersionFrom)
+	if err != nil {
+		return versions.Attribution{}, err
+	}
+
+	attribution, ok := versions.Attribute(object, versionFrom)
+	if !ok {
+		return versions.Attribution{}, fmt.Errorf("uploaded object does not have a value for version_from: %s", request.Source.VersionFrom)
+	}
+
+	return attribution, nil
+}
+
 func (command *OutCommand) localPath(request OutRequest, sourceDir string) (string, error) {
 	pattern := request.Params.File
 	matches, err := filepath.Glob(filepath.Join(sourceDir, pattern))
@@ -94,6 +368,10 @@ func (command *OutCommand) objectPath(request OutRequest, localPath string) stri
 	}
 }
 
+func (command *OutCommand) objectPrefix(request OutRequest, localPath string) string {
+	return parentDir(strings.TrimSuffix(request.Source.PrefixRegexp, "/")) + filepath.Base(localPath) + "/"
+}
+
 func (command *OutCommand) objectContentType(request OutRequest) string {
 	return request.Params.ContentType
 }
@@ -102,7 +380,9 @@ func parentDir(regexp string) string {
 	return regexp[:strings.LastIndex(regexp, "/")+1]
 }
 
-func (command *OutCommand) metadata(objectPath string, url string) []gcsresource.MetadataPair {
+// metadata describes the uploaded object, along with the object resource if
+// there is one.
+func (command *OutCommand) metadata(objectPath string, url string, object *storage.Object) []gcsresource.MetadataPair {
 	objectFilename := filepath.Base(objectPath)
 
 	metadata := []gcsresource.MetadataPair{
@@ -116,5 +396,9 @@ func (command *OutCommand) metadata(objectPath string, url string) []gcsresource
 		},
 	}
 
+	if object != nil {
+		metadata = append(metadata, gcsresource.ObjectMetadata(object)...)
+	}
+
 	return metadata
 }
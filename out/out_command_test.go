@@ -1,16 +1,33 @@
 package out_test
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/syslxg/gcs-resource"
 	"github.com/syslxg/gcs-resource/fakes"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	storage "google.golang.org/api/storage/v1"
 
 	. "github.com/syslxg/gcs-resource/out"
 )
@@ -46,6 +63,9 @@ var _ = Describe("Out Command", func() {
 			}
 
 			gcsClient = &fakes.FakeGCSClient{}
+			gcsClient.UploadFileReturns(0, &storage.Object{}, nil)
+			gcsClient.UploadFileIfGenerationMatchReturns(0, &storage.Object{}, nil)
+			gcsClient.CopyObjectReturns(0, &storage.Object{}, nil)
 			command = NewOutCommand(gcsClient)
 		})
 
@@ -152,7 +172,7 @@ var _ = Describe("Out Command", func() {
 			})
 
 			It("returns a response", func() {
-				gcsClient.UploadFileReturns(int64(12345), nil)
+				gcsClient.UploadFileReturns(int64(12345), &storage.Object{}, nil)
 				gcsClient.URLReturns("gs://bucket-name/folder/file.tgz", nil)
 
 				response, err := command.Run(sourceDir, request)
@@ -175,7 +195,7 @@ var _ = Describe("Out Command", func() {
 			})
 
 			It("returns an error if upload fails", func() {
-				gcsClient.UploadFileReturns(int64(0), errors.New("error uploading file"))
+				gcsClient.UploadFileReturns(int64(0), nil, errors.New("error uploading file"))
 
 				_, err := command.Run(sourceDir, request)
 				Expect(err).To(HaveOccurred())
@@ -211,6 +231,165 @@ var _ = Describe("Out Command", func() {
 			})
 		})
 
+		Describe("with regexp and track_generations", func() {
+			BeforeEach(func() {
+				request.Source.Regexp = "folder/file-(.*).tgz"
+				request.Source.TrackGenerations = true
+				createFile("files/file.tgz")
+			})
+
+			It("returns a response with the uploaded generation", func() {
+				gcsClient.UploadFileReturns(int64(12345), &storage.Object{Generation: 12345}, nil)
+				gcsClient.URLReturns("gs://bucket-name/folder/file.tgz#12345", nil)
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.URLCallCount()).To(Equal(1))
+				bucketName, objectPath, generation := gcsClient.URLArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(objectPath).To(Equal("folder/file.tgz"))
+				Expect(generation).To(Equal(int64(12345)))
+
+				Expect(response.Version.Path).To(Equal("folder/file.tgz"))
+				Expect(response.Version.Generation).To(Equal("12345"))
+
+				Expect(response.Metadata[1].Name).To(Equal("url"))
+				Expect(response.Metadata[1].Value).To(Equal("gs://bucket-name/folder/file.tgz#12345"))
+			})
+
+			It("returns the generation of the object on an unversioned bucket", func() {
+				gcsClient.UploadFileReturns(int64(0), &storage.Object{Generation: 12345}, nil)
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(response.Version.Path).To(Equal("folder/file.tgz"))
+				Expect(response.Version.Generation).To(Equal("12345"))
+			})
+		})
+
+		Describe("with regexp and version_from", func() {
+			BeforeEach(func() {
+				request.Source.Regexp = "folder/file.tgz"
+				request.Source.VersionFrom = "md5"
+				createFile("files/file.tgz")
+			})
+
+			It("returns a response with the attribute of the uploaded object", func() {
+				gcsClient.UploadFileReturns(int64(0), &storage.Object{Name: "folder/file.tgz", Md5Hash: "XUFAKrxLKna5cZ2REBfFkg=="}, nil)
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.GetBucketObjectInfoCallCount()).To(Equal(0))
+
+				Expect(response.Version.Path).To(Equal("folder/file.tgz"))
+				Expect(response.Version.Value).To(Equal("5d41402abc4b2a76b9719d911017c592"))
+			})
+
+			It("returns a response with the attribute of the copied object", func() {
+				request.Params.File = ""
+				request.Params.CopyFrom = &CopyFrom{Bucket: "other-bucket", Path: "folder/file.tgz"}
+				gcsClient.CopyObjectReturns(int64(0), &storage.Object{Name: "folder/file.tgz", Md5Hash: "XUFAKrxLKna5cZ2REBfFkg=="}, nil)
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.GetBucketObjectInfoCallCount()).To(Equal(0))
+				Expect(response.Version.Value).To(Equal("5d41402abc4b2a76b9719d911017c592"))
+			})
+
+			It("returns a response with the attribute of the deleted generation", func() {
+				request.Params.File = ""
+				request.Params.Delete = &Delete{Path: "folder/file.tgz", Generation: "12345"}
+				gcsClient.ObjectInfoReturns(&storage.Object{Name: "folder/file.tgz", Generation: 12345, Md5Hash: "XUFAKrxLKna5cZ2REBfFkg=="}, nil)
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, objectPath, generation := gcsClient.ObjectInfoArgsForCall(0)
+				Expect(objectPath).To(Equal("folder/file.tgz"))
+				Expect(generation).To(Equal(int64(12345)))
+				Expect(response.Version.Value).To(Equal("5d41402abc4b2a76b9719d911017c592"))
+			})
+
+			It("returns an error if the uploaded object has no such attribute", func() {
+				gcsClient.UploadFileReturns(int64(0), &storage.Object{Name: "folder/file.tgz"}, nil)
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("uploaded object does not have a value for version_from: md5"))
+			})
+
+			It("returns an error if version_from is invalid", func() {
+				request.Source.VersionFrom = "size"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(0))
+			})
+		})
+
+		Describe("with prefix_regexp", func() {
+			BeforeEach(func() {
+				request.Source.PrefixRegexp = `builds/(\d+)/`
+				request.Params.File = "output/*"
+				createFile("output/1234/a.tgz")
+				createFile("output/1234/meta/b.json")
+			})
+
+			It("uploads every file in the directory under the prefix", func() {
+				_, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(2))
+				bucketName, objectPath, _, localPath, _, _ := gcsClient.UploadFileArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(objectPath).To(Equal("builds/1234/a.tgz"))
+				Expect(localPath).To(Equal(filepath.Join(sourceDir, "output/1234/a.tgz")))
+
+				_, objectPath, _, localPath, _, _ = gcsClient.UploadFileArgsForCall(1)
+				Expect(objectPath).To(Equal("builds/1234/meta/b.json"))
+				Expect(localPath).To(Equal(filepath.Join(sourceDir, "output/1234/meta/b.json")))
+			})
+
+			It("returns a response", func() {
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(response.Version.Path).To(Equal("builds/1234/"))
+				Expect(response.Metadata[1].Name).To(Equal("url"))
+				Expect(response.Metadata[1].Value).To(Equal("gs://bucket-name/builds/1234/"))
+			})
+
+			It("returns an error if the file is not a directory", func() {
+				request.Params.File = "output/1234/a.tgz"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("please specify a directory when using prefix_regexp"))
+			})
+
+			It("returns an error if the prefix does not match the prefix_regexp", func() {
+				createFile("other/latest/a.tgz")
+				request.Params.File = "other/*"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("object prefix 'builds/latest/' does not match prefix_regexp"))
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(0))
+			})
+
+			It("returns an error if an upload fails", func() {
+				gcsClient.UploadFileReturns(int64(0), nil, errors.New("error uploading file"))
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("error uploading file"))
+			})
+		})
+
 		Describe("with versioned_file", func() {
 			BeforeEach(func() {
 				request.Source.VersionedFile = "folder/version"
@@ -233,7 +412,7 @@ var _ = Describe("Out Command", func() {
 			})
 
 			It("returns a response", func() {
-				gcsClient.UploadFileReturns(int64(12345), nil)
+				gcsClient.UploadFileReturns(int64(12345), &storage.Object{}, nil)
 				gcsClient.URLReturns("gs://bucket-name/folder/file.tgz#12345", nil)
 
 				response, err := command.Run(sourceDir, request)
@@ -256,7 +435,7 @@ var _ = Describe("Out Command", func() {
 			})
 
 			It("returns an error if upload fails", func() {
-				gcsClient.UploadFileReturns(int64(0), errors.New("error uploading file"))
+				gcsClient.UploadFileReturns(int64(0), nil, errors.New("error uploading file"))
 
 				_, err := command.Run(sourceDir, request)
 				Expect(err).To(HaveOccurred())
@@ -337,5 +516,1360 @@ var _ = Describe("Out Command", func() {
 				Expect(objectContentType).To(BeEmpty())
 			})
 		})
+
+		Describe("with preconditions", func() {
+			BeforeEach(func() {
+				request.Source.VersionedFile = "folder/version"
+				createFile("files/file.tgz")
+
+				gcsClient.GetBucketObjectInfoReturns(nil, gcsresource.ErrObjectNotExist)
+			})
+
+			Context("when if_not_exists is specified", func() {
+				BeforeEach(func() {
+					request.Params.IfNotExists = true
+				})
+
+				It("uploads the file only if the object does not exist", func() {
+					gcsClient.UploadFileIfGenerationMatchReturns(int64(12345), &storage.Object{}, nil)
+
+					response, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.UploadFileCallCount()).To(Equal(0))
+					Expect(gcsClient.UploadFileIfGenerationMatchCallCount()).To(Equal(1))
+					bucketName, objectPath, _, localPath, _, _, _, ifGenerationMatch := gcsClient.UploadFileIfGenerationMatchArgsForCall(0)
+					Expect(bucketName).To(Equal("bucket-name"))
+					Expect(objectPath).To(Equal("folder/version"))
+					Expect(localPath).To(Equal(filepath.Join(sourceDir, "files/file.tgz")))
+					Expect(ifGenerationMatch).To(Equal(int64(0)))
+
+					Expect(response.Version.Generation).To(Equal("12345"))
+				})
+
+				It("returns an error if the object already exists", func() {
+					gcsClient.GetBucketObjectInfoReturns(&storage.Object{Name: "folder/version", Generation: 678}, nil)
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("object already exists: folder/version"))
+					Expect(gcsClient.UploadFileIfGenerationMatchCallCount()).To(Equal(0))
+				})
+
+				It("returns an error if the object is created while uploading", func() {
+					gcsClient.UploadFileIfGenerationMatchReturns(int64(0), nil, gcsresource.ErrPreconditionFailed)
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("object already exists: folder/version"))
+				})
+
+				It("returns an error if the object can not be looked up", func() {
+					gcsClient.GetBucketObjectInfoReturns(nil, errors.New("forbidden"))
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("forbidden"))
+					Expect(gcsClient.UploadFileIfGenerationMatchCallCount()).To(Equal(0))
+				})
+
+				It("applies to files uploaded alongside", func() {
+					createFile("files/other.txt")
+					request.Params.Files = []string{"files/other.txt"}
+					gcsClient.UploadFileIfGenerationMatchStub = func(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string, parallelUploadThreshold int, ifGenerationMatch int64) (int64, *storage.Object, error) {
+						if objectPath == "folder/other.txt" {
+							return 0, nil, gcsresource.ErrPreconditionFailed
+						}
+						return 0, &storage.Object{}, nil
+					}
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("failed to upload 'folder/other.txt': object already exists: folder/other.txt"))
+					Expect(gcsClient.UploadFileIfGenerationMatchCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when skip_if_exists is specified", func() {
+				BeforeEach(func() {
+					request.Params.SkipIfExists = true
+					request.Params.ChecksumFiles = []string{"md5"}
+				})
+
+				It("succeeds and emits the existing version if the object already exists", func() {
+					createFile("files/other.txt")
+					request.Params.Files = []string{"files/other.txt"}
+					gcsClient.GetBucketObjectInfoReturns(&storage.Object{Name: "folder/version", Generation: 678}, nil)
+
+					response, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response.Version.Generation).To(Equal("678"))
+					Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "skipped", Value: "true"}))
+
+					_, objectPath := gcsClient.GetBucketObjectInfoArgsForCall(0)
+					Expect(objectPath).To(Equal("folder/version"))
+					Expect(gcsClient.UploadFileIfGenerationMatchCallCount()).To(Equal(0), "does not upload files alongside")
+					Expect(gcsClient.UploadFileCallCount()).To(Equal(0), "does not upload checksum sidecars")
+				})
+
+				It("succeeds if the object is created while uploading", func() {
+					gcsClient.UploadFileIfGenerationMatchReturns(int64(0), nil, gcsresource.ErrPreconditionFailed)
+					gcsClient.GetBucketObjectInfoReturnsOnCall(1, &storage.Object{Name: "folder/version", Generation: 678}, nil)
+
+					response, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response.Version.Generation).To(Equal("678"))
+					Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "skipped", Value: "true"}))
+				})
+
+				It("uploads the file if the object does not exist", func() {
+					gcsClient.UploadFileIfGenerationMatchReturns(int64(12345), &storage.Object{}, nil)
+
+					response, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response.Version.Generation).To(Equal("12345"))
+					Expect(response.Metadata).NotTo(ContainElement(gcsresource.MetadataPair{Name: "skipped", Value: "true"}))
+					Expect(gcsClient.UploadFileCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when if_generation_match is specified", func() {
+				BeforeEach(func() {
+					request.Params.IfGenerationMatch = "version/generation"
+
+					createFile("version/generation")
+					err := ioutil.WriteFile(filepath.Join(sourceDir, "version/generation"), []byte("12345\n"), 0644)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("uploads the file only if the object has the generation from the file", func() {
+					gcsClient.GetBucketObjectInfoReturns(&storage.Object{Name: "folder/version", Generation: 12345}, nil)
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					_, _, _, _, _, _, _, ifGenerationMatch := gcsClient.UploadFileIfGenerationMatchArgsForCall(0)
+					Expect(ifGenerationMatch).To(Equal(int64(12345)))
+				})
+
+				It("returns an error if the object has another generation", func() {
+					gcsClient.GetBucketObjectInfoReturns(&storage.Object{Name: "folder/version", Generation: 678}, nil)
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("object 'folder/version' does not have generation 12345"))
+					Expect(gcsClient.UploadFileIfGenerationMatchCallCount()).To(Equal(0))
+				})
+
+				It("returns an error if the object does not exist", func() {
+					_, err := command.Run(sourceDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("object 'folder/version' does not have generation 12345"))
+				})
+
+				It("does not upload files alongside if the object has another generation", func() {
+					createFile("files/other.txt")
+					request.Params.Files = []string{"files/other.txt"}
+					gcsClient.GetBucketObjectInfoReturns(&storage.Object{Name: "folder/version", Generation: 678}, nil)
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(gcsClient.UploadFileCallCount()).To(Equal(0))
+					Expect(gcsClient.UploadFileIfGenerationMatchCallCount()).To(Equal(0))
+				})
+
+				It("returns an error if the generation changes while uploading", func() {
+					gcsClient.GetBucketObjectInfoReturns(&storage.Object{Name: "folder/version", Generation: 12345}, nil)
+					gcsClient.UploadFileIfGenerationMatchReturns(int64(0), nil, gcsresource.ErrPreconditionFailed)
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("object 'folder/version' does not have generation 12345"))
+				})
+
+				It("returns an error if the file does not hold a generation", func() {
+					err := ioutil.WriteFile(filepath.Join(sourceDir, "version/generation"), []byte("latest"), 0644)
+					Expect(err).ToNot(HaveOccurred())
+
+					_, err = command.Run(sourceDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("invalid generation in if_generation_match file 'version/generation': latest"))
+					Expect(gcsClient.UploadFileIfGenerationMatchCallCount()).To(Equal(0))
+				})
+
+				It("returns an error with if_not_exists", func() {
+					request.Params.IfNotExists = true
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("please specify either if_not_exists or if_generation_match"))
+				})
+			})
+		})
+
+		Describe("with copy_from", func() {
+			BeforeEach(func() {
+				request.Source.Bucket = "release-bucket"
+				request.Source.Regexp = "releases/app-(.*).tgz"
+				request.Params.File = ""
+				request.Params.CopyFrom = &CopyFrom{
+					Bucket: "staging-bucket",
+					Path:   "builds/app-1.3.0.tgz",
+				}
+
+				gcsClient.URLStub = func(bucketName string, objectPath string, generation int64) (string, error) {
+					return fmt.Sprintf("gs://%s/%s", bucketName, objectPath), nil
+				}
+			})
+
+			It("copies the object instead of uploading a file", func() {
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(0))
+				Expect(gcsClient.CopyObjectCallCount()).To(Equal(1))
+				sourceBucketName, sourceObjectPath, sourceGeneration, bucketName, objectPath, predefinedACL := gcsClient.CopyObjectArgsForCall(0)
+				Expect(sourceBucketName).To(Equal("staging-bucket"))
+				Expect(sourceObjectPath).To(Equal("builds/app-1.3.0.tgz"))
+				Expect(sourceGeneration).To(Equal(int64(0)))
+				Expect(bucketName).To(Equal("release-bucket"))
+				Expect(objectPath).To(Equal("releases/app-1.3.0.tgz"))
+				Expect(predefinedACL).To(BeEmpty())
+
+				Expect(response.Version.Path).To(Equal("releases/app-1.3.0.tgz"))
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{
+					Name:  "copied_from",
+					Value: "gs://staging-bucket/builds/app-1.3.0.tgz",
+				}))
+			})
+
+			It("copies the given generation", func() {
+				request.Params.CopyFrom.Generation = "12345"
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, _, sourceGeneration, _, _, _ := gcsClient.CopyObjectArgsForCall(0)
+				Expect(sourceGeneration).To(Equal(int64(12345)))
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{
+					Name:  "copied_from",
+					Value: "gs://staging-bucket/builds/app-1.3.0.tgz#12345",
+				}))
+			})
+
+			It("copies the object from the url file of a get", func() {
+				createFile("staging/url")
+				err := ioutil.WriteFile(filepath.Join(sourceDir, "staging/url"), []byte("gs://staging-bucket/builds/app-1.4.0.tgz#67890"), 0644)
+				Expect(err).ToNot(HaveOccurred())
+				request.Params.CopyFrom = &CopyFrom{URLFile: "staging/url"}
+
+				_, err = command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				sourceBucketName, sourceObjectPath, sourceGeneration, _, objectPath, _ := gcsClient.CopyObjectArgsForCall(0)
+				Expect(sourceBucketName).To(Equal("staging-bucket"))
+				Expect(sourceObjectPath).To(Equal("builds/app-1.4.0.tgz"))
+				Expect(sourceGeneration).To(Equal(int64(67890)))
+				Expect(objectPath).To(Equal("releases/app-1.4.0.tgz"))
+			})
+
+			It("returns an error if the url file does not hold an object url", func() {
+				createFile("staging/url")
+				err := ioutil.WriteFile(filepath.Join(sourceDir, "staging/url"), []byte("https://example.com/app.tgz"), 0644)
+				Expect(err).ToNot(HaveOccurred())
+				request.Params.CopyFrom = &CopyFrom{URLFile: "staging/url"}
+
+				_, err = command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid object url: https://example.com/app.tgz"))
+				Expect(gcsClient.CopyObjectCallCount()).To(Equal(0))
+			})
+
+			It("emits the generation of the copy with versioned_file", func() {
+				request.Source.Regexp = ""
+				request.Source.VersionedFile = "releases/app.tgz"
+				gcsClient.CopyObjectReturns(int64(54321), &storage.Object{}, nil)
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, _, _, _, objectPath, _ := gcsClient.CopyObjectArgsForCall(0)
+				Expect(objectPath).To(Equal("releases/app.tgz"))
+				Expect(response.Version.Generation).To(Equal("54321"))
+			})
+
+			It("reports the copy from the rewrite response in the metadata", func() {
+				gcsClient.CopyObjectReturns(int64(54321), &storage.Object{Bucket: "bucket-name", Name: "folder/file-1.3.tgz", ContentType: "application/gzip"}, nil)
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.ObjectInfoCallCount()).To(Equal(0))
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "content_type", Value: "application/gzip"}))
+			})
+
+			It("returns an error if the copy fails", func() {
+				gcsClient.CopyObjectReturns(int64(0), nil, errors.New("error copying"))
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("error copying"))
+			})
+
+			It("returns an error with file", func() {
+				request.Params.File = "files/file*.tgz"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("please specify only one of file, copy_from or delete"))
+			})
+
+			It("returns an error without a source", func() {
+				request.Params.CopyFrom = &CopyFrom{Bucket: "staging-bucket"}
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("please specify either copy_from.url_file or copy_from.bucket and copy_from.path"))
+			})
+
+			It("returns an error for an invalid generation", func() {
+				request.Params.CopyFrom.Generation = "latest"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid copy_from.generation value specified: latest"))
+			})
+
+			It("returns an error with prefix_regexp", func() {
+				request.Source.Regexp = ""
+				request.Source.PrefixRegexp = "releases/app-(.*)/"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("copy_from is not supported with prefix_regexp"))
+			})
+		})
+
+		Describe("with delete", func() {
+			BeforeEach(func() {
+				request.Source.Regexp = "releases/app-(.*).tgz"
+				request.Params.File = ""
+				request.Params.Delete = &Delete{
+					Path:       "releases/app-1.2.0.tgz",
+					Generation: "12345",
+				}
+			})
+
+			It("deletes the object instead of uploading a file", func() {
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(0))
+				Expect(gcsClient.DeleteObjectCallCount()).To(Equal(1))
+				bucketName, objectPath, generation := gcsClient.DeleteObjectArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(objectPath).To(Equal("releases/app-1.2.0.tgz"))
+				Expect(generation).To(Equal(int64(12345)))
+
+				Expect(response.Version.Path).To(Equal("releases/app-1.2.0.tgz"))
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{
+					Name:  "deleted",
+					Value: "gs://bucket-name/releases/app-1.2.0.tgz#12345",
+				}))
+			})
+
+			It("deletes the live generation of the versioned file", func() {
+				request.Source.Regexp = ""
+				request.Source.VersionedFile = "releases/app.tgz"
+				request.Params.Delete = &Delete{}
+				gcsClient.GetBucketObjectInfoReturns(&storage.Object{Generation: 67890}, nil)
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, objectPath, generation := gcsClient.DeleteObjectArgsForCall(0)
+				Expect(objectPath).To(Equal("releases/app.tgz"))
+				Expect(generation).To(Equal(int64(67890)))
+				Expect(response.Version.Generation).To(Equal("67890"))
+			})
+
+			It("only lists the object with dry_run", func() {
+				request.Params.DryRun = true
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.DeleteObjectCallCount()).To(Equal(0))
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{
+					Name:  "would_delete",
+					Value: "gs://bucket-name/releases/app-1.2.0.tgz#12345",
+				}))
+			})
+
+			It("returns an error if the delete fails", func() {
+				gcsClient.DeleteObjectReturns(errors.New("error deleting"))
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("failed to delete 'gs://bucket-name/releases/app-1.2.0.tgz#12345': error deleting"))
+			})
+
+			It("returns an error if the path does not match the regexp", func() {
+				request.Params.Delete.Path = "other/app-1.2.0.tgz"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("object 'other/app-1.2.0.tgz' does not match regexp"))
+				Expect(gcsClient.DeleteObjectCallCount()).To(Equal(0))
+			})
+
+			It("returns an error without a path", func() {
+				request.Params.Delete.Path = ""
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("please specify delete.path when using regexp"))
+			})
+
+			It("returns an error for an invalid generation", func() {
+				request.Params.Delete.Generation = "latest"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid delete.generation value specified: latest"))
+			})
+		})
+
+		Describe("with a retention policy", func() {
+			var now time.Time
+
+			BeforeEach(func() {
+				now = time.Now()
+				createFile("files/app-4.0.0.tgz")
+				request.Params.File = "files/app-*.tgz"
+			})
+
+			Context("with regexp", func() {
+				BeforeEach(func() {
+					request.Source.Regexp = "releases/app-(.*).tgz"
+
+					gcsClient.BucketObjectsInfoReturns([]*storage.Object{
+						{Name: "releases/app-2.0.0.tgz", Generation: 2, TimeCreated: now.Add(-48 * time.Hour).Format(time.RFC3339Nano)},
+						{Name: "releases/app-1.0.0.tgz", Generation: 1, TimeCreated: now.Add(-72 * time.Hour).Format(time.RFC3339Nano)},
+						{Name: "releases/app-4.0.0.tgz", Generation: 4, TimeCreated: now.Format(time.RFC3339Nano)},
+						{Name: "releases/app-3.0.0.tgz", Generation: 3, TimeCreated: now.Add(-1 * time.Hour).Format(time.RFC3339Nano)},
+					}, nil)
+				})
+
+				It("deletes the objects beyond keep_latest after the upload", func() {
+					request.Params.KeepLatest = 2
+
+					response, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.UploadFileCallCount()).To(Equal(1))
+					Expect(gcsClient.DeleteObjectCallCount()).To(Equal(2))
+					_, objectPath, generation := gcsClient.DeleteObjectArgsForCall(0)
+					Expect(objectPath).To(Equal("releases/app-1.0.0.tgz"))
+					Expect(generation).To(Equal(int64(1)))
+					_, objectPath, generation = gcsClient.DeleteObjectArgsForCall(1)
+					Expect(objectPath).To(Equal("releases/app-2.0.0.tgz"))
+					Expect(generation).To(Equal(int64(2)))
+
+					Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{
+						Name:  "deleted",
+						Value: "gs://bucket-name/releases/app-1.0.0.tgz#1",
+					}))
+				})
+
+				It("deletes the objects older than older_than", func() {
+					request.Params.OlderThan = "1d"
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.DeleteObjectCallCount()).To(Equal(2))
+					_, objectPath, _ := gcsClient.DeleteObjectArgsForCall(0)
+					Expect(objectPath).To(Equal("releases/app-1.0.0.tgz"))
+					_, objectPath, _ = gcsClient.DeleteObjectArgsForCall(1)
+					Expect(objectPath).To(Equal("releases/app-2.0.0.tgz"))
+				})
+
+				It("applies both keep_latest and older_than", func() {
+					request.Params.KeepLatest = 3
+					request.Params.OlderThan = "24h"
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.DeleteObjectCallCount()).To(Equal(1))
+					_, objectPath, _ := gcsClient.DeleteObjectArgsForCall(0)
+					Expect(objectPath).To(Equal("releases/app-1.0.0.tgz"))
+				})
+
+				It("keeps the uploaded object", func() {
+					request.Params.File = ""
+					request.Params.CopyFrom = &CopyFrom{Bucket: "staging-bucket", Path: "builds/app-1.0.0.tgz"}
+					request.Params.KeepLatest = 1
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.DeleteObjectCallCount()).To(Equal(2))
+					_, objectPath, _ := gcsClient.DeleteObjectArgsForCall(0)
+					Expect(objectPath).To(Equal("releases/app-2.0.0.tgz"))
+					_, objectPath, _ = gcsClient.DeleteObjectArgsForCall(1)
+					Expect(objectPath).To(Equal("releases/app-3.0.0.tgz"))
+				})
+
+				It("only lists the objects with dry_run", func() {
+					request.Params.KeepLatest = 3
+					request.Params.DryRun = true
+
+					response, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.DeleteObjectCallCount()).To(Equal(0))
+					Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{
+						Name:  "would_delete",
+						Value: "gs://bucket-name/releases/app-1.0.0.tgz#1",
+					}))
+				})
+			})
+
+			Context("with versioned_file", func() {
+				BeforeEach(func() {
+					request.Source.VersionedFile = "releases/app.tgz"
+					gcsClient.UploadFileReturns(int64(4), &storage.Object{}, nil)
+
+					gcsClient.ObjectGenerationsInfoReturns([]*storage.Object{
+						{Name: "releases/app.tgz", Generation: 4, TimeCreated: now.Format(time.RFC3339Nano)},
+						{Name: "releases/app.tgz", Generation: 1, TimeCreated: now.Add(-72 * time.Hour).Format(time.RFC3339Nano), TimeDeleted: now.Add(-48 * time.Hour).Format(time.RFC3339Nano)},
+						{Name: "releases/app.tgz", Generation: 3, TimeCreated: now.Add(-1 * time.Hour).Format(time.RFC3339Nano), TimeDeleted: now.Format(time.RFC3339Nano)},
+						{Name: "releases/app.tgz", Generation: 2, TimeCreated: now.Add(-48 * time.Hour).Format(time.RFC3339Nano), TimeDeleted: now.Add(-1 * time.Hour).Format(time.RFC3339Nano)},
+					}, nil)
+				})
+
+				It("deletes the noncurrent generations beyond keep_latest", func() {
+					request.Params.KeepLatest = 2
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.DeleteObjectCallCount()).To(Equal(2))
+					_, objectPath, generation := gcsClient.DeleteObjectArgsForCall(0)
+					Expect(objectPath).To(Equal("releases/app.tgz"))
+					Expect(generation).To(Equal(int64(1)))
+					_, _, generation = gcsClient.DeleteObjectArgsForCall(1)
+					Expect(generation).To(Equal(int64(2)))
+				})
+
+				It("never deletes the live generation", func() {
+					request.Params.OlderThan = "1ns"
+
+					_, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.DeleteObjectCallCount()).To(Equal(3))
+					for i := 0; i < 3; i++ {
+						_, _, generation := gcsClient.DeleteObjectArgsForCall(i)
+						Expect(generation).ToNot(Equal(int64(4)))
+					}
+				})
+			})
+
+			It("returns an error for an invalid older_than", func() {
+				request.Source.Regexp = "releases/app-(.*).tgz"
+				request.Params.OlderThan = "a month"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid older_than value specified: a month"))
+			})
+
+			It("returns an error for a negative keep_latest", func() {
+				request.Source.Regexp = "releases/app-(.*).tgz"
+				request.Params.KeepLatest = -1
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid keep_latest value specified: -1"))
+			})
+
+			It("returns an error for dry_run without anything to delete", func() {
+				request.Source.Regexp = "releases/app-(.*).tgz"
+				request.Params.DryRun = true
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("please specify delete, keep_latest or older_than when using dry_run"))
+			})
+
+			It("returns an error with prefix_regexp", func() {
+				request.Source.PrefixRegexp = "releases/app-(.*)/"
+				request.Params.KeepLatest = 2
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("delete, keep_latest and older_than are not supported with prefix_regexp"))
+			})
+		})
+
+		Describe("with latest_alias and latest_pointer", func() {
+			var pointers [][]byte
+
+			BeforeEach(func() {
+				request.Source.Regexp = `releases/app-(\d+\.\d+\.\d+).tgz`
+				request.Params.File = "files/app-*.tgz"
+				createFile("files/app-1.3.0.tgz")
+
+				pointers = nil
+				gcsClient.UploadFileStub = func(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string) (int64, *storage.Object, error) {
+					if objectPath == "releases/latest.json" {
+						pointer, err := ioutil.ReadFile(localPath)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(objectContentType).To(Equal("application/json"))
+						pointers = append(pointers, pointer)
+						return 67890, &storage.Object{}, nil
+					}
+					return 12345, &storage.Object{}, nil
+				}
+			})
+
+			It("copies the uploaded object to latest_alias", func() {
+				request.Params.LatestAlias = "releases/app-latest.tgz"
+				gcsClient.CopyObjectReturns(int64(54321), &storage.Object{}, nil)
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.CopyObjectCallCount()).To(Equal(1))
+				sourceBucketName, sourceObjectPath, sourceGeneration, bucketName, objectPath, _ := gcsClient.CopyObjectArgsForCall(0)
+				Expect(sourceBucketName).To(Equal("bucket-name"))
+				Expect(sourceObjectPath).To(Equal("releases/app-1.3.0.tgz"))
+				Expect(sourceGeneration).To(Equal(int64(12345)))
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(objectPath).To(Equal("releases/app-latest.tgz"))
+
+				Expect(response.Version.Path).To(Equal("releases/app-1.3.0.tgz"))
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{
+					Name:  "latest_alias",
+					Value: "gs://bucket-name/releases/app-latest.tgz#54321",
+				}))
+			})
+
+			It("writes latest_pointer with the path, generation and checksums", func() {
+				request.Params.LatestPointer = "releases/latest.json"
+				request.Params.ChecksumFiles = []string{"sha256"}
+				gcsClient.GetBucketObjectInfoReturns(&storage.Object{
+					Md5Hash: "1B2M2Y8AsgTpgAmY7PhCfg==",
+					Crc32c:  "AAAAAA==",
+				}, nil)
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(pointers).To(HaveLen(1))
+				Expect(pointers[0]).To(MatchJSON(`{
+					"path": "releases/app-1.3.0.tgz",
+					"generation": "12345",
+					"url": "gs://bucket-name/releases/app-1.3.0.tgz#12345",
+					"checksums": {
+						"md5": "d41d8cd98f00b204e9800998ecf8427e",
+						"crc32c": "00000000",
+						"sha256": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+					}
+				}`))
+
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{
+					Name:  "latest_pointer",
+					Value: "gs://bucket-name/releases/latest.json#67890",
+				}))
+			})
+
+			It("does not update them if the upload fails", func() {
+				request.Params.LatestAlias = "releases/app-latest.tgz"
+				request.Params.LatestPointer = "releases/latest.json"
+				gcsClient.UploadFileStub = nil
+				gcsClient.UploadFileReturns(int64(0), nil, errors.New("error uploading"))
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(gcsClient.CopyObjectCallCount()).To(Equal(0))
+				Expect(pointers).To(BeEmpty())
+			})
+
+			It("returns an error if latest_alias matches the regexp", func() {
+				request.Source.Regexp = "releases/app-(.*).tgz"
+				request.Params.LatestAlias = "releases/app-latest.tgz"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("latest_alias 'releases/app-latest.tgz' must not match regexp"))
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(0))
+			})
+
+			It("returns an error if latest_pointer is the versioned_file", func() {
+				request.Source.Regexp = ""
+				request.Source.VersionedFile = "releases/latest.json"
+				request.Params.LatestPointer = "releases/latest.json"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("latest_pointer 'releases/latest.json' must not be the versioned_file"))
+			})
+
+			It("returns an error with prefix_regexp", func() {
+				request.Source.Regexp = ""
+				request.Source.PrefixRegexp = "releases/app-(.*)/"
+				request.Params.LatestAlias = "releases/app-latest/"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("latest_alias and latest_pointer are not supported with prefix_regexp"))
+			})
+		})
+
+		Describe("with holds", func() {
+			var placed, released bool
+
+			BeforeEach(func() {
+				placed, released = true, false
+				request.Source.VersionedFile = "releases/app.tgz"
+				createFile("files/file.tgz")
+				gcsClient.UploadFileReturns(int64(12345), &storage.Object{}, nil)
+				gcsClient.SetObjectHoldsReturns(&storage.Object{
+					EventBasedHold:          true,
+					RetentionExpirationTime: "2027-01-01T00:00:00Z",
+				}, nil)
+			})
+
+			It("places the holds on the uploaded object", func() {
+				request.Params.EventBasedHold = &placed
+				request.Params.TemporaryHold = &released
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.SetObjectHoldsCallCount()).To(Equal(1))
+				bucketName, objectPath, generation, holds := gcsClient.SetObjectHoldsArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(objectPath).To(Equal("releases/app.tgz"))
+				Expect(generation).To(Equal(int64(12345)))
+				Expect(*holds.EventBasedHold).To(BeTrue())
+				Expect(*holds.TemporaryHold).To(BeFalse())
+
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "event_based_hold", Value: "true"}))
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "retention_expiration_time", Value: "2027-01-01T00:00:00Z"}))
+			})
+
+			It("leaves holds that are not specified", func() {
+				request.Params.TemporaryHold = &placed
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, _, _, holds := gcsClient.SetObjectHoldsArgsForCall(0)
+				Expect(*holds.TemporaryHold).To(BeTrue())
+				Expect(holds.EventBasedHold).To(BeNil())
+			})
+
+			It("does not touch the holds without the params", func() {
+				_, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.SetObjectHoldsCallCount()).To(Equal(0))
+			})
+
+			It("returns an error if the holds can not be set", func() {
+				request.Params.TemporaryHold = &placed
+				gcsClient.SetObjectHoldsReturns(nil, errors.New("error patching"))
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("failed to set holds on 'releases/app.tgz': error patching"))
+			})
+
+			It("returns an error with prefix_regexp", func() {
+				request.Source.VersionedFile = ""
+				request.Source.PrefixRegexp = "releases/app-(.*)/"
+				request.Params.TemporaryHold = &placed
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("temporary_hold and event_based_hold are not supported with prefix_regexp"))
+			})
+		})
+
+		Describe("with retention", func() {
+			BeforeEach(func() {
+				request.Source.VersionedFile = "releases/app.tgz"
+				request.Params.Retention = &Retention{Mode: "Locked", RetainUntil: "2027-01-01T00:00:00Z"}
+				createFile("files/file.tgz")
+				gcsClient.UploadFileReturns(int64(12345), &storage.Object{}, nil)
+				gcsClient.SetObjectRetentionReturns(gcsresource.ObjectRetention{
+					Mode:            "Locked",
+					RetainUntilTime: "2027-01-01T00:00:00Z",
+				}, nil)
+			})
+
+			It("sets the retention on the uploaded object", func() {
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.SetObjectRetentionCallCount()).To(Equal(1))
+				bucketName, objectPath, generation, retention := gcsClient.SetObjectRetentionArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(objectPath).To(Equal("releases/app.tgz"))
+				Expect(generation).To(Equal(int64(12345)))
+				Expect(retention).To(Equal(gcsresource.ObjectRetention{Mode: "Locked", RetainUntilTime: "2027-01-01T00:00:00Z"}))
+
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "retention_mode", Value: "Locked"}))
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{Name: "retain_until_time", Value: "2027-01-01T00:00:00Z"}))
+			})
+
+			It("retains an unlocked object for retain_for from now", func() {
+				request.Params.Retention = &Retention{RetainFor: "30d"}
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, _, _, retention := gcsClient.SetObjectRetentionArgsForCall(0)
+				Expect(retention.Mode).To(Equal("Unlocked"))
+
+				retainUntil, err := time.Parse(time.RFC3339, retention.RetainUntilTime)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(retainUntil).To(BeTemporally("~", time.Now().Add(30*24*time.Hour), time.Minute))
+			})
+
+			It("sets the retention on the copied object", func() {
+				request.Params.File = ""
+				request.Params.CopyFrom = &CopyFrom{Bucket: "other-bucket", Path: "releases/app.tgz"}
+				gcsClient.CopyObjectReturns(int64(54321), &storage.Object{}, nil)
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, objectPath, generation, _ := gcsClient.SetObjectRetentionArgsForCall(0)
+				Expect(objectPath).To(Equal("releases/app.tgz"))
+				Expect(generation).To(Equal(int64(54321)))
+			})
+
+			It("returns an error if the retention can not be set", func() {
+				gcsClient.SetObjectRetentionReturns(gcsresource.ObjectRetention{}, errors.New("error patching"))
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("failed to set retention on 'releases/app.tgz': error patching"))
+			})
+
+			It("returns an error without uploading if the mode is invalid", func() {
+				request.Params.Retention.Mode = "Governance"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid retention.mode value specified: Governance"))
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(0))
+			})
+
+			It("returns an error if both retain_until and retain_for are specified", func() {
+				request.Params.Retention.RetainFor = "30d"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("please specify either retention.retain_until or retention.retain_for"))
+			})
+
+			It("returns an error if retain_until is invalid", func() {
+				request.Params.Retention.RetainUntil = "2027-01-01"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid retention.retain_until value specified: 2027-01-01"))
+			})
+
+			It("returns an error with prefix_regexp", func() {
+				request.Source.VersionedFile = ""
+				request.Source.PrefixRegexp = "releases/app-(.*)/"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("retention is not supported with prefix_regexp"))
+			})
+		})
+
+		Describe("with signed_url_ttl", func() {
+			BeforeEach(func() {
+				request.Source.VersionedFile = "releases/app.tgz"
+				request.Params.SignedURLTTL = "30m"
+				createFile("files/file.tgz")
+				gcsClient.UploadFileReturns(int64(12345), &storage.Object{}, nil)
+				gcsClient.SignedURLReturns("https://storage.googleapis.com/bucket-name/releases/app.tgz?generation=12345&X-Goog-Signature=abc", nil)
+			})
+
+			It("emits a signed url of the uploaded object", func() {
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				bucketName, objectPath, generation, ttl := gcsClient.SignedURLArgsForCall(0)
+				Expect(bucketName).To(Equal("bucket-name"))
+				Expect(objectPath).To(Equal("releases/app.tgz"))
+				Expect(generation).To(Equal(int64(12345)))
+				Expect(ttl).To(Equal(30 * time.Minute))
+
+				Expect(response.Metadata).To(ContainElement(gcsresource.MetadataPair{
+					Name:  "signed_url",
+					Value: "https://storage.googleapis.com/bucket-name/releases/app.tgz?generation=12345&X-Goog-Signature=abc",
+				}))
+			})
+
+			It("returns an error if the url can not be signed", func() {
+				gcsClient.SignedURLReturns("", errors.New("failed to sign url: no private key"))
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("failed to sign url: no private key"))
+			})
+
+			It("returns an error for an invalid signed_url_ttl", func() {
+				request.Params.SignedURLTTL = "-1h"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid signed_url_ttl value specified: -1h"))
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(0))
+			})
+		})
+
+		Describe("with the object resource", func() {
+			BeforeEach(func() {
+				request.Source.VersionedFile = "releases/app.tgz"
+				createFile("files/file.tgz")
+				gcsClient.UploadFileReturns(int64(12345), &storage.Object{}, nil)
+				gcsClient.URLReturns("gs://bucket-name/releases/app.tgz#12345", nil)
+			})
+
+			It("reports it in the metadata from the upload response", func() {
+				gcsClient.UploadFileReturns(int64(12345), &storage.Object{
+					Bucket:       "bucket-name",
+					Name:         "releases/app.tgz",
+					MediaLink:    "https://storage.googleapis.com/download/storage/v1/b/bucket-name/o/releases%2Fapp.tgz?generation=12345&alt=media",
+					Size:         512,
+					ContentType:  "application/gzip",
+					StorageClass: "NEARLINE",
+					Updated:      "2026-10-01T12:00:00.000Z",
+				}, nil)
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.ObjectInfoCallCount()).To(Equal(0))
+
+				Expect(response.Metadata).To(Equal([]gcsresource.MetadataPair{
+					{Name: "filename", Value: "app.tgz"},
+					{Name: "url", Value: "gs://bucket-name/releases/app.tgz#12345"},
+					{Name: "media_link", Value: "https://storage.googleapis.com/download/storage/v1/b/bucket-name/o/releases%2Fapp.tgz?generation=12345&alt=media"},
+					{Name: "console_url", Value: "https://console.cloud.google.com/storage/browser/_details/bucket-name/releases/app.tgz"},
+					{Name: "size", Value: "512 B"},
+					{Name: "content_type", Value: "application/gzip"},
+					{Name: "storage_class", Value: "NEARLINE"},
+					{Name: "updated", Value: "2026-10-01T12:00:00.000Z"},
+				}))
+			})
+		})
+
+		Describe("with object_name", func() {
+			BeforeEach(func() {
+				request.Source.Regexp = "releases/app-(.*).tgz"
+				request.Params.File = "build/app.tgz"
+				createFile("build/app.tgz")
+
+				err := ioutil.WriteFile(filepath.Join(sourceDir, "version"), []byte("1.3.0\n"), 0644)
+				Expect(err).ToNot(HaveOccurred())
+
+				os.Setenv("BUILD_ID", "42")
+			})
+
+			AfterEach(func() {
+				os.Unsetenv("BUILD_ID")
+			})
+
+			It("uploads the file to the rendered object name", func() {
+				request.Params.ObjectName = "releases/app-{{.Version}}+{{.Env.BUILD_ID}}.tgz"
+				request.Params.VersionFile = "version"
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, objectPath, _, localPath, _, _ := gcsClient.UploadFileArgsForCall(0)
+				Expect(objectPath).To(Equal("releases/app-1.3.0+42.tgz"))
+				Expect(localPath).To(Equal(filepath.Join(sourceDir, "build/app.tgz")))
+				Expect(response.Version.Path).To(Equal("releases/app-1.3.0+42.tgz"))
+			})
+
+			It("renders a timestamp", func() {
+				request.Params.ObjectName = "releases/app-{{.Timestamp}}.tgz"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, objectPath, _, _, _, _ := gcsClient.UploadFileArgsForCall(0)
+				Expect(objectPath).To(MatchRegexp(`^releases/app-\d{14}\.tgz$`))
+			})
+
+			It("returns an error if the rendered name does not match the regexp", func() {
+				request.Params.ObjectName = "other/{{.Name}}"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("object name 'other/app.tgz' does not match regexp"))
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(0))
+			})
+
+			It("returns an error for an unset environment variable", func() {
+				request.Params.ObjectName = "releases/app-{{.Env.UNSET_VARIABLE}}.tgz"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("failed to render object_name"))
+			})
+
+			It("returns an error for an invalid template", func() {
+				request.Params.ObjectName = "releases/app-{{.Version"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid object_name template specified: releases/app-{{.Version"))
+			})
+
+			It("returns an error if version_file is specified without object_name", func() {
+				request.Params.VersionFile = "version"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("please specify object_name when using version_file"))
+			})
+
+			It("returns an error without regexp", func() {
+				request.Source.Regexp = ""
+				request.Source.VersionedFile = "releases/app.tgz"
+				request.Params.ObjectName = "releases/{{.Name}}"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("please specify regexp when using object_name"))
+			})
+		})
+
+		Describe("with files and directory", func() {
+			var (
+				uploadsMutex sync.Mutex
+				uploads      []string
+			)
+
+			BeforeEach(func() {
+				request.Source.Regexp = "folder/app-(.*)-linux.tgz"
+				request.Params.File = "build/app-*-linux.tgz"
+
+				createFile("build/app-1.3-linux.tgz")
+				createFile("build/app-1.3-darwin.tgz")
+				createFile("build/app-1.3-windows.zip")
+				createFile("docs/index.html")
+				createFile("docs/api/index.html")
+
+				uploads = nil
+				gcsClient.UploadFileStub = func(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string) (int64, *storage.Object, error) {
+					uploadsMutex.Lock()
+					defer uploadsMutex.Unlock()
+
+					uploads = append(uploads, objectPath)
+					return 0, &storage.Object{}, nil
+				}
+			})
+
+			It("uploads every match next to the primary object, which is uploaded last", func() {
+				request.Params.Files = []string{"build/*"}
+				request.Params.Directory = "docs"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(uploads).To(HaveLen(5))
+				Expect(uploads[:4]).To(ConsistOf(
+					"folder/app-1.3-darwin.tgz",
+					"folder/app-1.3-windows.zip",
+					"folder/index.html",
+					"folder/api/index.html",
+				))
+				Expect(uploads[4]).To(Equal("folder/app-1.3-linux.tgz"))
+			})
+
+			It("emits the primary file as the version and reports every uploaded path", func() {
+				request.Params.Files = []string{"build/*.tgz", "build/*.zip"}
+
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(response.Version.Path).To(Equal("folder/app-1.3-linux.tgz"))
+				Expect(response.Metadata[3:]).To(Equal([]gcsresource.MetadataPair{
+					{Name: "uploaded", Value: "folder/app-1.3-darwin.tgz"},
+					{Name: "uploaded", Value: "folder/app-1.3-windows.zip"},
+				}))
+			})
+
+			It("returns an error without uploading if two files would be uploaded to the same object", func() {
+				createFile("docs/app-1.3-darwin.tgz")
+				request.Params.Files = []string{"build/*"}
+				request.Params.Directory = "docs"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("would both be uploaded to 'folder/app-1.3-darwin.tgz'"))
+				Expect(uploads).To(BeEmpty())
+			})
+
+			It("returns an error if a pattern has no matches", func() {
+				request.Params.Files = []string{"build/*.rpm"}
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("no matches found for pattern: build/*.rpm"))
+			})
+
+			It("returns an error and does not upload the primary file if an upload fails", func() {
+				request.Params.Files = []string{"build/*"}
+				gcsClient.UploadFileStub = func(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string) (int64, *storage.Object, error) {
+					if objectPath == "folder/app-1.3-windows.zip" {
+						return 0, nil, errors.New("error uploading file")
+					}
+					return 0, &storage.Object{}, nil
+				}
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("failed to upload 'folder/app-1.3-windows.zip': error uploading file"))
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(2))
+			})
+		})
+
+		Describe("with checksum_files", func() {
+			var uploads map[string]string
+
+			BeforeEach(func() {
+				request.Source.Regexp = "folder/file-(.*).tgz"
+				request.Params.File = "file-1.3.tgz"
+				request.Params.ChecksumFiles = []string{"sha512", "md5"}
+
+				err := ioutil.WriteFile(filepath.Join(sourceDir, "file-1.3.tgz"), []byte("file-content"), 0644)
+				Expect(err).ToNot(HaveOccurred())
+
+				uploads = map[string]string{}
+				gcsClient.UploadFileStub = func(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string) (int64, *storage.Object, error) {
+					uploaded, err := ioutil.ReadFile(localPath)
+					Expect(err).ToNot(HaveOccurred())
+					uploads[objectPath] = string(uploaded)
+
+					return 0, &storage.Object{}, nil
+				}
+			})
+
+			It("uploads a sidecar per algorithm next to the object", func() {
+				_, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(3))
+				Expect(uploads).To(HaveKeyWithValue("folder/file-1.3.tgz.md5", "e9013fc202c87be48e3b302df10efc4b  file-1.3.tgz\n"))
+				Expect(uploads).To(HaveKeyWithValue("folder/file-1.3.tgz.sha512", fmt.Sprintf("%x  file-1.3.tgz\n", sha512.Sum512([]byte("file-content")))))
+			})
+
+			It("uploads the sidecars before the object", func() {
+				_, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(3))
+				_, objectPath, _, _, _, _ := gcsClient.UploadFileArgsForCall(2)
+				Expect(objectPath).To(Equal("folder/file-1.3.tgz"))
+			})
+
+			It("does not upload the object if a sidecar can not be uploaded", func() {
+				gcsClient.UploadFileReturns(0, nil, errors.New("forbidden"))
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(1))
+				_, objectPath, _, _, _, _ := gcsClient.UploadFileArgsForCall(0)
+				Expect(objectPath).To(Equal("folder/file-1.3.tgz.md5"))
+			})
+
+			It("emits the digests as metadata", func() {
+				response, err := command.Run(sourceDir, request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(response.Metadata[3:]).To(Equal([]gcsresource.MetadataPair{
+					{Name: "md5", Value: "e9013fc202c87be48e3b302df10efc4b"},
+					{Name: "sha512", Value: fmt.Sprintf("%x", sha512.Sum512([]byte("file-content")))},
+				}))
+			})
+
+			It("returns an error for an unsupported algorithm", func() {
+				request.Params.ChecksumFiles = []string{"crc32"}
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid checksum_files value specified: crc32"))
+			})
+		})
+
+		Describe("with signing_key", func() {
+			var (
+				content    []byte
+				signatures map[string][]byte
+			)
+
+			pemKey := func(blockType string, der []byte) string {
+				return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+			}
+
+			BeforeEach(func() {
+				request.Source.Regexp = "folder/file-(.*).tgz"
+				request.Params.File = "file-1.3.tgz"
+
+				content = []byte("file-content")
+				err := ioutil.WriteFile(filepath.Join(sourceDir, "file-1.3.tgz"), content, 0644)
+				Expect(err).ToNot(HaveOccurred())
+
+				signatures = map[string][]byte{}
+				gcsClient.UploadFileStub = func(bucketName string, objectPath string, objectContentType string, localPath string, predefinedACL string, cacheControl string) (int64, *storage.Object, error) {
+					uploaded, err := ioutil.ReadFile(localPath)
+					Expect(err).ToNot(HaveOccurred())
+					signatures[objectPath] = uploaded
+
+					return 12345, &storage.Object{}, nil
+				}
+				gcsClient.URLStub = func(bucketName string, objectPath string, generation int64) (string, error) {
+					return fmt.Sprintf("gs://%s/%s#%d", bucketName, objectPath, generation), nil
+				}
+			})
+
+			Context("with an ed25519 key", func() {
+				var publicKey ed25519.PublicKey
+
+				BeforeEach(func() {
+					var privateKey ed25519.PrivateKey
+					publicKey, privateKey, err = ed25519.GenerateKey(rand.Reader)
+					Expect(err).ToNot(HaveOccurred())
+
+					der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+					Expect(err).ToNot(HaveOccurred())
+					request.Source.SigningKey = pemKey("PRIVATE KEY", der)
+				})
+
+				It("uploads a base64 signature next to the object, before the object", func() {
+					_, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gcsClient.UploadFileCallCount()).To(Equal(2))
+					_, objectPath, _, _, _, _ := gcsClient.UploadFileArgsForCall(0)
+					Expect(objectPath).To(Equal("folder/file-1.3.tgz.sig"))
+					_, objectPath, _, _, _, _ = gcsClient.UploadFileArgsForCall(1)
+					Expect(objectPath).To(Equal("folder/file-1.3.tgz"))
+
+					signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signatures["folder/file-1.3.tgz.sig"])))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(ed25519.Verify(publicKey, content, signature)).To(BeTrue())
+				})
+
+				It("records the signature url and key fingerprint in the metadata", func() {
+					response, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					der, err := x509.MarshalPKIXPublicKey(publicKey)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(response.Metadata[3:]).To(Equal([]gcsresource.MetadataPair{
+						{Name: "signature_url", Value: "gs://bucket-name/folder/file-1.3.tgz.sig#0"},
+						{Name: "signing_key_fingerprint", Value: fmt.Sprintf("SHA256:%x", sha256.Sum256(der))},
+					}))
+				})
+
+				It("records the signature generation with track_generations", func() {
+					request.Source.TrackGenerations = true
+
+					response, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(response.Metadata[3].Value).To(Equal("gs://bucket-name/folder/file-1.3.tgz.sig#12345"))
+				})
+			})
+
+			Context("with an ECDSA key", func() {
+				It("signs the sha256 digest of the file", func() {
+					privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+					Expect(err).ToNot(HaveOccurred())
+
+					der, err := x509.MarshalECPrivateKey(privateKey)
+					Expect(err).ToNot(HaveOccurred())
+					request.Source.SigningKey = pemKey("EC PRIVATE KEY", der)
+
+					_, err = command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signatures["folder/file-1.3.tgz.sig"])))
+					Expect(err).ToNot(HaveOccurred())
+					digest := sha256.Sum256(content)
+					Expect(ecdsa.VerifyASN1(&privateKey.PublicKey, digest[:], signature)).To(BeTrue())
+				})
+			})
+
+			Context("with an OpenPGP key", func() {
+				It("uploads an armored detached signature", func() {
+					entity, err := openpgp.NewEntity("publisher", "", "publisher@example.com", nil)
+					Expect(err).ToNot(HaveOccurred())
+
+					var key bytes.Buffer
+					writer, err := armor.Encode(&key, openpgp.PrivateKeyType, nil)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(entity.SerializePrivate(writer, nil)).To(Succeed())
+					Expect(writer.Close()).To(Succeed())
+					request.Source.SigningKey = key.String()
+
+					response, err := command.Run(sourceDir, request)
+					Expect(err).ToNot(HaveOccurred())
+
+					signer, err := openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{entity}, bytes.NewReader(content), bytes.NewReader(signatures["folder/file-1.3.tgz.sig"]))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(signer.PrimaryKey.KeyId).To(Equal(entity.PrimaryKey.KeyId))
+
+					Expect(response.Metadata[4].Value).To(Equal(fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)))
+				})
+			})
+
+			It("does not upload the object if the signature can not be uploaded", func() {
+				_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+				Expect(err).ToNot(HaveOccurred())
+				der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+				Expect(err).ToNot(HaveOccurred())
+				request.Source.SigningKey = pemKey("PRIVATE KEY", der)
+
+				gcsClient.UploadFileReturns(0, nil, errors.New("forbidden"))
+
+				_, err = command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(1))
+				_, objectPath, _, _, _, _ := gcsClient.UploadFileArgsForCall(0)
+				Expect(objectPath).To(Equal("folder/file-1.3.tgz.sig"))
+			})
+
+			It("returns an error before uploading for an invalid signing_key", func() {
+				request.Source.SigningKey = "not-a-key"
+
+				_, err := command.Run(sourceDir, request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("invalid signing_key: not an armored OpenPGP or PEM private key"))
+				Expect(gcsClient.UploadFileCallCount()).To(Equal(0))
+			})
+		})
 	})
 })
@@ -0,0 +1,115 @@
+package out
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	gcsresource "github.com/syslxg/gcs-resource"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// precondition returns the generation the primary object must have for the
+// upload to happen: 0 with if_not_exists or skip_if_exists, the generation
+// in the if_generation_match file, or nil to always upload.
+func (command *OutCommand) precondition(request OutRequest, sourceDir string) (*int64, error) {
+	if request.Params.IfGenerationMatch != "" {
+		generationFile := filepath.Join(sourceDir, request.Params.IfGenerationMatch)
+
+		contents, err := ioutil.ReadFile(generationFile)
+		if err != nil {
+			return nil, err
+		}
+
+		generation, err := strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid generation in if_generation_match file '%s': %s", request.Params.IfGenerationMatch, strings.TrimSpace(string(contents)))
+		}
+
+		return &generation, nil
+	}
+
+	return command.existencePrecondition(request), nil
+}
+
+// existencePrecondition returns the precondition of objects which must not
+// exist yet, or nil to always upload.
+func (command *OutCommand) existencePrecondition(request OutRequest) *int64 {
+	if request.Params.IfNotExists || request.Params.SkipIfExists {
+		generation := int64(0)
+		return &generation
+	}
+
+	return nil
+}
+
+// checkPrecondition looks up the live generation of the primary object
+// before anything is written, so a put failing its precondition leaves no
+// other objects behind. With skip_if_exists, an existing object is not an
+// error: it reports the upload as skipped and returns its generation and
+// object resource.
+func (command *OutCommand) checkPrecondition(request OutRequest, objectPath string, ifGenerationMatch *int64) (int64, *storage.Object, bool, error) {
+	if ifGenerationMatch == nil {
+		return 0, nil, false, nil
+	}
+
+	liveGeneration := int64(0)
+	object, err := command.gcsClient.GetBucketObjectInfo(request.Source.Bucket, objectPath)
+	if err == nil {
+		liveGeneration = object.Generation
+	} else if err != gcsresource.ErrObjectNotExist {
+		return 0, nil, false, err
+	}
+
+	if liveGeneration == *ifGenerationMatch {
+		return 0, nil, false, nil
+	}
+
+	if *ifGenerationMatch == 0 && request.Params.SkipIfExists {
+		return liveGeneration, object, true, nil
+	}
+
+	return 0, nil, false, preconditionError(objectPath, *ifGenerationMatch)
+}
+
+// preconditionError describes the failed precondition of an object.
+func preconditionError(objectPath string, ifGenerationMatch int64) error {
+	if ifGenerationMatch != 0 {
+		return fmt.Errorf("object '%s' does not have generation %d", objectPath, ifGenerationMatch)
+	}
+
+	return fmt.Errorf("object already exists: %s", objectPath)
+}
+
+// uploadFile uploads a file, only if the object has the given generation
+// when ifGenerationMatch is set, and returns its generation and object
+// resource. With skip_if_exists, an existing object is not an error: the
+// upload is skipped and the existing object returned.
+func (command *OutCommand) uploadFile(request OutRequest, objectPath string, localPath string, ifGenerationMatch *int64) (int64, *storage.Object, bool, error) {
+	bucketName := request.Source.Bucket
+	objectContentType := command.objectContentType(request)
+	parallelUploadThreshold := command.ParallelUploadThreshold(request)
+
+	if ifGenerationMatch == nil {
+		generation, object, err := command.gcsClient.UploadFile(bucketName, objectPath, objectContentType, localPath, request.Params.PredefinedACL, request.Params.CacheControl, parallelUploadThreshold)
+		return generation, object, false, err
+	}
+
+	generation, object, err := command.gcsClient.UploadFileIfGenerationMatch(bucketName, objectPath, objectContentType, localPath, request.Params.PredefinedACL, request.Params.CacheControl, parallelUploadThreshold, *ifGenerationMatch)
+	if err != gcsresource.ErrPreconditionFailed {
+		return generation, object, false, err
+	}
+
+	if *ifGenerationMatch != 0 || !request.Params.SkipIfExists {
+		return 0, nil, false, preconditionError(objectPath, *ifGenerationMatch)
+	}
+
+	object, err = command.gcsClient.GetBucketObjectInfo(bucketName, objectPath)
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	return object.Generation, object, true, nil
+}
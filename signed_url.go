@@ -0,0 +1,251 @@
+package gcsresource
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2"
+	oauthgoogle "golang.org/x/oauth2/google"
+)
+
+const (
+	signedURLHost = "storage.googleapis.com"
+
+	// MaxSignedURLTTL is the longest a V4 signed url can be valid for.
+	MaxSignedURLTTL = 7 * 24 * time.Hour
+
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	signBlobURL        = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:signBlob"
+)
+
+// ParseSignedURLTTL parses a signed_url_ttl duration, which must be positive
+// and at most MaxSignedURLTTL.
+func ParseSignedURLTTL(ttl string) (time.Duration, error) {
+	duration, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, err
+	}
+
+	if duration <= 0 || duration > MaxSignedURLTTL {
+		return 0, fmt.Errorf("must be between 1s and %s", MaxSignedURLTTL)
+	}
+
+	return duration, nil
+}
+
+// urlSigner signs urls as a service account.
+type urlSigner struct {
+	email string
+	sign  func(payload []byte) ([]byte, error)
+}
+
+// SignedURL returns a V4 signed https url to get the object at the given
+// generation, valid for ttl.
+func (gcsclient *gcsclient) SignedURL(bucketName string, objectPath string, generation int64, ttl time.Duration) (string, error) {
+	signer, err := gcsclient.urlSigner()
+	if err != nil {
+		return "", fmt.Errorf("failed to sign url: %s", err)
+	}
+
+	signedURL, err := signURL(signer, bucketName, objectPath, generation, ttl, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to sign url: %s", err)
+	}
+
+	return signedURL, nil
+}
+
+// urlSigner signs with the private key of the json key or the default
+// credentials file, and otherwise with the IAM signBlob method of the
+// default service account.
+func (gcsclient *gcsclient) urlSigner() (urlSigner, error) {
+	jsonKey := []byte(gcsclient.jsonKey)
+	if len(jsonKey) == 0 {
+		credentials, err := oauthgoogle.FindDefaultCredentials(oauth2.NoContext, cloudPlatformScope)
+		if err != nil {
+			return urlSigner{}, err
+		}
+		jsonKey = credentials.JSON
+	}
+
+	if len(jsonKey) > 0 {
+		var serviceAccount struct {
+			ClientEmail string `json:"client_email"`
+			PrivateKey  string `json:"private_key"`
+		}
+		if err := json.Unmarshal(jsonKey, &serviceAccount); err != nil {
+			return urlSigner{}, err
+		}
+
+		if serviceAccount.PrivateKey != "" {
+			return privateKeySigner(serviceAccount.ClientEmail, serviceAccount.PrivateKey)
+		}
+	}
+
+	email, err := metadata.Get("instance/service-accounts/default/email")
+	if err != nil {
+		return urlSigner{}, fmt.Errorf("no private key available and the default service account is unknown: %s", err)
+	}
+
+	client, err := oauthgoogle.DefaultClient(oauth2.NoContext, cloudPlatformScope)
+	if err != nil {
+		return urlSigner{}, err
+	}
+
+	return urlSigner{
+		email: email,
+		sign: func(payload []byte) ([]byte, error) {
+			return signBlob(client, email, payload)
+		},
+	}, nil
+}
+
+func privateKeySigner(email string, privateKey string) (urlSigner, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return urlSigner{}, errors.New("invalid private key in json key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return urlSigner{}, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return urlSigner{}, errors.New("private key in json key is not an RSA key")
+	}
+
+	return urlSigner{
+		email: email,
+		sign: func(payload []byte) ([]byte, error) {
+			digest := sha256.Sum256(payload)
+			return rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		},
+	}, nil
+}
+
+func signBlob(client *http.Client, email string, payload []byte) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{
+		"payload": base64.StdEncoding.EncodeToString(payload),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Post(fmt.Sprintf(signBlobURL, url.PathEscape(email)), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signBlob failed for %s: %s", email, response.Status)
+	}
+
+	var signed struct {
+		SignedBlob string `json:"signedBlob"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&signed); err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(signed.SignedBlob)
+}
+
+// signURL builds a V4 signed url, see
+// https://cloud.google.com/storage/docs/access-control/signing-urls-manually
+func signURL(signer urlSigner, bucketName string, objectPath string, generation int64, ttl time.Duration, now time.Time) (string, error) {
+	now = now.UTC()
+	date := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+	scope := date + "/auto/storage/goog4_request"
+
+	query := map[string]string{
+		"X-Goog-Algorithm":     "GOOG4-RSA-SHA256",
+		"X-Goog-Credential":    signer.email + "/" + scope,
+		"X-Goog-Date":          timestamp,
+		"X-Goog-Expires":       fmt.Sprintf("%d", int64(ttl/time.Second)),
+		"X-Goog-SignedHeaders": "host",
+	}
+	if generation != 0 {
+		query["generation"] = fmt.Sprintf("%d", generation)
+	}
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parameters := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parameters = append(parameters, uriEncode(key, true)+"="+uriEncode(query[key], true))
+	}
+	canonicalQuery := strings.Join(parameters, "&")
+
+	canonicalPath := "/" + bucketName + "/" + uriEncode(objectPath, false)
+
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		canonicalPath,
+		canonicalQuery,
+		"host:" + signedURLHost,
+		"",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	requestDigest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"GOOG4-RSA-SHA256",
+		timestamp,
+		scope,
+		hex.EncodeToString(requestDigest[:]),
+	}, "\n")
+
+	signature, err := signer.sign([]byte(stringToSign))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("https://%s%s?%s&X-Goog-Signature=%s", signedURLHost, canonicalPath, canonicalQuery, hex.EncodeToString(signature)), nil
+}
+
+// uriEncode percent-encodes everything but the unreserved characters of
+// RFC 3986, and slashes in paths.
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '.', b == '_', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+
+	return encoded.String()
+}
@@ -0,0 +1,269 @@
+package versions
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cppforlife/go-semi-semantic/version"
+	"github.com/syslxg/gcs-resource"
+	storage "google.golang.org/api/storage/v1"
+)
+
+const (
+	VersionFromUpdated  = "updated"
+	VersionFromMetadata = "metadata"
+	VersionFromMD5      = "md5"
+	VersionFromCRC32C   = "crc32c"
+)
+
+type VersionFrom struct {
+	// the object attribute the version is taken from
+	Attribute string
+
+	// the custom metadata key, only set for the metadata attribute
+	Key string
+}
+
+func ParseVersionFrom(versionFrom string) (VersionFrom, error) {
+	switch {
+	case versionFrom == VersionFromUpdated, versionFrom == VersionFromMD5, versionFrom == VersionFromCRC32C:
+		return VersionFrom{Attribute: versionFrom}, nil
+	case strings.HasPrefix(versionFrom, VersionFromMetadata+":") && len(versionFrom) > len(VersionFromMetadata)+1:
+		return VersionFrom{Attribute: VersionFromMetadata, Key: versionFrom[len(VersionFromMetadata)+1:]}, nil
+	}
+
+	return VersionFrom{}, fmt.Errorf("invalid version_from value specified: %s", versionFrom)
+}
+
+// IsContentHash reports whether versions are content hashes, which carry no
+// ordering of their own.
+func (v VersionFrom) IsContentHash() bool {
+	return v.Attribute == VersionFromMD5 || v.Attribute == VersionFromCRC32C
+}
+
+type Attributions []Attribution
+
+func (a Attributions) Len() int {
+	return len(a)
+}
+
+func (a Attributions) Less(i int, j int) bool {
+	return a[i].isBefore(a[j])
+}
+
+func (a Attributions) Swap(i int, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+type Attribution struct {
+	// path to gcs object in bucket
+	Path string
+
+	// the raw attribute value used as the version
+	Value string
+
+	// parsed version, only set for metadata attributes
+	Version version.Version
+
+	// last modification time of the gcs object
+	Updated time.Time
+
+	// creation time of the gcs object
+	Created time.Time
+}
+
+func (a Attribution) isBefore(other Attribution) bool {
+	if !a.Version.Empty() && !other.Version.Empty() && !a.Version.IsEq(other.Version) {
+		return a.Version.IsLt(other.Version)
+	}
+
+	return a.Updated.Before(other.Updated)
+}
+
+// Attribute derives the version of an object from the attribute selected by
+// versionFrom. It returns false if the object does not carry the attribute.
+func Attribute(object *storage.Object, versionFrom VersionFrom) (Attribution, bool) {
+	attribution := Attribution{
+		Path: object.Name,
+	}
+
+	if object.Updated != "" {
+		updated, err := time.Parse(time.RFC3339Nano, object.Updated)
+		if err != nil {
+			return Attribution{}, false
+		}
+		attribution.Updated = updated
+	}
+
+	if object.TimeCreated != "" {
+		created, err := time.Parse(time.RFC3339Nano, object.TimeCreated)
+		if err != nil {
+			return Attribution{}, false
+		}
+		attribution.Created = created
+	}
+
+	switch versionFrom.Attribute {
+	case VersionFromUpdated:
+		if object.Updated == "" {
+			return Attribution{}, false
+		}
+		attribution.Value = object.Updated
+	case VersionFromMetadata:
+		value, ok := object.Metadata[versionFrom.Key]
+		if !ok || value == "" {
+			return Attribution{}, false
+		}
+
+		ver, err := version.NewVersionFromString(value)
+		if err != nil {
+			return Attribution{}, false
+		}
+		attribution.Value = value
+		attribution.Version = ver
+	case VersionFromMD5:
+		value, ok := hexHash(object.Md5Hash)
+		if !ok {
+			return Attribution{}, false
+		}
+		attribution.Value = value
+	case VersionFromCRC32C:
+		value, ok := hexHash(object.Crc32c)
+		if !ok {
+			return Attribution{}, false
+		}
+		attribution.Value = value
+	default:
+		return Attribution{}, false
+	}
+
+	return attribution, true
+}
+
+func hexHash(encoded string) (string, bool) {
+	if encoded == "" {
+		return "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+
+	return hex.EncodeToString(decoded), true
+}
+
+func GetBucketObjectAttributions(gcsClient gcsresource.GCSClient, source gcsresource.Source) Attributions {
+	versionFrom, err := ParseVersionFrom(source.VersionFrom)
+	if err != nil {
+		gcsresource.Fatal("parsing version_from", err)
+	}
+
+	bucketObjects, err := gcsClient.BucketObjectsInfo(source.Bucket, Query(source.Regexp))
+	if err != nil {
+		gcsresource.Fatal("listing objects", err)
+	}
+
+	paths := make([]string, 0, len(bucketObjects))
+	objects := make(map[string]*storage.Object, len(bucketObjects))
+	for _, object := range bucketObjects {
+		paths = append(paths, object.Name)
+		objects[object.Name] = object
+	}
+
+	matchingPaths, err := Match(paths, source.Regexp)
+	if err != nil {
+		gcsresource.Fatal("finding matches", err)
+	}
+
+	var attributions = make(Attributions, 0, len(matchingPaths))
+	for _, path := range matchingPaths {
+		attribution, ok := Attribute(objects[path], versionFrom)
+
+		if ok {
+			attributions = append(attributions, attribution)
+		}
+	}
+
+	sort.Stable(attributions)
+
+	if versionFrom.IsContentHash() {
+		attributions = attributions.dedupe()
+	}
+
+	return attributions
+}
+
+// dedupe keeps the most recently updated object for every value, so identical
+// re-uploads do not produce new versions.
+func (a Attributions) dedupe() Attributions {
+	latest := make(map[string]int, len(a))
+	for i, attribution := range a {
+		latest[attribution.Value] = i
+	}
+
+	deduped := make(Attributions, 0, len(latest))
+	for i, attribution := range a {
+		if latest[attribution.Value] == i {
+			deduped = append(deduped, attribution)
+		}
+	}
+
+	return deduped
+}
+
+// Since returns the attributions of objects created at or after since.
+func (a Attributions) Since(since time.Time) Attributions {
+	filtered := Attributions{}
+	for _, attribution := range a {
+		if !attribution.Created.Before(since) {
+			filtered = append(filtered, attribution)
+		}
+	}
+
+	return filtered
+}
+
+// Newer returns the attributions following the one matching path and value.
+// It returns false if the previous version can no longer be located.
+func (a Attributions) Newer(path string, value string, versionFrom VersionFrom) (Attributions, bool) {
+	for i, attribution := range a {
+		if attribution.Value == value && (versionFrom.IsContentHash() || attribution.Path == path) {
+			return a[i+1:], true
+		}
+	}
+
+	if versionFrom.IsContentHash() {
+		return nil, false
+	}
+
+	previous, ok := Attribute(&storage.Object{
+		Name:     path,
+		Updated:  valueFor(versionFrom, VersionFromUpdated, value),
+		Metadata: map[string]string{versionFrom.Key: valueFor(versionFrom, VersionFromMetadata, value)},
+	}, versionFrom)
+	if !ok {
+		return nil, false
+	}
+
+	newer := Attributions{}
+	for _, attribution := range a {
+		if previous.isBefore(attribution) {
+			newer = append(newer, attribution)
+		}
+	}
+
+	return newer, true
+}
+
+func valueFor(versionFrom VersionFrom, attribute string, value string) string {
+	if versionFrom.Attribute == attribute {
+		return value
+	}
+
+	return ""
+}
@@ -1,6 +1,8 @@
 package versions
 
 import (
+	"time"
+
 	"github.com/cppforlife/go-semi-semantic/version"
 )
 
@@ -11,6 +13,10 @@ func (e Extractions) Len() int {
 }
 
 func (e Extractions) Less(i int, j int) bool {
+	if e[i].Version.IsEq(e[j].Version) {
+		return e[i].Generation < e[j].Generation
+	}
+
 	return e[i].Version.IsLt(e[j].Version)
 }
 
@@ -27,4 +33,22 @@ type Extraction struct {
 
 	// the raw version match
 	VersionNumber string
+
+	// generation of gcs object, only set when generations are tracked
+	Generation int64
+
+	// creation time of the gcs object, only set along with the generation
+	Created time.Time
+}
+
+// Since returns the extractions of objects created at or after since.
+func (e Extractions) Since(since time.Time) Extractions {
+	filtered := Extractions{}
+	for _, extraction := range e {
+		if !extraction.Created.Before(since) {
+			filtered = append(filtered, extraction)
+		}
+	}
+
+	return filtered
 }
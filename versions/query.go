@@ -0,0 +1,209 @@
+package versions
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode"
+
+	"github.com/syslxg/gcs-resource"
+)
+
+// globSpecialChars are the characters with a meaning in gcs match globs.
+const globSpecialChars = `*?[]{},\!^`
+
+// Query derives the narrowest listing gcs can serve for a regexp. The listing
+// is a superset of the matching objects, so results still need to be matched.
+func Query(regex string) gcsresource.ObjectQuery {
+	query := gcsresource.ObjectQuery{
+		Prefix: LiteralPrefix(regex),
+	}
+
+	re, ok := parse(regex)
+	if !ok {
+		return query
+	}
+
+	query.StartOffset = startOffset(re)
+
+	if glob, ok := MatchGlob(regex); ok && glob != query.Prefix+"**" {
+		query.MatchGlob = glob
+	}
+
+	return query
+}
+
+// LiteralPrefix returns the literal text every path matching regex starts
+// with, e.g. `releases/app-` for `releases/app-(.*)\.tgz`.
+func LiteralPrefix(regex string) string {
+	re, ok := parse(regex)
+	if !ok {
+		return Prefix(regex)
+	}
+
+	prefix, _ := literalPrefix(re)
+
+	return prefix
+}
+
+// MatchGlob converts regex to a gcs match glob accepting at least every path
+// matched by regex. It returns false if regex can not be expressed as a glob.
+func MatchGlob(regex string) (string, bool) {
+	re, ok := parse(regex)
+	if !ok {
+		return "", false
+	}
+
+	return glob(re)
+}
+
+// parse parses regex the way Match anchors it.
+func parse(regex string) (*syntax.Regexp, bool) {
+	re, err := syntax.Parse("^"+regex+"$", syntax.Perl)
+	if err != nil {
+		return nil, false
+	}
+
+	return re.Simplify(), true
+}
+
+// elements flattens the concatenations and capture groups of re.
+func elements(re *syntax.Regexp) []*syntax.Regexp {
+	switch re.Op {
+	case syntax.OpConcat:
+		var flattened []*syntax.Regexp
+		for _, sub := range re.Sub {
+			flattened = append(flattened, elements(sub)...)
+		}
+		return flattened
+	case syntax.OpCapture:
+		return elements(re.Sub[0])
+	}
+
+	return []*syntax.Regexp{re}
+}
+
+// literalPrefix returns the literal prefix of re and the elements following it.
+func literalPrefix(re *syntax.Regexp) (string, []*syntax.Regexp) {
+	prefix := ""
+
+	flattened := elements(re)
+	for i, element := range flattened {
+		switch {
+		case element.Op == syntax.OpBeginText || element.Op == syntax.OpBeginLine:
+		case element.Op == syntax.OpLiteral && element.Flags&syntax.FoldCase == 0:
+			prefix += string(element.Rune)
+		default:
+			return prefix, flattened[i:]
+		}
+	}
+
+	return prefix, nil
+}
+
+// startOffset skips the objects sorting before the first character allowed
+// after the literal prefix of re. Only classes of positive, printable
+// characters give one: a negated class like [^/] starts at \x00 and reaches
+// the end of unicode, so it skips nothing.
+func startOffset(re *syntax.Regexp) string {
+	prefix, rest := literalPrefix(re)
+	if len(rest) == 0 {
+		return ""
+	}
+
+	next := rest[0]
+	if next.Op == syntax.OpPlus {
+		next = next.Sub[0]
+	}
+
+	if next.Op != syntax.OpCharClass || len(next.Rune) == 0 || next.Flags&syntax.FoldCase != 0 {
+		return ""
+	}
+
+	first, last := next.Rune[0], next.Rune[len(next.Rune)-1]
+	if !unicode.IsPrint(first) || last == unicode.MaxRune {
+		return ""
+	}
+
+	return prefix + string(next.Rune[0])
+}
+
+func glob(re *syntax.Regexp) (string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginText, syntax.OpEndText, syntax.OpBeginLine, syntax.OpEndLine:
+		return "", true
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return "", false
+		}
+
+		literal := string(re.Rune)
+		if strings.ContainsAny(literal, globSpecialChars) {
+			return "", false
+		}
+		return literal, true
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return "?", true
+	case syntax.OpCharClass:
+		return globClass(re)
+	case syntax.OpStar, syntax.OpPlus:
+		sub := re.Sub[0]
+		if sub.Op != syntax.OpAnyChar && sub.Op != syntax.OpAnyCharNotNL {
+			return "", false
+		}
+
+		if re.Op == syntax.OpPlus {
+			return "?**", true
+		}
+		return "**", true
+	case syntax.OpCapture:
+		return glob(re.Sub[0])
+	case syntax.OpConcat:
+		var converted strings.Builder
+		for _, sub := range re.Sub {
+			part, ok := glob(sub)
+			if !ok {
+				return "", false
+			}
+			converted.WriteString(part)
+		}
+		return converted.String(), true
+	case syntax.OpAlternate:
+		alternatives := make([]string, 0, len(re.Sub))
+		for _, sub := range re.Sub {
+			part, ok := glob(sub)
+			if !ok || strings.ContainsAny(part, "{},") {
+				return "", false
+			}
+			alternatives = append(alternatives, part)
+		}
+		return "{" + strings.Join(alternatives, ",") + "}", true
+	}
+
+	return "", false
+}
+
+func globClass(re *syntax.Regexp) (string, bool) {
+	if len(re.Rune) == 0 {
+		return "", false
+	}
+
+	var class strings.Builder
+	class.WriteString("[")
+	for i := 0; i < len(re.Rune); i += 2 {
+		lo, hi := re.Rune[i], re.Rune[i+1]
+		for _, r := range []rune{lo, hi} {
+			if r < ' ' || r > '~' || strings.ContainsRune(globSpecialChars+"-", r) {
+				return "", false
+			}
+		}
+
+		class.WriteRune(lo)
+		if hi != lo {
+			class.WriteString("-")
+			class.WriteRune(hi)
+		}
+	}
+	class.WriteString("]")
+
+	return class.String(), true
+}
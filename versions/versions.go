@@ -4,18 +4,19 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/cppforlife/go-semi-semantic/version"
 	"github.com/syslxg/gcs-resource"
+	storage "google.golang.org/api/storage/v1"
 )
 
 const regexpSpecialChars = `\\\*\.\[\]\(\)\{\}\?\|\^\$\+`
 
 func GetBucketObjectVersions(gcsClient gcsresource.GCSClient, source gcsresource.Source) Extractions {
 	regexp := source.Regexp
-	prefix := Prefix(regexp)
 
-	bucketObjects, err := gcsClient.BucketObjects(source.Bucket, prefix)
+	bucketObjects, err := gcsClient.QueryBucketObjects(source.Bucket, Query(regexp))
 	if err != nil {
 		gcsresource.Fatal("listing objects", err)
 	}
@@ -39,6 +40,76 @@ func GetBucketObjectVersions(gcsClient gcsresource.GCSClient, source gcsresource
 	return extractions
 }
 
+func GetBucketObjectGenerations(gcsClient gcsresource.GCSClient, source gcsresource.Source) Extractions {
+	regexp := source.Regexp
+
+	bucketObjects, err := gcsClient.BucketObjectsInfo(source.Bucket, Query(regexp))
+	if err != nil {
+		gcsresource.Fatal("listing objects", err)
+	}
+
+	paths := make([]string, 0, len(bucketObjects))
+	objects := make(map[string]*storage.Object, len(bucketObjects))
+	for _, object := range bucketObjects {
+		paths = append(paths, object.Name)
+		objects[object.Name] = object
+	}
+
+	matchingPaths, err := Match(paths, source.Regexp)
+	if err != nil {
+		gcsresource.Fatal("finding matches", err)
+	}
+
+	var extractions = make(Extractions, 0, len(matchingPaths))
+	for _, path := range matchingPaths {
+		extraction, ok := Extract(path, regexp)
+
+		if ok {
+			extraction.Generation = objects[path].Generation
+			extraction.Created, _ = time.Parse(time.RFC3339Nano, objects[path].TimeCreated)
+			extractions = append(extractions, extraction)
+		}
+	}
+
+	sort.Sort(extractions)
+
+	return extractions
+}
+
+func GetBucketPrefixVersions(gcsClient gcsresource.GCSClient, source gcsresource.Source) Extractions {
+	regexp := PrefixPattern(source.PrefixRegexp)
+	prefix := Prefix(regexp)
+
+	bucketPrefixes, err := gcsClient.BucketPrefixes(source.Bucket, prefix)
+	if err != nil {
+		gcsresource.Fatal("listing prefixes", err)
+	}
+
+	matchingPaths, err := Match(bucketPrefixes, regexp)
+	if err != nil {
+		gcsresource.Fatal("finding matches", err)
+	}
+
+	var extractions = make(Extractions, 0, len(matchingPaths))
+	for _, path := range matchingPaths {
+		extraction, ok := Extract(path, regexp)
+
+		if ok {
+			extractions = append(extractions, extraction)
+		}
+	}
+
+	sort.Sort(extractions)
+
+	return extractions
+}
+
+// PrefixPattern normalizes a prefix regexp to match the trailing slash of
+// the common prefixes returned by gcs.
+func PrefixPattern(prefixRegexp string) string {
+	return strings.TrimSuffix(prefixRegexp, "/") + "/"
+}
+
 func Prefix(regex string) string {
 	nonRE := regexp.MustCompile(`\\(?P<chr>[` + regexpSpecialChars + `])|(?P<chr>[^` + regexpSpecialChars + `])`)
 	re := regexp.MustCompile(`^(` + nonRE.String() + `)*$`)
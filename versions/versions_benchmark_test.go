@@ -0,0 +1,81 @@
+package versions_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/syslxg/gcs-resource"
+	"github.com/syslxg/gcs-resource/fakes"
+	"github.com/syslxg/gcs-resource/versions"
+)
+
+const benchmarkRegexp = `releases/app-(\d+)\.tgz`
+
+// benchmarkObjects holds 100k object names, of which only 1k match
+// benchmarkRegexp.
+func benchmarkObjects() []string {
+	objects := make([]string, 0, 100000)
+	for i := 0; i < 1000; i++ {
+		objects = append(objects, fmt.Sprintf("releases/app-%d.tgz", i))
+	}
+	for i := 0; i < 99000; i++ {
+		objects = append(objects, fmt.Sprintf("releases/other-%d.tgz", i))
+	}
+
+	sort.Strings(objects)
+
+	return objects
+}
+
+// serverFilter mimics the filtering gcs does on prefix and start offset.
+func serverFilter(objects []string) func(string, gcsresource.ObjectQuery) ([]string, error) {
+	return func(bucketName string, query gcsresource.ObjectQuery) ([]string, error) {
+		start := sort.SearchStrings(objects, query.StartOffset)
+		if query.Prefix > query.StartOffset {
+			start = sort.SearchStrings(objects, query.Prefix)
+		}
+
+		listed := []string{}
+		for _, object := range objects[start:] {
+			if !strings.HasPrefix(object, query.Prefix) {
+				break
+			}
+			listed = append(listed, object)
+		}
+
+		return listed, nil
+	}
+}
+
+func BenchmarkGetBucketObjectVersions(b *testing.B) {
+	gcsClient := &fakes.FakeGCSClient{}
+	gcsClient.QueryBucketObjectsStub = serverFilter(benchmarkObjects())
+	source := gcsresource.Source{Bucket: "bucket-name", Regexp: benchmarkRegexp}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		versions.GetBucketObjectVersions(gcsClient, source)
+	}
+}
+
+func BenchmarkGetBucketObjectVersionsDirectoryPrefix(b *testing.B) {
+	objects := benchmarkObjects()
+	gcsClient := &fakes.FakeGCSClient{}
+	gcsClient.QueryBucketObjectsStub = func(bucketName string, query gcsresource.ObjectQuery) ([]string, error) {
+		return serverFilter(objects)(bucketName, gcsresource.ObjectQuery{Prefix: versions.Prefix(benchmarkRegexp)})
+	}
+	source := gcsresource.Source{Bucket: "bucket-name", Regexp: benchmarkRegexp}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		versions.GetBucketObjectVersions(gcsClient, source)
+	}
+}
+
+func BenchmarkQuery(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		versions.Query(benchmarkRegexp)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/syslxg/gcs-resource"
 	"github.com/syslxg/gcs-resource/versions"
+	storage "google.golang.org/api/storage/v1"
 )
 
 type MatchFunc func(paths []string, pattern string) ([]string, error)
@@ -121,6 +123,66 @@ var _ = Describe("Prefix", func() {
 	})
 })
 
+var _ = Describe("LiteralPrefix", func() {
+	It("returns the literal text every match starts with", func() {
+		Expect(versions.LiteralPrefix("releases/app-(.*).tgz")).To(Equal("releases/app-"))
+		Expect(versions.LiteralPrefix("(.*).tgz")).To(Equal(""))
+		Expect(versions.LiteralPrefix(`hello/cruel\[\\\^\$\.\|\?\*\+\(\)world/fizz-(.*).tgz`)).To(Equal(`hello/cruel[\^$.|?*+()world/fizz-`))
+		Expect(versions.LiteralPrefix(`hello/\d{3}/fizz-(.*).tgz`)).To(Equal("hello/"))
+	})
+
+	It("stops at optional, case insensitive or alternative parts", func() {
+		Expect(versions.LiteralPrefix("releases/apps?-(.*).tgz")).To(Equal("releases/app"))
+		Expect(versions.LiteralPrefix("releases/(?i)app-(.*).tgz")).To(Equal("releases/"))
+		Expect(versions.LiteralPrefix("releases/app-(.*).tgz|other/(.*).tgz")).To(Equal(""))
+	})
+})
+
+var _ = Describe("MatchGlob", func() {
+	It("converts expressible regexps", func() {
+		glob, ok := versions.MatchGlob("releases/app-(.*).tgz")
+		Expect(ok).To(BeTrue())
+		Expect(glob).To(Equal("releases/app-**?tgz"))
+
+		glob, ok = versions.MatchGlob(`builds/[0-9][0-9]/(.*)\.(tgz|zip)`)
+		Expect(ok).To(BeTrue())
+		Expect(glob).To(Equal("builds/[0-9][0-9]/**.{tgz,zip}"))
+	})
+
+	It("refuses regexps it can not express", func() {
+		_, ok := versions.MatchGlob(`releases/app-(\d+)\.tgz`)
+		Expect(ok).To(BeFalse())
+
+		_, ok = versions.MatchGlob(`releases/app\*-(.*).tgz`)
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("Query", func() {
+	It("narrows down the listing", func() {
+		Expect(versions.Query(`releases/app-(\d+)\.tgz`)).To(Equal(gcsresource.ObjectQuery{
+			Prefix:      "releases/app-",
+			StartOffset: "releases/app-0",
+		}))
+		Expect(versions.Query("releases/app-(.*)")).To(Equal(gcsresource.ObjectQuery{
+			Prefix: "releases/app-",
+		}))
+	})
+
+	It("only derives a start offset from positive characters", func() {
+		Expect(versions.Query(`releases/app-([a-z]+)\.tgz`)).To(Equal(gcsresource.ObjectQuery{
+			Prefix:      "releases/app-",
+			StartOffset: "releases/app-a",
+		}))
+		Expect(versions.Query(`releases/app-([^/]+)\.tgz`)).To(Equal(gcsresource.ObjectQuery{
+			Prefix: "releases/app-",
+		}))
+		Expect(versions.Query(`releases/app-([^a-z]+)\.tgz`)).To(Equal(gcsresource.ObjectQuery{
+			Prefix: "releases/app-",
+		}))
+	})
+})
+
 var _ = Describe("Extract", func() {
 	Context("when the path does not contain extractable information", func() {
 		It("doesn't extract it", func() {
@@ -176,3 +238,71 @@ var _ = Describe("Extract", func() {
 		})
 	})
 })
+
+var _ = Describe("ParseVersionFrom", func() {
+	It("accepts object attributes", func() {
+		Expect(versions.ParseVersionFrom("updated")).To(Equal(versions.VersionFrom{Attribute: "updated"}))
+		Expect(versions.ParseVersionFrom("md5")).To(Equal(versions.VersionFrom{Attribute: "md5"}))
+		Expect(versions.ParseVersionFrom("crc32c")).To(Equal(versions.VersionFrom{Attribute: "crc32c"}))
+	})
+
+	It("accepts custom metadata keys", func() {
+		Expect(versions.ParseVersionFrom("metadata:build")).To(Equal(versions.VersionFrom{Attribute: "metadata", Key: "build"}))
+	})
+
+	It("errors on unknown attributes", func() {
+		_, err := versions.ParseVersionFrom("size")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("invalid version_from value specified: size"))
+
+		_, err = versions.ParseVersionFrom("metadata:")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Attribute", func() {
+	var object *storage.Object
+
+	BeforeEach(func() {
+		object = &storage.Object{
+			Name:     "folder/latest.tgz",
+			Updated:  "2018-01-02T03:04:05.678Z",
+			Md5Hash:  "XUFAKrxLKna5cZ2REBfFkg==",
+			Crc32c:   "mnG7TA==",
+			Metadata: map[string]string{"build": "1.2.3"},
+		}
+	})
+
+	It("uses the update time", func() {
+		result, ok := versions.Attribute(object, versions.VersionFrom{Attribute: "updated"})
+		Expect(ok).To(BeTrue())
+		Expect(result.Path).To(Equal("folder/latest.tgz"))
+		Expect(result.Value).To(Equal("2018-01-02T03:04:05.678Z"))
+	})
+
+	It("uses custom metadata", func() {
+		result, ok := versions.Attribute(object, versions.VersionFrom{Attribute: "metadata", Key: "build"})
+		Expect(ok).To(BeTrue())
+		Expect(result.Value).To(Equal("1.2.3"))
+		Expect(result.Version.String()).To(Equal("1.2.3"))
+	})
+
+	It("uses hex encoded content hashes", func() {
+		result, ok := versions.Attribute(object, versions.VersionFrom{Attribute: "md5"})
+		Expect(ok).To(BeTrue())
+		Expect(result.Value).To(Equal("5d41402abc4b2a76b9719d911017c592"))
+
+		result, ok = versions.Attribute(object, versions.VersionFrom{Attribute: "crc32c"})
+		Expect(ok).To(BeTrue())
+		Expect(result.Value).To(Equal("9a71bb4c"))
+	})
+
+	It("does not attribute objects without the attribute", func() {
+		_, ok := versions.Attribute(object, versions.VersionFrom{Attribute: "metadata", Key: "missing"})
+		Expect(ok).To(BeFalse())
+
+		object.Md5Hash = ""
+		_, ok = versions.Attribute(object, versions.VersionFrom{Attribute: "md5"})
+		Expect(ok).To(BeFalse())
+	})
+})